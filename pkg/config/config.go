@@ -31,6 +31,23 @@ type Server struct {
 	AuthCheck   string `yaml:"authCheck,omitempty" json:"authCheck,omitempty"`
 }
 
+const (
+	SavedQueriesFileStore      = "file"
+	SavedQueriesConfigMapStore = "configmap"
+)
+
+type SavedQueries struct {
+	Store         string `yaml:"store,omitempty" json:"store,omitempty"`
+	FilePath      string `yaml:"filePath,omitempty" json:"filePath,omitempty"`
+	Namespace     string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	ConfigMapName string `yaml:"configMapName,omitempty" json:"configMapName,omitempty"`
+}
+
+// PodLabelFilters allows filtering flows by pod labels. It requires permissions to list and watch pods in all namespaces
+type PodLabelFilters struct {
+	Enable bool `yaml:"enable" json:"enable"`
+}
+
 type Prometheus struct {
 	URL              string       `yaml:"url" json:"url"`
 	Timeout          Duration     `yaml:"timeout,omitempty" json:"timeout,omitempty"`
@@ -39,6 +56,15 @@ type Prometheus struct {
 	CAPath           string       `yaml:"caPath,omitempty" json:"caPath,omitempty"`
 	ForwardUserToken bool         `yaml:"forwardUserToken,omitempty" json:"forwardUserToken,omitempty"`
 	Metrics          []MetricInfo `yaml:"metrics,omitempty" json:"metrics,omitempty"`
+	Discovery        Discovery    `yaml:"discovery,omitempty" json:"discovery,omitempty"`
+}
+
+// Discovery enables listing the netobserv metrics known by Prometheus, periodically, in addition to the configured
+// metrics. The configured metrics take precedence, except for their labels which are completed from series when found.
+// As it doesn't run on behalf of a user, it requires a tokenPath when forwardUserToken is set
+type Discovery struct {
+	Enable   bool     `yaml:"enable" json:"enable"`
+	Interval Duration `yaml:"interval,omitempty" json:"interval,omitempty"`
 }
 
 type FlowDirection string
@@ -98,6 +124,15 @@ type QuickFilter struct {
 	Default bool `yaml:"default,omitempty" json:"default,omitempty"`
 }
 
+const (
+	FieldTypeString      = "string"
+	FieldTypeNumber      = "number"
+	FieldTypeBoolean     = "boolean"
+	FieldTypeIP          = "ip"
+	FieldTypeStringArray = "string[]"
+	FieldTypeNumberArray = "number[]"
+)
+
 type FieldConfig struct {
 	Name        string `yaml:"name" json:"name"`
 	Type        string `yaml:"type" json:"type"`
@@ -109,6 +144,18 @@ type FieldConfig struct {
 type Deduper struct {
 	Mark  bool `yaml:"mark" json:"mark"`
 	Merge bool `yaml:"merge" json:"merge"`
+	// MergeStrategy is how flows reported by both the ingress and egress nodes are counted once: "ingress" (default)
+	// counts ingress flows and egress flows leaving the cluster, "egress" counts egress flows and ingress flows
+	// entering the cluster, "max" takes the largest of both directions, and "none" counts both
+	MergeStrategy constants.ReportersMerge `yaml:"mergeStrategy,omitempty" json:"mergeStrategy,omitempty"`
+}
+
+// GetMergeStrategy returns the merge strategy, defaulting to ingress
+func (d *Deduper) GetMergeStrategy() constants.ReportersMerge {
+	if d.MergeStrategy == "" {
+		return constants.DefaultReportersMerge
+	}
+	return d.MergeStrategy
 }
 
 type Frontend struct {
@@ -127,15 +174,18 @@ type Frontend struct {
 	Fields          []FieldConfig `yaml:"fields" json:"fields"`
 	DataSources     []string      `yaml:"dataSources" json:"dataSources"`
 	PromLabels      []string      `yaml:"promLabels" json:"promLabels"`
+	Scopes          []Scope       `yaml:"scopes,omitempty" json:"scopes,omitempty"`
 	MaxChunkAgeMs   int           `yaml:"maxChunkAgeMs,omitempty" json:"maxChunkAgeMs,omitempty"` // populated at query time
 }
 
 type Config struct {
-	Loki       Loki       `yaml:"loki" json:"loki"`
-	Prometheus Prometheus `yaml:"prometheus" json:"prometheus"`
-	Frontend   Frontend   `yaml:"frontend" json:"frontend"`
-	Server     Server     `yaml:"server,omitempty" json:"server,omitempty"`
-	Path       string     `yaml:"-" json:"-"`
+	Loki            Loki            `yaml:"loki" json:"loki"`
+	Prometheus      Prometheus      `yaml:"prometheus" json:"prometheus"`
+	Frontend        Frontend        `yaml:"frontend" json:"frontend"`
+	Server          Server          `yaml:"server,omitempty" json:"server,omitempty"`
+	SavedQueries    SavedQueries    `yaml:"savedQueries,omitempty" json:"savedQueries,omitempty"`
+	PodLabelFilters PodLabelFilters `yaml:"podLabelFilters,omitempty" json:"podLabelFilters,omitempty"`
+	Path            string          `yaml:"-" json:"-"`
 }
 
 func ReadFile(version, date, filename string) (*Config, error) {
@@ -150,11 +200,18 @@ func ReadFile(version, date, filename string) (*Config, error) {
 			AuthCheck:   "auto",
 		},
 		Loki: Loki{
-			Timeout: Duration{Duration: 30 * time.Second},
+			Timeout:      Duration{Duration: 30 * time.Second},
+			MaxURLLength: 8000,
 		},
 		Prometheus: Prometheus{
 			Timeout: Duration{Duration: 30 * time.Second},
 		},
+		SavedQueries: SavedQueries{
+			Store:         SavedQueriesFileStore,
+			FilePath:      "/tmp/saved-queries.json",
+			Namespace:     "netobserv",
+			ConfigMapName: "netobserv-saved-queries",
+		},
 		Frontend: Frontend{
 			BuildVersion: version,
 			BuildDate:    date,
@@ -169,13 +226,14 @@ func ReadFile(version, date, filename string) (*Config, error) {
 			QuickFilters: []QuickFilter{},
 			Features:     []string{},
 			Deduper: Deduper{
-				Mark:  false,
-				Merge: true,
+				Mark:          false,
+				Merge:         true,
+				MergeStrategy: constants.DefaultReportersMerge,
 			},
 			Fields: []FieldConfig{
-				{Name: "TimeFlowEndMs", Type: "number"},
-				{Name: "SrcAddr", Type: "string"},
-				{Name: "DstAddr", Type: "string"},
+				{Name: "TimeFlowEndMs", Type: FieldTypeNumber},
+				{Name: "SrcAddr", Type: FieldTypeIP},
+				{Name: "DstAddr", Type: FieldTypeIP},
 			},
 			DataSources: []string{},
 			PromLabels:  []string{},
@@ -188,13 +246,25 @@ func ReadFile(version, date, filename string) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	// default fields are only used by the frontend: filters on other fields are allowed unless fields are configured
+	defaultFields := cfg.Frontend.Fields
+	cfg.Frontend.Fields = nil
 	err = yaml.Unmarshal(yamlFile, &cfg)
 	if err != nil {
 		return nil, err
 	}
+	hasFields := len(cfg.Frontend.Fields) > 0
+	if !hasFields {
+		cfg.Frontend.Fields = defaultFields
+	}
+
+	cfg.Frontend.Scopes = mergeScopes(cfg.Frontend.Scopes)
 
 	if cfg.IsLokiEnabled() {
 		cfg.Frontend.DataSources = append(cfg.Frontend.DataSources, string(constants.DataSourceLoki))
+		if hasFields {
+			cfg.Loki.Fields = cfg.Frontend.Fields
+		}
 	}
 
 	if cfg.IsPromEnabled() {
@@ -259,10 +329,28 @@ func (c *Config) Validate() error {
 		if err != nil {
 			configErrors = append(configErrors, "wrong Prometheus URL")
 		}
+		if c.Prometheus.Discovery.Enable && c.Prometheus.ForwardUserToken && c.Prometheus.TokenPath == "" {
+			configErrors = append(configErrors, "Prometheus metrics discovery requires a tokenPath when forwardUserToken is set")
+		}
 	} else {
 		log.Info("Prometheus is disabled")
 	}
 
+	configErrors = append(configErrors, c.validateFields()...)
+	configErrors = append(configErrors, validateScopes(c.Frontend.GetScopes())...)
+
+	switch c.Frontend.Deduper.MergeStrategy {
+	case "", constants.ReportersMergeIngress, constants.ReportersMergeEgress, constants.ReportersMergeMax, constants.ReportersMergeNone:
+	default:
+		configErrors = append(configErrors, fmt.Sprintf("unknown deduper merge strategy '%s'", c.Frontend.Deduper.MergeStrategy))
+	}
+
+	switch c.SavedQueries.Store {
+	case "", SavedQueriesFileStore, SavedQueriesConfigMapStore:
+	default:
+		configErrors = append(configErrors, fmt.Sprintf("unknown saved queries store '%s'", c.SavedQueries.Store))
+	}
+
 	if len(configErrors) > 0 {
 		configErrors = append([]string{fmt.Sprintf("Config file has %d errors:\n", len(configErrors))}, configErrors...)
 		return errors.New(strings.Join(configErrors, "\n - "))
@@ -271,6 +359,18 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+func (c *Config) validateFields() []string {
+	var configErrors []string
+	for _, f := range c.Frontend.Fields {
+		switch f.Type {
+		case FieldTypeString, FieldTypeNumber, FieldTypeBoolean, FieldTypeIP, FieldTypeStringArray, FieldTypeNumberArray:
+		default:
+			configErrors = append(configErrors, fmt.Sprintf("unknown type '%s' for field %s", f.Type, f.Name))
+		}
+	}
+	return configErrors
+}
+
 func (c *Config) GetAuthChecker() (auth.Checker, error) {
 	// parse config auth
 	var checkType auth.CheckType
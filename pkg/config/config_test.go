@@ -0,0 +1,112 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readYAML reads a configuration from the given YAML content
+func readYAML(t *testing.T, content string) *Config {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	cfg, err := ReadFile("v0", "now", path)
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestReadFile_DefaultFields(t *testing.T) {
+	cfg := readYAML(t, `
+loki:
+  url: http://loki
+  labels: [SrcK8S_Namespace]
+`)
+	// default fields are sent to the frontend, but don't restrict filters
+	assert.Len(t, cfg.Frontend.Fields, 3)
+	assert.False(t, cfg.Loki.HasFields())
+}
+
+func TestReadFile_Fields(t *testing.T) {
+	cfg := readYAML(t, `
+loki:
+  url: http://loki
+  labels: [SrcK8S_Namespace]
+frontend:
+  fields:
+    - name: SrcK8S_Name
+      type: string
+      filter: src_name
+    - name: SrcAddr
+      type: ip
+`)
+	assert.True(t, cfg.Loki.HasFields())
+	assert.Equal(t, cfg.Frontend.Fields, cfg.Loki.Fields)
+
+	f, ok := cfg.Loki.GetField("src_name")
+	require.True(t, ok)
+	assert.Equal(t, "SrcK8S_Name", f.Name)
+	_, ok = cfg.Loki.GetField("DstK8S_Name")
+	assert.False(t, ok)
+	assert.True(t, cfg.Loki.IsIPField("SrcAddr"))
+	// not configured: built-in type
+	assert.True(t, cfg.Loki.IsIPField("DstAddr"))
+}
+
+func TestGetField_Concurrent(t *testing.T) {
+	l := Loki{Fields: []FieldConfig{{Name: "SrcK8S_Name", Type: FieldTypeString, Filter: "src_name"}}}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, ok := l.GetField("src_name")
+			assert.True(t, ok)
+			assert.Equal(t, "SrcK8S_Name", f.Name)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDeduperMergeStrategy(t *testing.T) {
+	cfg := readYAML(t, `
+loki:
+  url: http://loki
+  labels: [SrcK8S_Namespace]
+`)
+	assert.Equal(t, constants.ReportersMergeIngress, cfg.Frontend.Deduper.GetMergeStrategy())
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Frontend.Deduper.MergeStrategy = constants.ReportersMergeMax
+	assert.Equal(t, constants.ReportersMergeMax, cfg.Frontend.Deduper.GetMergeStrategy())
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Frontend.Deduper.MergeStrategy = ""
+	assert.Equal(t, constants.DefaultReportersMerge, cfg.Frontend.Deduper.GetMergeStrategy())
+
+	cfg.Frontend.Deduper.MergeStrategy = "sum"
+	assert.ErrorContains(t, cfg.Validate(), "unknown deduper merge strategy 'sum'")
+}
+
+func TestValidate_PrometheusDiscovery(t *testing.T) {
+	cfg := readYAML(t, `
+prometheus:
+  url: http://prometheus
+  forwardUserToken: true
+  discovery:
+    enable: true
+`)
+	// discovery doesn't run on behalf of a user
+	assert.ErrorContains(t, cfg.Validate(), "Prometheus metrics discovery requires a tokenPath when forwardUserToken is set")
+
+	cfg.Prometheus.TokenPath = "/var/run/secrets/token"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Prometheus.TokenPath = ""
+	cfg.Prometheus.Discovery.Enable = false
+	assert.NoError(t, cfg.Validate())
+}
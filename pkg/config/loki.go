@@ -1,6 +1,11 @@
 package config
 
-import "github.com/netobserv/network-observability-console-plugin/pkg/utils"
+import (
+	"sync"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils"
+)
 
 type Loki struct {
 	URL                string   `yaml:"url" json:"url"`
@@ -17,7 +22,12 @@ type Loki struct {
 	StatusUserKeyPath  string   `yaml:"statusUserKeyPath,omitempty" json:"statusUserKeyPath,omitempty"`
 	UseMocks           bool     `yaml:"useMocks,omitempty" json:"useMocks,omitempty"`
 	ForwardUserToken   bool     `yaml:"forwardUserToken,omitempty" json:"forwardUserToken,omitempty"`
-	labelsMap          map[string]struct{}
+	MaxURLLength       int      `yaml:"maxUrlLength,omitempty" json:"maxUrlLength,omitempty"` // above this length, queries are sent as POST; 0 means always GET
+	// Fields are copied from the frontend configuration, to compile filters according to their type
+	Fields     []FieldConfig `yaml:"-" json:"-"`
+	labelsMap  map[string]struct{}
+	fieldsOnce sync.Once
+	fieldsMap  map[string]*FieldConfig
 }
 
 func (l *Loki) GetStatusURL() string {
@@ -34,3 +44,38 @@ func (l *Loki) IsLabel(key string) bool {
 	_, isLabel := l.labelsMap[key]
 	return isLabel
 }
+
+// HasFields returns true when fields are configured, in which case filters on unknown fields are rejected
+func (l *Loki) HasFields() bool {
+	return len(l.Fields) > 0
+}
+
+// GetField returns the configured field matching a filter key, which is either a field name or the filter name of a field
+func (l *Loki) GetField(key string) (*FieldConfig, bool) {
+	// requests are served concurrently: the map is built once, fields not being modified after the configuration is read
+	l.fieldsOnce.Do(func() {
+		fieldsMap := make(map[string]*FieldConfig, len(l.Fields))
+		for i := range l.Fields {
+			f := &l.Fields[i]
+			if f.Filter != "" {
+				fieldsMap[f.Filter] = f
+			}
+		}
+		// field names take precedence over filter names
+		for i := range l.Fields {
+			fieldsMap[l.Fields[i].Name] = &l.Fields[i]
+		}
+		l.fieldsMap = fieldsMap
+	})
+	f, ok := l.fieldsMap[key]
+	return f, ok
+}
+
+// IsIPField returns true when the filter key is an IP field, according to its configured type or, when it is not
+// configured, to the built-in fields
+func (l *Loki) IsIPField(key string) bool {
+	if f, ok := l.GetField(key); ok {
+		return f.Type == FieldTypeIP
+	}
+	return fields.IsIP(key)
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+// ScopeLabel is a pair of labels of an aggregation scope, on the source and destination of flows. Labels common to
+// both, such as the cluster name, only have Src
+type ScopeLabel struct {
+	Src string `yaml:"src" json:"src"`
+	Dst string `yaml:"dst,omitempty" json:"dst,omitempty"`
+}
+
+// Scope is an aggregation scope of the topology, such as "namespace" or "subnet", flows being aggregated on its labels.
+// Group is the name nodes are grouped under in the groups parameter, such as "namespaces", when the scope can be used
+// for grouping. Parent is the scope whose labels are also needed to identify nodes, such as the namespace of owners
+type Scope struct {
+	Name   string       `yaml:"name" json:"name"`
+	Labels []ScopeLabel `yaml:"labels" json:"labels"`
+	Group  string       `yaml:"group,omitempty" json:"group,omitempty"`
+	Parent string       `yaml:"parent,omitempty" json:"parent,omitempty"`
+}
+
+// DefaultScopes are the built-in aggregation scopes; configured scopes are added to them, replacing those of the same name
+var DefaultScopes = []Scope{
+	{Name: "app", Labels: []ScopeLabel{{Src: "app"}}},
+	{Name: "droppedState", Labels: []ScopeLabel{{Src: "PktDropLatestState"}}},
+	{Name: "droppedCause", Labels: []ScopeLabel{{Src: "PktDropLatestDropCause"}}},
+	{Name: "dnsRCode", Labels: []ScopeLabel{{Src: "DnsFlagsResponseCode"}}},
+	{Name: "cluster", Labels: []ScopeLabel{{Src: "K8S_ClusterName"}}, Group: "clusters"},
+	{Name: "zone", Labels: []ScopeLabel{{Src: "SrcK8S_Zone", Dst: "DstK8S_Zone"}}, Group: "zones"},
+	{Name: "host", Labels: []ScopeLabel{{Src: "SrcK8S_HostName", Dst: "DstK8S_HostName"}}, Group: "hosts"},
+	{Name: "namespace", Labels: []ScopeLabel{{Src: "SrcK8S_Namespace", Dst: "DstK8S_Namespace"}}, Group: "namespaces"},
+	{
+		Name: "owner",
+		Labels: []ScopeLabel{
+			{Src: "SrcK8S_OwnerName", Dst: "DstK8S_OwnerName"},
+			{Src: "SrcK8S_OwnerType", Dst: "DstK8S_OwnerType"},
+		},
+		Group:  "owners",
+		Parent: "namespace",
+	},
+	{
+		Name: "resource",
+		Labels: []ScopeLabel{
+			{Src: "SrcK8S_Name", Dst: "DstK8S_Name"},
+			{Src: "SrcK8S_Type", Dst: "DstK8S_Type"},
+			{Src: "SrcK8S_OwnerName", Dst: "DstK8S_OwnerName"},
+			{Src: "SrcK8S_OwnerType", Dst: "DstK8S_OwnerType"},
+			{Src: "SrcK8S_Namespace", Dst: "DstK8S_Namespace"},
+			{Src: "SrcAddr", Dst: "DstAddr"},
+			{Src: "SrcK8S_HostName", Dst: "DstK8S_HostName"},
+		},
+	},
+}
+
+// GetScopes returns the aggregation scopes, defaulting to the built-in ones
+func (f *Frontend) GetScopes() []Scope {
+	if len(f.Scopes) == 0 {
+		return DefaultScopes
+	}
+	return f.Scopes
+}
+
+// mergeScopes adds the configured scopes to the default ones, replacing those of the same name
+func mergeScopes(configured []Scope) []Scope {
+	scopes := slices.Clone(DefaultScopes)
+	for _, s := range configured {
+		if i := slices.IndexFunc(scopes, func(d Scope) bool { return d.Name == s.Name }); i >= 0 {
+			scopes[i] = s
+		} else {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// FindScope returns the scope of the given name
+func FindScope(scopes []Scope, name string) (*Scope, bool) {
+	for i := range scopes {
+		if scopes[i].Name == name {
+			return &scopes[i], true
+		}
+	}
+	return nil, false
+}
+
+// GetLabels returns the labels of the scope, sources before destinations, followed by those of its parents
+func (s *Scope) GetLabels(scopes []Scope) []string {
+	var labels []string
+	for _, scope := range s.WithParents(scopes) {
+		labels = append(labels, scope.ownLabels()...)
+	}
+	return labels
+}
+
+// WithParents returns the scope followed by its parents, from the closest one
+func (s *Scope) WithParents(scopes []Scope) []*Scope {
+	var chain []*Scope
+	visited := map[string]bool{}
+	for scope, ok := s, true; ok && !visited[scope.Name]; scope, ok = FindScope(scopes, scope.Parent) {
+		visited[scope.Name] = true
+		chain = append(chain, scope)
+	}
+	return chain
+}
+
+// ownLabels returns the labels of the scope, without its parents: sources first, then destinations
+func (s *Scope) ownLabels() []string {
+	labels := make([]string, 0, 2*len(s.Labels))
+	for _, l := range s.Labels {
+		labels = append(labels, l.Src)
+	}
+	for _, l := range s.Labels {
+		if l.Dst != "" {
+			labels = append(labels, l.Dst)
+		}
+	}
+	return labels
+}
+
+// GetGroupLabels returns the labels of the scopes grouped by the groups parameter, such as "hosts+namespaces".
+// Parents are not included: groups are nested from the groups parameter
+func GetGroupLabels(scopes []Scope, groups string) []string {
+	var labels []string
+	names := strings.Split(groups, "+")
+	for i := range scopes {
+		s := &scopes[i]
+		if s.Group == "" || !slices.Contains(names, s.Group) {
+			continue
+		}
+		labels = append(labels, s.ownLabels()...)
+	}
+	return labels
+}
+
+func validateScopes(scopes []Scope) []string {
+	var configErrors []string
+	groups := map[string]string{}
+	for i := range scopes {
+		s := &scopes[i]
+		if s.Name == "" {
+			configErrors = append(configErrors, "scopes must have a name")
+			continue
+		}
+		if len(s.Labels) == 0 {
+			configErrors = append(configErrors, fmt.Sprintf("scope %s has no labels", s.Name))
+		}
+		for _, l := range s.Labels {
+			if l.Src == "" {
+				configErrors = append(configErrors, fmt.Sprintf("scope %s has a label without src", s.Name))
+				continue
+			}
+			// labels are written as is in queries
+			for _, label := range []string{l.Src, l.Dst} {
+				if label != "" && !filters.IsValidKey(label) {
+					configErrors = append(configErrors, fmt.Sprintf("invalid label '%s' for scope %s", label, s.Name))
+				}
+			}
+		}
+		if s.Parent != "" {
+			if _, ok := FindScope(scopes, s.Parent); !ok || s.Parent == s.Name {
+				configErrors = append(configErrors, fmt.Sprintf("unknown parent '%s' for scope %s", s.Parent, s.Name))
+			}
+		}
+		if s.Group != "" {
+			if other, ok := groups[s.Group]; ok {
+				configErrors = append(configErrors, fmt.Sprintf("group '%s' is used by scopes %s and %s", s.Group, other, s.Name))
+			}
+			groups[s.Group] = s.Name
+		}
+	}
+	return configErrors
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeScopes(t *testing.T) {
+	scopes := mergeScopes([]Scope{
+		{Name: "namespace", Labels: []ScopeLabel{{Src: "SrcK8S_Namespace", Dst: "DstK8S_Namespace"}}, Group: "projects"},
+		{Name: "subnet", Labels: []ScopeLabel{{Src: "SrcSubnetLabel", Dst: "DstSubnetLabel"}}, Group: "subnets"},
+	})
+
+	// default scopes are kept in order, replaced by those of the same name, and followed by the new ones
+	require.Len(t, scopes, len(DefaultScopes)+1)
+	ns, ok := FindScope(scopes, "namespace")
+	require.True(t, ok)
+	assert.Equal(t, "projects", ns.Group)
+	assert.Equal(t, "subnet", scopes[len(scopes)-1].Name)
+	ns, _ = FindScope(DefaultScopes, "namespace")
+	assert.Equal(t, "namespaces", ns.Group, "defaults must not be modified")
+
+	owner, _ := FindScope(scopes, "owner")
+	assert.Equal(t, []string{
+		"SrcK8S_OwnerName", "SrcK8S_OwnerType", "DstK8S_OwnerName", "DstK8S_OwnerType", "SrcK8S_Namespace", "DstK8S_Namespace",
+	}, owner.GetLabels(scopes))
+	assert.Equal(t, []string{"SrcK8S_Namespace", "DstK8S_Namespace", "SrcSubnetLabel", "DstSubnetLabel"}, GetGroupLabels(scopes, "projects+subnets"))
+}
+
+func TestValidateScopes(t *testing.T) {
+	assert.Empty(t, validateScopes(DefaultScopes))
+	assert.Empty(t, validateScopes(mergeScopes([]Scope{{Name: "subnet", Labels: []ScopeLabel{{Src: "SrcSubnetLabel", Dst: "DstSubnetLabel"}}, Group: "subnets"}})))
+
+	assert.Equal(t, []string{
+		"scopes must have a name",
+		"scope empty has no labels",
+		"scope nosrc has a label without src",
+		"invalid label 'Src})' for scope injected",
+		"invalid label 'Dst Label' for scope injected",
+		"unknown parent 'unknown' for scope orphan",
+		"unknown parent 'self' for scope self",
+		"group 'hosts' is used by scopes host and node",
+	}, validateScopes(append(DefaultScopes[:0:0],
+		Scope{Labels: []ScopeLabel{{Src: "app"}}},
+		Scope{Name: "empty"},
+		Scope{Name: "nosrc", Labels: []ScopeLabel{{Dst: "DstLabel"}}},
+		Scope{Name: "injected", Labels: []ScopeLabel{{Src: "Src})", Dst: "Dst Label"}}},
+		Scope{Name: "orphan", Labels: []ScopeLabel{{Src: "app"}}, Parent: "unknown"},
+		Scope{Name: "self", Labels: []ScopeLabel{{Src: "app"}}, Parent: "self"},
+		Scope{Name: "host", Labels: []ScopeLabel{{Src: "SrcK8S_HostName", Dst: "DstK8S_HostName"}}, Group: "hosts"},
+		Scope{Name: "node", Labels: []ScopeLabel{{Src: "SrcK8S_HostName", Dst: "DstK8S_HostName"}}, Group: "hosts"},
+	)))
+}
+
+func TestReadFile_Scopes(t *testing.T) {
+	cfg := readYAML(t, `
+loki:
+  url: http://loki
+  labels: [SrcK8S_Namespace]
+frontend:
+  scopes:
+    - name: subnet
+      labels:
+        - src: SrcSubnetLabel
+          dst: DstSubnetLabel
+      group: subnets
+`)
+	assert.Len(t, cfg.Frontend.GetScopes(), len(DefaultScopes)+1)
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Frontend.Scopes[len(cfg.Frontend.Scopes)-1].Labels[0].Src = "SrcSubnetLabel,"
+	assert.ErrorContains(t, cfg.Validate(), "invalid label 'SrcSubnetLabel,' for scope subnet")
+}
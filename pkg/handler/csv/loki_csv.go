@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/naming"
 	"github.com/netobserv/network-observability-console-plugin/pkg/utils"
 )
 
@@ -16,7 +17,8 @@ const (
 	receivedTimeCol = timePrefix + "Received"
 )
 
-func GetCSVData(qr *model.AggregatedQueryResponse, columns []string) ([][]string, error) {
+// GetCSVData returns the records as CSV rows. Ports and protocols are written with their names when known, as in the console
+func GetCSVData(qr *model.AggregatedQueryResponse, columns []string, names *naming.Names) ([][]string, error) {
 	if streams, ok := qr.Result.(model.Streams); ok { // make csv datas containing header as first line + rows
 		data := make([][]string, 1)
 
@@ -63,7 +65,7 @@ func GetCSVData(qr *model.AggregatedQueryResponse, columns []string) ([][]string
 					data[0] = append(data[0], fields...)
 				}
 
-				data = append(data, getRowDatas(stream, labels, fields, line, len(data[0])))
+				data = append(data, getRowDatas(stream, labels, fields, line, len(data[0]), names))
 			}
 		}
 		return data, nil
@@ -72,7 +74,7 @@ func GetCSVData(qr *model.AggregatedQueryResponse, columns []string) ([][]string
 }
 
 func getRowDatas(stream model.Stream, labels, fields []string,
-	line map[string]interface{}, size int) []string {
+	line map[string]interface{}, size int, names *naming.Names) []string {
 	rowDatas := make([]string, 0, size)
 
 	// set time columns
@@ -82,12 +84,20 @@ func getRowDatas(stream model.Stream, labels, fields []string,
 
 	// set labels values
 	for _, label := range labels {
-		rowDatas = append(rowDatas, stream.Labels[label])
+		if name, ok := names.FormatValue(label, stream.Labels[label]); ok {
+			rowDatas = append(rowDatas, name)
+		} else {
+			rowDatas = append(rowDatas, stream.Labels[label])
+		}
 	}
 
 	// set field values
 	for _, field := range fields {
-		rowDatas = append(rowDatas, fmt.Sprint(line[field]))
+		if name, ok := names.FormatValue(field, line[field]); ok {
+			rowDatas = append(rowDatas, name)
+		} else {
+			rowDatas = append(rowDatas, fmt.Sprint(line[field]))
+		}
 	}
 
 	return rowDatas
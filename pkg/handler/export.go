@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/naming"
 )
 
 const (
@@ -47,7 +48,7 @@ func (h *Handlers) ExportFlows(ctx context.Context) func(w http.ResponseWriter,
 		switch exportFormat {
 		case exportCSVFormat:
 			code = http.StatusOK
-			writeCSV(w, code, flows, exportColumns)
+			writeCSV(w, code, flows, exportColumns, naming.New(&h.Cfg.Frontend))
 		default:
 			code = http.StatusBadRequest
 			writeError(w, code, fmt.Sprintf("export format %q is not valid", exportFormat))
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/podlabels"
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/naming"
+)
+
+// filtersValidation is the result of a filters validation
+type filtersValidation struct {
+	Valid bool `json:"valid"`
+	// Normalized are the filters written in the requested syntax, with filter names resolved to field names.
+	// It can be used as is as filters parameter. It's only set when filters are valid.
+	Normalized string         `json:"normalized,omitempty"`
+	Syntax     string         `json:"syntax"`
+	Errors     []filterError  `json:"errors,omitempty"`
+	Clauses    []filterReport `json:"clauses"`
+}
+
+// filterError is either a syntax error, located by its position in expressions, or an invalid clause
+type filterError struct {
+	Message  string `json:"message"`
+	Position *int   `json:"position,omitempty"`
+	Group    *int   `json:"group,omitempty"`
+	Clause   *int   `json:"clause,omitempty"`
+}
+
+// filterReport tells how a clause is run by each data source. Group and Clause are the indexes of the clause
+// in the disjunctive form of the filters, where groups are OR'ed and clauses in a group are AND'ed
+type filterReport struct {
+	Group    int      `json:"group"`
+	Clause   int      `json:"clause"`
+	Field    string   `json:"field"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values"`
+	// Loki is how the clause is written in LogQL, unset if Loki is disabled or the clause is invalid
+	Loki             loki.FilterTarget `json:"loki,omitempty"`
+	Prometheus       bool              `json:"prometheus"`
+	PrometheusReason string            `json:"prometheusReason,omitempty"`
+}
+
+func (h *Handlers) ValidateFilters() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var code int
+		startTime := time.Now()
+		defer func() {
+			metrics.ObserveHTTPCall("ValidateFilters", code, startTime)
+		}()
+
+		params := r.URL.Query()
+		syntax := params.Get(filterSyntaxKey)
+		switch syntax {
+		case "":
+			syntax = filterSyntaxLegacy
+		case filterSyntaxLegacy, filterSyntaxExpression:
+		default:
+			code = http.StatusBadRequest
+			writeError(w, code, fmt.Sprintf("invalid filter syntax: %s", syntax))
+			return
+		}
+
+		code = http.StatusOK
+		writeJSON(w, code, h.validateFilters(params, syntax))
+	}
+}
+
+func (h *Handlers) validateFilters(params url.Values, syntax string) *filtersValidation {
+	res := filtersValidation{Syntax: syntax, Clauses: []filterReport{}}
+	// label selectors are not expanded, so that normalized filters still select pods by labels
+	groups, err := getFilters(params)
+	if err != nil {
+		fe := filterError{Message: err.Error()}
+		var parseErr *filters.ParseError
+		if errors.As(err, &parseErr) {
+			fe.Position = &parseErr.Pos
+		}
+		res.Errors = append(res.Errors, fe)
+		return &res
+	}
+	naming.New(&h.Cfg.Frontend).TranslateFilters(groups)
+
+	for g := range groups {
+		for c := range groups[g] {
+			report, errMsg := h.validateFilter(&groups[g][c])
+			report.Group, report.Clause = g, c
+			res.Clauses = append(res.Clauses, report)
+			if errMsg != "" {
+				res.Errors = append(res.Errors, filterError{Message: errMsg, Group: &report.Group, Clause: &report.Clause})
+			}
+		}
+	}
+	if len(res.Errors) > 0 {
+		return &res
+	}
+
+	res.Valid = true
+	if syntax == filterSyntaxLegacy {
+		if normalized, ok := filters.Format(groups); ok {
+			// legacy filters are URL-decoded when parsed
+			res.Normalized = url.QueryEscape(normalized)
+			return &res
+		}
+		res.Syntax = filterSyntaxExpression
+	}
+	res.Normalized = filters.FormatExpression(groups)
+	return &res
+}
+
+// validateFilter checks that the filter can be run by at least one of the enabled data sources, and reports how it's run.
+// The filter key is resolved to the field name. Returns an error message if the filter is invalid
+func (h *Handlers) validateFilter(m *filters.Match) (filterReport, string) {
+	report := filterReport{Field: m.Key, Operator: m.Operator(), Values: m.SplitValues()}
+	if keys, ok := podlabels.ExpandedKeys(m.Key); ok {
+		return h.validateSelectorFilter(m, keys, report)
+	}
+	if h.Cfg.IsLokiEnabled() {
+		field, target, err := loki.CheckFilter(&h.Cfg.Loki, *m)
+		if err != nil {
+			return report, err.Error()
+		}
+		m.Key = field
+		report.Field = field
+		report.Loki = target
+	}
+	if h.PromInventory == nil {
+		report.PrometheusReason = "Prometheus is disabled"
+	} else {
+		report.PrometheusReason = h.PromInventory.CheckFilter(&h.Cfg.Loki, *m)
+		report.Prometheus = report.PrometheusReason == ""
+	}
+	if report.Loki == "" && !report.Prometheus {
+		return report, fmt.Sprintf("filter on %s cannot be run without Loki: %s", m.Key, report.PrometheusReason)
+	}
+	return report, ""
+}
+
+// validateSelectorFilter checks a label selector filter, reported as the filters it's expanded into
+func (h *Handlers) validateSelectorFilter(m *filters.Match, expandedKeys []string, report filterReport) (filterReport, string) {
+	if h.PodLabels == nil {
+		return report, errLabelSelectorsDisabled.Error()
+	}
+	if _, err := podlabels.ParseSelectors(m); err != nil {
+		return report, err.Error()
+	}
+	report.Prometheus = true
+	for _, key := range expandedKeys {
+		expanded := filters.Match{Key: key, Values: `"name"`, Not: m.Not}
+		r, errMsg := h.validateFilter(&expanded)
+		if errMsg != "" {
+			return report, errMsg
+		}
+		if filterTargetCost[r.Loki] > filterTargetCost[report.Loki] {
+			report.Loki = r.Loki
+		}
+		if !r.Prometheus {
+			report.Prometheus = false
+			report.PrometheusReason = r.PrometheusReason
+		}
+	}
+	return report, ""
+}
+
+// filterTargetCost ranks how expensive filters are in LogQL
+var filterTargetCost = map[loki.FilterTarget]int{
+	loki.FilterTargetLabel: 1,
+	loki.FilterTargetLine:  2,
+	loki.FilterTargetJSON:  3,
+}
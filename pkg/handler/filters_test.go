@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/config"
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/podlabels"
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/prometheus"
+)
+
+func validateFiltersRequest(t *testing.T, hdl *Handlers, params url.Values) (int, filtersValidation) {
+	rec := httptest.NewRecorder()
+	hdl.ValidateFilters()(rec, httptest.NewRequest(http.MethodGet, "/api/filters/validate?"+params.Encode(), nil))
+	var res filtersValidation
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	}
+	return rec.Code, res
+}
+
+func TestValidateFilters(t *testing.T) {
+	hdl := Handlers{
+		Cfg: &config.Config{Loki: config.Loki{URL: "http://loki", Labels: []string{"SrcK8S_Namespace"}, Fields: []config.FieldConfig{
+			{Name: "SrcK8S_Namespace", Type: config.FieldTypeString},
+			{Name: "SrcK8S_Name", Type: config.FieldTypeString},
+			{Name: "SrcPort", Type: config.FieldTypeNumber, Filter: "src_port"},
+		}}},
+		PromInventory: prometheus.NewInventory(&config.Prometheus{Metrics: []config.MetricInfo{
+			{Enabled: true, Name: "netobserv_namespace_flows_total", ValueField: "", Labels: []string{"SrcK8S_Namespace"}},
+		}}),
+	}
+
+	code, res := validateFiltersRequest(t, &hdl, url.Values{filtersKey: {url.QueryEscape(`SrcK8S_Namespace="ns"&src_port=80|SrcK8S_Name=api`)}})
+	require.Equal(t, http.StatusOK, code)
+	assert.True(t, res.Valid)
+	assert.Empty(t, res.Errors)
+	assert.Equal(t, filterSyntaxLegacy, res.Syntax)
+	assert.Equal(t, url.QueryEscape(`SrcK8S_Namespace="ns"&SrcPort=80|SrcK8S_Name=api`), res.Normalized)
+	assert.Equal(t, []filterReport{
+		{Group: 0, Clause: 0, Field: "SrcK8S_Namespace", Operator: "=", Values: []string{`"ns"`}, Loki: loki.FilterTargetLabel, Prometheus: true},
+		{Group: 0, Clause: 1, Field: "SrcPort", Operator: "=", Values: []string{"80"}, Loki: loki.FilterTargetLine, PrometheusReason: "No enabled metric has the label SrcPort"},
+		{Group: 1, Clause: 0, Field: "SrcK8S_Name", Operator: "=", Values: []string{"api"}, Loki: loki.FilterTargetLine, PrometheusReason: "No enabled metric has the label SrcK8S_Name"},
+	}, res.Clauses)
+
+	// expression syntax
+	code, res = validateFiltersRequest(t, &hdl, url.Values{filtersKey: {`src_port in (80, 443) and not SrcK8S_Namespace = "ns"`}, filterSyntaxKey: {filterSyntaxExpression}})
+	require.Equal(t, http.StatusOK, code)
+	assert.True(t, res.Valid)
+	assert.Equal(t, `SrcPort IN (80, 443) AND SrcK8S_Namespace != "ns"`, res.Normalized)
+
+	// invalid clauses
+	code, res = validateFiltersRequest(t, &hdl, url.Values{filtersKey: {url.QueryEscape(`SrcK8S_Namespace="ns"|src_port=http&Foo=bar`)}})
+	require.Equal(t, http.StatusOK, code)
+	assert.False(t, res.Valid)
+	assert.Empty(t, res.Normalized)
+	require.Len(t, res.Errors, 2)
+	assert.Equal(t, "invalid numeric value for SrcPort in flows request: http", res.Errors[0].Message)
+	assert.Equal(t, 1, *res.Errors[0].Group)
+	assert.Equal(t, 0, *res.Errors[0].Clause)
+	assert.Equal(t, 1, *res.Errors[1].Clause)
+	assert.Len(t, res.Clauses, 3)
+
+	// syntax error
+	code, res = validateFiltersRequest(t, &hdl, url.Values{filtersKey: {`SrcPort = 80 and (`}, filterSyntaxKey: {filterSyntaxExpression}})
+	require.Equal(t, http.StatusOK, code)
+	assert.False(t, res.Valid)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, 18, *res.Errors[0].Position)
+	assert.Nil(t, res.Errors[0].Clause)
+
+	code, _ = validateFiltersRequest(t, &hdl, url.Values{filterSyntaxKey: {"sql"}})
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestValidateFilters_PrometheusOnly(t *testing.T) {
+	hdl := Handlers{
+		Cfg: &config.Config{},
+		PromInventory: prometheus.NewInventory(&config.Prometheus{Metrics: []config.MetricInfo{
+			{Enabled: true, Name: "netobserv_namespace_flows_total", ValueField: "", Labels: []string{"SrcK8S_Namespace"}},
+		}}),
+	}
+	_, res := validateFiltersRequest(t, &hdl, url.Values{filtersKey: {url.QueryEscape(`SrcK8S_Namespace="ns"&SrcPort=80`)}})
+	assert.False(t, res.Valid)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, "filter on SrcPort cannot be run without Loki: No enabled metric has the label SrcPort", res.Errors[0].Message)
+	assert.Equal(t, 1, *res.Errors[0].Clause)
+}
+
+func TestValidateFilters_Names(t *testing.T) {
+	hdl := Handlers{Cfg: &config.Config{
+		Loki: config.Loki{URL: "http://loki", Labels: []string{"SrcK8S_Namespace"}},
+		Frontend: config.Frontend{
+			PortNaming: config.PortNaming{Enable: true},
+			Columns:    []config.Column{{ID: "DstPort", Field: "DstPort", Filter: "dst_port"}},
+		},
+	}}
+	_, res := validateFiltersRequest(t, &hdl, url.Values{filtersKey: {`DstPort = "postgresql" and Proto in ("tcp", "UDP")`}, filterSyntaxKey: {filterSyntaxExpression}})
+	assert.True(t, res.Valid, res.Errors)
+	assert.Equal(t, `DstPort = 5432 AND Proto IN (6, 17)`, res.Normalized)
+}
+
+func TestValidateFilters_LabelSelectors(t *testing.T) {
+	hdl := Handlers{Cfg: &config.Config{Loki: config.Loki{URL: "http://loki", Labels: []string{"SrcK8S_Namespace", "SrcK8S_OwnerName"}}}}
+	params := url.Values{filtersKey: {`SrcK8S_LabelSelector = "app=checkout"`}, filterSyntaxKey: {filterSyntaxExpression}}
+
+	_, res := validateFiltersRequest(t, &hdl, params)
+	assert.False(t, res.Valid)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, errLabelSelectorsDisabled.Error(), res.Errors[0].Message)
+
+	hdl.PodLabels = podlabels.NewCache(nil)
+	_, res = validateFiltersRequest(t, &hdl, params)
+	assert.True(t, res.Valid, res.Errors)
+	assert.Equal(t, `SrcK8S_LabelSelector = "app=checkout"`, res.Normalized)
+	assert.Equal(t, loki.FilterTargetLabel, res.Clauses[0].Loki)
+
+	// reported as the costliest of the namespace and owner filters
+	params.Set(filtersKey, `DstK8S_LabelSelector = "app=checkout"`)
+	_, res = validateFiltersRequest(t, &hdl, params)
+	assert.True(t, res.Valid, res.Errors)
+	assert.Equal(t, loki.FilterTargetLine, res.Clauses[0].Loki)
+
+	params.Set(filtersKey, `DstK8S_LabelSelector = "app in checkout"`)
+	_, res = validateFiltersRequest(t, &hdl, params)
+	assert.False(t, res.Valid)
+	require.Len(t, res.Errors, 1)
+	assert.Contains(t, res.Errors[0].Message, "invalid label selector in DstK8S_LabelSelector filter")
+}
+
+func TestValidateFilters_Regexes(t *testing.T) {
+	hdl := Handlers{Cfg: &config.Config{Loki: config.Loki{URL: "http://loki", Labels: []string{"SrcK8S_Namespace"}}}}
+	_, res := validateFiltersRequest(t, &hdl, url.Values{filtersKey: {url.QueryEscape(`SrcK8S_Namespace=~kube-.*&SrcK8S_Name!~api-[0-9]{1,3}`)}})
+	assert.True(t, res.Valid, res.Errors)
+	assert.Equal(t, url.QueryEscape(`SrcK8S_Namespace=~kube-.*&SrcK8S_Name!~api-[0-9]{1,3}`), res.Normalized)
+	assert.Equal(t, []filterReport{
+		{Group: 0, Clause: 0, Field: "SrcK8S_Namespace", Operator: "=~", Values: []string{"kube-.*"}, Loki: loki.FilterTargetLabel, PrometheusReason: "Prometheus is disabled"},
+		{Group: 0, Clause: 1, Field: "SrcK8S_Name", Operator: "!~", Values: []string{"api-[0-9]{1,3}"}, Loki: loki.FilterTargetJSON, PrometheusReason: "Prometheus is disabled"},
+	}, res.Clauses)
+
+	_, res = validateFiltersRequest(t, &hdl, url.Values{filtersKey: {"SrcK8S_Name =~ `api-(`"}, filterSyntaxKey: {filterSyntaxExpression}})
+	assert.False(t, res.Valid)
+	require.Len(t, res.Errors, 1)
+	assert.Equal(t, "invalid filter expression at position 15: invalid regular expression: missing closing ): `api-(`", res.Errors[0].Message)
+	assert.Equal(t, 15, *res.Errors[0].Position)
+}
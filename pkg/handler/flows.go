@@ -12,7 +12,6 @@ import (
 	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
 	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model"
-	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
 	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
 )
 
@@ -26,6 +25,11 @@ const (
 	dataSourceKey = "dataSource"
 	filtersKey    = "filters"
 	packetLossKey = "packetLoss"
+
+	caseSensitiveKey       = "caseSensitive"
+	filterSyntaxKey        = "filterSyntax"
+	filterSyntaxLegacy     = "legacy"
+	filterSyntaxExpression = "expression"
 )
 
 func (h *Handlers) GetFlows(ctx context.Context) func(w http.ResponseWriter, r *http.Request) {
@@ -81,8 +85,7 @@ func (h *Handlers) getFlows(ctx context.Context, lokiClient httpclient.Caller, p
 	if err != nil {
 		return nil, http.StatusBadRequest, err
 	}
-	rawFilters := params.Get(filtersKey)
-	filterGroups, err := filters.Parse(rawFilters)
+	filterGroups, err := h.parseFilters(params)
 	if err != nil {
 		return nil, http.StatusBadRequest, err
 	}
@@ -28,6 +28,10 @@ func (h *Handlers) GetFrontendConfig() func(w http.ResponseWriter, r *http.Reque
 				cfg.Frontend.MaxChunkAgeMs = int(maxChunkAge.Milliseconds())
 			}
 		}
+		if h.PromInventory != nil {
+			// labels of the metrics in use, which may have been discovered
+			cfg.Frontend.PromLabels = h.PromInventory.EnabledLabels()
+		}
 		writeJSON(w, http.StatusOK, cfg.Frontend)
 	}
 }
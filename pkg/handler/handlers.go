@@ -2,10 +2,19 @@ package handler
 
 import (
 	"github.com/netobserv/network-observability-console-plugin/pkg/config"
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/podlabels"
+	"github.com/netobserv/network-observability-console-plugin/pkg/newpeers"
 	"github.com/netobserv/network-observability-console-plugin/pkg/prometheus"
+	"github.com/netobserv/network-observability-console-plugin/pkg/savedquery"
 )
 
 type Handlers struct {
-	Cfg           *config.Config
-	PromInventory *prometheus.Inventory
+	Cfg            *config.Config
+	PromInventory  *prometheus.Inventory
+	AuthChecker    auth.Checker
+	SavedQueries   savedquery.Store
+	PodLabels      *podlabels.Cache
+	NewPeers       *newpeers.Cache
+	ScrapeInterval *prometheus.ScrapeInterval
 }
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
+)
+
+const (
+	autoInterval = "auto"
+	// autoMaxPoints is the maximum number of points per series with the auto step
+	autoMaxPoints = 300
+	// autoDefaultRange is the time range queried when there is no start time, as in Loki
+	autoDefaultRange = time.Hour
+	// autoRateScrapes is the minimum number of scrapes covered by the auto rate interval
+	autoRateScrapes = 4
+)
+
+// autoSteps are the steps picked by the auto step, the smallest one giving at most autoMaxPoints. Above them,
+// whole days are used
+var autoSteps = []time.Duration{
+	15 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	2 * time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	2 * time.Hour,
+	3 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+	24 * time.Hour,
+}
+
+// resolveAutoIntervals returns the params with the auto step and rate interval replaced by the values derived from
+// the time range and, when Prometheus may be queried, from its scrape interval
+func (h *Handlers) resolveAutoIntervals(ctx context.Context, cl clients, params url.Values, ds constants.DataSource) (url.Values, error) {
+	autoStep := params.Get(stepKey) == autoInterval
+	autoRate := params.Get(rateIntervalKey) == autoInterval
+	if !autoStep && !autoRate {
+		return params, nil
+	}
+
+	var scrapeInterval time.Duration
+	if ds != constants.DataSourceLoki && cl.prom != nil && h.ScrapeInterval != nil {
+		scrapeInterval = h.ScrapeInterval.Get(ctx, cl.prom)
+	}
+	resolved := url.Values{}
+	for k, v := range params {
+		resolved[k] = v
+	}
+
+	var step time.Duration
+	if autoStep {
+		_, sTime, err := getStartTime(params)
+		if err != nil {
+			return nil, err
+		}
+		_, eTime, err := getEndTime(params)
+		if err != nil {
+			return nil, err
+		}
+		timeRange := autoDefaultRange
+		if !sTime.IsZero() {
+			timeRange = eTime.Sub(sTime)
+		}
+		step = getAutoStep(timeRange, scrapeInterval)
+		resolved.Set(stepKey, formatInterval(step))
+	} else {
+		var err error
+		if _, step, err = getStep(params); err != nil {
+			return nil, err
+		}
+	}
+	if autoRate {
+		resolved.Set(rateIntervalKey, formatInterval(getAutoRateInterval(step, scrapeInterval)))
+	}
+	return resolved, nil
+}
+
+// getAutoStep returns the smallest step giving at most autoMaxPoints over the time range, and not below the scrape
+// interval, as there is no more data in between
+func getAutoStep(timeRange, scrapeInterval time.Duration) time.Duration {
+	minStep := timeRange / autoMaxPoints
+	if minStep < scrapeInterval {
+		minStep = scrapeInterval
+	}
+	for _, step := range autoSteps {
+		if step >= minStep {
+			return step
+		}
+	}
+	day := autoSteps[len(autoSteps)-1]
+	return (minStep + day - 1) / day * day
+}
+
+// getAutoRateInterval returns the rate interval covering each step; with Prometheus, it also covers at least
+// autoRateScrapes scrapes and the step plus a scrape, so that rates are computed on enough samples
+func getAutoRateInterval(step, scrapeInterval time.Duration) time.Duration {
+	if scrapeInterval == 0 {
+		return step
+	}
+	rateInterval := step + scrapeInterval
+	if minInterval := autoRateScrapes * scrapeInterval; rateInterval < minInterval {
+		rateInterval = minInterval
+	}
+	return rateInterval
+}
+
+// formatInterval formats a duration such as it's read both by Go and by Loki or Prometheus, e.g. "5m" or "90s"
+func formatInterval(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+}
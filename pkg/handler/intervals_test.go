@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
+)
+
+func TestGetAutoStep(t *testing.T) {
+	// Loki
+	assert.Equal(t, 15*time.Second, getAutoStep(5*time.Minute, 0))
+	assert.Equal(t, 15*time.Second, getAutoStep(time.Hour, 0))
+	assert.Equal(t, time.Minute, getAutoStep(5*time.Hour, 0))
+	assert.Equal(t, 5*time.Minute, getAutoStep(24*time.Hour, 0))
+	assert.Equal(t, time.Hour, getAutoStep(7*24*time.Hour, 0))
+	assert.Equal(t, 48*time.Hour, getAutoStep(365*24*time.Hour, 0))
+
+	// Prometheus: not below the scrape interval
+	assert.Equal(t, time.Minute, getAutoStep(time.Hour, time.Minute))
+	assert.Equal(t, 5*time.Minute, getAutoStep(24*time.Hour, 30*time.Second))
+}
+
+func TestGetAutoRateInterval(t *testing.T) {
+	// Loki
+	assert.Equal(t, 5*time.Minute, getAutoRateInterval(5*time.Minute, 0))
+
+	// Prometheus: at least 4 scrapes, or the step and a scrape
+	assert.Equal(t, 2*time.Minute, getAutoRateInterval(30*time.Second, 30*time.Second))
+	assert.Equal(t, 5*time.Minute+30*time.Second, getAutoRateInterval(5*time.Minute, 30*time.Second))
+}
+
+func TestFormatInterval(t *testing.T) {
+	assert.Equal(t, "15s", formatInterval(15*time.Second))
+	assert.Equal(t, "90s", formatInterval(90*time.Second))
+	assert.Equal(t, "5m", formatInterval(5*time.Minute))
+	assert.Equal(t, "48h", formatInterval(48*time.Hour))
+}
+
+func TestResolveAutoIntervals(t *testing.T) {
+	h := Handlers{}
+	params := url.Values{
+		startTimeKey:    []string{"1700000000"},
+		endTimeKey:      []string{"1700086399"},
+		stepKey:         []string{autoInterval},
+		rateIntervalKey: []string{autoInterval},
+		aggregateByKey:  []string{"namespace"},
+	}
+	resolved, err := h.resolveAutoIntervals(context.TODO(), clients{}, params, constants.DataSourceAuto)
+	require.NoError(t, err)
+	assert.Equal(t, "5m", resolved.Get(stepKey))
+	assert.Equal(t, "5m", resolved.Get(rateIntervalKey))
+	assert.Equal(t, "namespace", resolved.Get(aggregateByKey))
+	// params are not modified
+	assert.Equal(t, autoInterval, params.Get(stepKey))
+
+	// rate interval only
+	params = url.Values{
+		stepKey:         []string{"1m"},
+		rateIntervalKey: []string{autoInterval},
+	}
+	resolved, err = h.resolveAutoIntervals(context.TODO(), clients{}, params, constants.DataSourceAuto)
+	require.NoError(t, err)
+	assert.Equal(t, "1m", resolved.Get(stepKey))
+	assert.Equal(t, "1m", resolved.Get(rateIntervalKey))
+
+	// without start time, the default time range is used
+	params = url.Values{
+		stepKey: []string{autoInterval},
+	}
+	resolved, err = h.resolveAutoIntervals(context.TODO(), clients{}, params, constants.DataSourceAuto)
+	require.NoError(t, err)
+	assert.Equal(t, "15s", resolved.Get(stepKey))
+	assert.Empty(t, resolved.Get(rateIntervalKey))
+}
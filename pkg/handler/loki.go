@@ -5,11 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
+	pmodel "github.com/prometheus/common/model"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 
@@ -21,6 +23,7 @@ import (
 	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
 )
 
 type LokiError struct {
@@ -62,7 +65,7 @@ func newLokiClient(cfg *config.Loki, requestHeader http.Header, useStatusConfig
 
 	if cfg.UseMocks {
 		hlog.Debug("Mocking Loki Client")
-		return new(lokiclientmock.LokiClientMock)
+		return &lokiCaller{Caller: new(lokiclientmock.LokiClientMock), maxURLLength: cfg.MaxURLLength}
 	}
 
 	skipTLS := cfg.SkipTLS
@@ -76,16 +79,42 @@ func newLokiClient(cfg *config.Loki, requestHeader http.Header, useStatusConfig
 		userKeyPath = cfg.StatusUserKeyPath
 	}
 
-	return httpclient.NewClientWrapper(cfg.Timeout.Duration, headers, skipTLS, caPath, userCertPath, userKeyPath)
+	return &lokiCaller{
+		Caller:       httpclient.NewClientWrapper(cfg.Timeout.Duration, headers, skipTLS, caPath, userCertPath, userKeyPath),
+		maxURLLength: cfg.MaxURLLength,
+	}
+}
+
+// lokiCaller sends GET queries as form-encoded POST when their URL exceeds maxURLLength,
+// to avoid hitting URL length limits with long LogQL
+type lokiCaller struct {
+	httpclient.Caller
+	maxURLLength int
 }
 
-/* loki query will fail if spaces or quotes are not encoded
- * we can't use url.QueryEscape or url.Values here since Loki doesn't manage encoded parenthesis
- */
-func EncodeQuery(url string) string {
-	unquoted := strings.ReplaceAll(url, "\"", "%22")
-	unspaced := strings.ReplaceAll(unquoted, " ", "%20")
-	return unspaced
+func (c *lokiCaller) Get(rawURL string) ([]byte, int, error) {
+	if c.maxURLLength > 0 && len(rawURL) > c.maxURLLength {
+		path, form, err := splitQueryURL(rawURL)
+		if err == nil {
+			hlog.Debugf("URL length %d exceeds %d, using POST on %s", len(rawURL), c.maxURLLength, path)
+			return c.Caller.Post(path, form)
+		}
+		hlog.WithError(err).Warn("cannot convert query URL to form, falling back to GET")
+	}
+	return c.Caller.Get(rawURL)
+}
+
+// splitQueryURL splits an URL into its path and form parameters
+func splitQueryURL(rawURL string) (string, url.Values, error) {
+	path, rawQuery, found := strings.Cut(rawURL, "?")
+	if !found {
+		return "", nil, fmt.Errorf("no query parameters in URL: %s", rawURL)
+	}
+	form, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, form, nil
 }
 
 func getLokiError(resp []byte, code int) (int, string) {
@@ -140,10 +169,20 @@ func executeLokiQuery(flowsURL string, lokiClient httpclient.Caller) ([]byte, in
 	return resp, http.StatusOK, nil
 }
 
-func getLokiLabelValues(baseURL string, lokiClient httpclient.Caller, label string) ([]string, int, error) {
-	baseURL = strings.TrimRight(baseURL, "/")
-	url := fmt.Sprintf("%s/loki/api/v1/label/%s/values", baseURL, label)
-	hlog.Debugf("getLokiLabelValues URL: %s", url)
+func getLokiLabelValues(cfg *config.Loki, lokiClient httpclient.Caller, label string, rq *resourceQuery, filts filters.SingleQuery) ([]string, int, error) {
+	qb := loki.NewFlowQueryBuilder(cfg, rq.start, rq.end, "", false, "", constants.PacketLossAll)
+	if err := qb.Filters(filts); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if qb.IsStreamSelectorOnly() {
+		return fetchLokiLabelValues(qb.BuildLabelValues(label), lokiClient)
+	}
+	// Filters that are not labels can't be used with the label values API: count by the label instead
+	return fetchLokiCountByValues(qb.BuildCountBy(label, rq.lookback()), label, lokiClient)
+}
+
+func fetchLokiLabelValues(url string, lokiClient httpclient.Caller) ([]string, int, error) {
+	hlog.Debugf("fetchLokiLabelValues URL: %s", url)
 
 	resp, code, err := lokiClient.Get(url)
 	if err != nil {
@@ -153,7 +192,7 @@ func getLokiLabelValues(baseURL string, lokiClient httpclient.Caller, label stri
 		newCode, msg := getLokiError(resp, code)
 		return nil, newCode, errors.New(msg)
 	}
-	hlog.Tracef("getLokiLabelValues raw response: %s", resp)
+	hlog.Tracef("fetchLokiLabelValues raw response: %s", resp)
 	var lvr model.LabelValuesResponse
 	err = json.Unmarshal(resp, &lvr)
 	if err != nil {
@@ -162,18 +201,28 @@ func getLokiLabelValues(baseURL string, lokiClient httpclient.Caller, label stri
 	return lvr.Data, http.StatusOK, nil
 }
 
-func getLokiNamesForPrefix(cfg *config.Loki, lokiClient httpclient.Caller, filts filters.SingleQuery, searchField string) ([]string, int, error) {
-	queryBuilder := loki.NewFlowQueryBuilderWithDefaults(cfg)
+func getLokiNamesForPrefix(cfg *config.Loki, lokiClient httpclient.Caller, rq *resourceQuery, filts filters.SingleQuery, searchField string) ([]string, int, error) {
+	queryBuilder := loki.NewFlowQueryBuilder(cfg, rq.start, rq.end, "", false, constants.RecordTypeLog, constants.PacketLossAll)
 	if err := queryBuilder.Filters(filts); err != nil {
 		return nil, http.StatusBadRequest, err
 	}
 
-	query := queryBuilder.Build()
+	if cfg.IsLabel(searchField) && queryBuilder.IsStreamSelectorOnly() {
+		// Indexed field and filters: use the label values API
+		return fetchLokiLabelValues(queryBuilder.BuildLabelValues(searchField), lokiClient)
+	}
+
+	// Else, run a metric query counting by the searched field, so that Loki returns distinct values only
+	return fetchLokiCountByValues(queryBuilder.BuildCountBy(searchField, rq.lookback()), searchField, lokiClient)
+}
+
+// fetchLokiCountByValues runs a metric query counting by a field, and returns the distinct values of that field
+func fetchLokiCountByValues(query, field string, lokiClient httpclient.Caller) ([]string, int, error) {
 	resp, code, err := executeLokiQuery(query, lokiClient)
 	if err != nil {
 		return nil, code, errors.New("Loki query failed: " + err.Error())
 	}
-	hlog.Tracef("GetNames raw response: %s", resp)
+	hlog.Tracef("fetchLokiCountByValues raw response: %s", resp)
 
 	var qr model.QueryResponse
 	err = json.Unmarshal(resp, &qr)
@@ -182,12 +231,17 @@ func getLokiNamesForPrefix(cfg *config.Loki, lokiClient httpclient.Caller, filts
 		return nil, http.StatusInternalServerError, errors.New("Failed to unmarshal Loki response: " + err.Error())
 	}
 
-	streams, ok := qr.Data.Result.(model.Streams)
+	vector, ok := qr.Data.Result.(model.Vector)
 	if !ok {
 		return nil, http.StatusInternalServerError, errors.New("Loki returned unexpected type: " + string(qr.Data.ResultType))
 	}
 
-	values := extractDistinctValues(searchField, streams)
+	var values []string
+	for _, sample := range vector {
+		if v := string(sample.Metric[pmodel.LabelName(field)]); len(v) > 0 {
+			values = append(values, v)
+		}
+	}
 	return values, http.StatusOK, nil
 }
 
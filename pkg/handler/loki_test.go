@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"net/url"
 	"testing"
 	"time"
 
@@ -46,3 +47,35 @@ func TestFetchMaxChunkAge_Absent(t *testing.T) {
 	// Default value
 	assert.Equal(t, 2*time.Hour, mca)
 }
+
+func TestLokiCaller_ShortURLUsesGet(t *testing.T) {
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	lokiClientMock.On("Get", "http://loki/loki/api/v1/query_range?query={app=%22netobserv-flowcollector%22}").Return([]byte(`{}`), 200, nil)
+	cl := lokiCaller{Caller: lokiClientMock, maxURLLength: 100}
+	_, _, err := cl.Get("http://loki/loki/api/v1/query_range?query={app=%22netobserv-flowcollector%22}")
+	require.NoError(t, err)
+
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
+	lokiClientMock.AssertNumberOfCalls(t, "Post", 0)
+}
+
+func TestLokiCaller_LongURLUsesPost(t *testing.T) {
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	var path string
+	var form url.Values
+	lokiClientMock.SpyPost(func(u string, f url.Values) {
+		path = u
+		form = f
+	})
+	cl := lokiCaller{Caller: lokiClientMock, maxURLLength: 50}
+	_, _, _ = cl.Get("http://loki/loki/api/v1/query_range?query={app=%22netobserv-flowcollector%22}|json|SrcAddr=ip(%2210.0.0.1%22)+or+SrcAddr=ip(%2210.0.0.2%22)&start=1640991600&limit=50")
+
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 0)
+	lokiClientMock.AssertNumberOfCalls(t, "Post", 1)
+	assert.Equal(t, "http://loki/loki/api/v1/query_range", path)
+	assert.Equal(t, url.Values{
+		"query": []string{`{app="netobserv-flowcollector"}|json|SrcAddr=ip("10.0.0.1") or SrcAddr=ip("10.0.0.2")`},
+		"start": []string{"1640991600"},
+		"limit": []string{"50"},
+	}, form)
+}
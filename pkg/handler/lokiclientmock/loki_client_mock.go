@@ -1,6 +1,7 @@
 package lokiclientmock
 
 import (
+	"net/url"
 	"os"
 	"strings"
 
@@ -12,37 +13,45 @@ var mlog = logrus.WithField("module", "lokiclientmock")
 type LokiClientMock struct {
 }
 
-func (o *LokiClientMock) Get(url string) ([]byte, int, error) {
+func (o *LokiClientMock) Get(rawURL string) ([]byte, int, error) {
 	var path string
-	mlog.Debugf("Get url: %s", url)
+	mlog.Debugf("Get url: %s", rawURL)
+	// match against the decoded query
+	query, err := url.QueryUnescape(rawURL)
+	if err != nil {
+		return nil, 400, err
+	}
 
-	isLabel := strings.Contains(url, "/label/")
+	isLabel := strings.Contains(query, "/label/")
+	//nolint:gocritic // if-else is ok
 	if isLabel {
 		path = "mocks/loki/namespaces.json"
+	} else if strings.Contains(query, "query=count by") {
+		path = "mocks/loki/names.json"
 	} else {
-		if strings.Contains(url, "query=topk") {
+		if strings.Contains(query, "query=topk") {
 			path = "mocks/loki/flow_metrics"
 
-			if strings.Contains(url, "|unwrap%20PktDrop") {
+			if strings.Contains(query, "|unwrap PktDrop") {
 				path += "_dropped"
 			}
 
 			//nolint:gocritic // if-else is ok
-			if strings.Contains(url, "by(app)") {
+			if strings.Contains(query, "by(app)") {
 				path += "_app.json"
-			} else if strings.Contains(url, "by(PktDropLatestState)") {
+			} else if strings.Contains(query, "by(PktDropLatestState)") {
 				path += "_state.json"
-			} else if strings.Contains(url, "by(PktDropLatestDropCause)") {
+			} else if strings.Contains(query, "by(PktDropLatestDropCause)") {
 				path += "_cause.json"
-			} else if strings.Contains(url, "by(K8S_ClusterName)") {
+			} else if strings.Contains(query, "by(K8S_ClusterName)") {
 				path += "_cluster.json"
-			} else if strings.Contains(url, "by(SrcK8S_Zone,DstK8S_Zone)") {
+			} else if strings.Contains(query, "by(SrcK8S_Zone,DstK8S_Zone)") {
 				path += "zone.json"
-			} else if strings.Contains(url, "by(SrcK8S_HostName,DstK8S_HostName)") {
+			} else if strings.Contains(query, "by(SrcK8S_HostName,DstK8S_HostName)") {
 				path += "_host.json"
-			} else if strings.Contains(url, "by(SrcK8S_Namespace,DstK8S_Namespace)") {
+			} else if strings.Contains(query, "by(SrcK8S_Namespace,DstK8S_Namespace)") {
 				path += "_namespace.json"
-			} else if strings.Contains(url, "by(SrcK8S_OwnerName,SrcK8S_OwnerType,DstK8S_OwnerName,DstK8S_OwnerType,SrcK8S_Namespace,DstK8S_Namespace)") {
+			} else if strings.Contains(query, "by(SrcK8S_OwnerName,SrcK8S_OwnerType,DstK8S_OwnerName,DstK8S_OwnerType,SrcK8S_Namespace,DstK8S_Namespace)") {
 				path += "_owner.json"
 			} else {
 				path += "_resource.json"
@@ -50,11 +59,11 @@ func (o *LokiClientMock) Get(url string) ([]byte, int, error) {
 		} else {
 			path = "mocks/loki/flow_records"
 			//nolint:gocritic // if-else is ok
-			if strings.Contains(url, "|~`\"Packets\":0[,}]|~`\"PktDropPackets\":[1-9][0-9]*[,}]") {
+			if strings.Contains(query, "|~`\"Packets\":0[,}]|~`\"PktDropPackets\":[1-9][0-9]*[,}]") {
 				path += "_dropped.json"
-			} else if strings.Contains(url, "|~`\"PktDropPackets\":[1-9][0-9]*[,}]") {
+			} else if strings.Contains(query, "|~`\"PktDropPackets\":[1-9][0-9]*[,}]") {
 				path += "_has_dropped.json"
-			} else if strings.Contains(url, "|~`\"PktDropPackets\":0[,}]") {
+			} else if strings.Contains(query, "|~`\"PktDropPackets\":0[,}]") {
 				path += "_sent.json"
 			} else {
 				path += ".json"
@@ -70,3 +79,9 @@ func (o *LokiClientMock) Get(url string) ([]byte, int, error) {
 
 	return []byte(file), 200, nil
 }
+
+// Post rebuilds the GET URL from the form, so that the same mock files are used for both methods
+func (o *LokiClientMock) Post(url string, form url.Values) ([]byte, int, error) {
+	mlog.Debugf("Post url: %s, form: %v", url, form)
+	return o.Get(url + "?" + form.Encode())
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	pmodel "github.com/prometheus/common/model"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+	"github.com/netobserv/network-observability-console-plugin/pkg/newpeers"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
+)
+
+const (
+	windowKey   = "window"
+	baselineKey = "baseline"
+
+	defaultNewPeersWindow   = time.Hour
+	defaultNewPeersBaseline = 7 * 24 * time.Hour
+	defaultNewPeersLimit    = "1000"
+	// maxStepsPerQuery keeps queries far below the Loki limit of points per series
+	maxStepsPerQuery = 1000
+)
+
+// newPeersRun is a query of the edges: between owners, or between owners and external addresses
+type newPeersRun struct {
+	aggregateBy string
+	groups      string
+	// noOwner is the field required to be empty, for external addresses
+	noOwner string
+}
+
+var newPeersRuns = []newPeersRun{
+	{aggregateBy: "owner"},
+	{aggregateBy: fields.DstAddr, groups: "namespaces+owners", noOwner: fields.DstOwnerName},
+	{aggregateBy: fields.SrcAddr, groups: "namespaces+owners", noOwner: fields.SrcOwnerName},
+}
+
+// GetNewPeers returns the edges between owners, or between owners and external IPs, of the recent window that
+// were not seen in the baseline window before it. Baselines are cached, and only extended on subsequent calls
+func (h *Handlers) GetNewPeers(ctx context.Context) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clients, err := newClients(h.Cfg, r.Header, false)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		var code int
+		startTime := time.Now()
+		defer func() {
+			metrics.ObserveHTTPCall("GetNewPeers", code, startTime)
+		}()
+
+		params := r.URL.Query()
+		hlog.Debugf("GetNewPeers query params: %s", params)
+		resp, code, err := h.getNewPeers(ctx, clients, params, r.Header.Get("Authorization"))
+		if err != nil {
+			writeError(w, code, err.Error())
+			return
+		}
+		code = http.StatusOK
+		writeJSON(w, code, resp)
+	}
+}
+
+func (h *Handlers) getNewPeers(ctx context.Context, cl clients, params url.Values, token string) (*newpeers.Response, int, error) {
+	ds, err := getDatasource(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	window, err := getPositiveDuration(params, windowKey, defaultNewPeersWindow)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	baselineDuration, err := getPositiveDuration(params, baselineKey, defaultNewPeersBaseline)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	filterGroups, err := h.parseFilters(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	now := time.Now()
+	recentStart := now.Add(-window)
+	key := newpeers.Key(token, string(ds), params.Get(filtersKey), params.Get(filterSyntaxKey), params.Get(caseSensitiveKey), baselineDuration.String())
+	baseline := h.NewPeers.Get(key)
+	if baseline == nil || baseline.End.After(recentStart) || baseline.Start.Before(recentStart.Add(-2*baselineDuration)) {
+		// learn the whole baseline when there is none, when it overlaps the recent window, or when it's outdated
+		baseline = newpeers.NewBaseline(recentStart.Add(-baselineDuration))
+	}
+	if recentStart.Sub(baseline.End) >= defaultStepDuration {
+		// query the time not covered yet: the whole baseline, or the time since it was last extended
+		matrices, code, err := h.queryNewPeersEdges(ctx, cl, params, ds, filterGroups, baseline.End, recentStart)
+		if err != nil {
+			return nil, code, fmt.Errorf("baseline query failed: %w", err)
+		}
+		baseline = baseline.Extend(recentStart, matrices...)
+		h.NewPeers.Put(key, baseline)
+	}
+
+	matrices, code, err := h.queryNewPeersEdges(ctx, cl, params, ds, filterGroups, recentStart, now)
+	if err != nil {
+		return nil, code, err
+	}
+	return &newpeers.Response{
+		Peers:         baseline.NewPeers(matrices...),
+		BaselineStart: baseline.Start.Unix(),
+		BaselineEnd:   baseline.End.Unix(),
+		BaselineEdges: baseline.Len(),
+		UnixTimestamp: now.Unix(),
+	}, http.StatusOK, nil
+}
+
+// queryNewPeersEdges runs the edge queries on the time window, as bytes per step. Rates are queried from either
+// datasource, computed over the whole step so that no traffic between two steps is missed, then multiplied by the
+// step. Note that the limit applies to each step: on long windows such as the baseline, edges smaller than the top
+// ones of every step are not seen
+func (h *Handlers) queryNewPeersEdges(
+	ctx context.Context,
+	cl clients,
+	params url.Values,
+	ds constants.DataSource,
+	filterGroups filters.MultiQueries,
+	start, end time.Time,
+) ([]model.Matrix, int, error) {
+	step := end.Sub(start) / maxStepsPerQuery
+	if step < defaultStepDuration {
+		step = defaultStepDuration
+	}
+	var matrices []model.Matrix
+	for _, run := range newPeersRuns {
+		// end time is ceiled to the next second when read
+		runParams := url.Values{
+			startTimeKey:      []string{strconv.FormatInt(start.Unix(), 10)},
+			endTimeKey:        []string{strconv.FormatInt(end.Unix()-1, 10)},
+			stepKey:           []string{pmodel.Duration(step).String()},
+			rateIntervalKey:   []string{pmodel.Duration(step).String()},
+			metricTypeKey:     []string{constants.MetricTypeBytes},
+			metricFunctionKey: []string{string(constants.MetricFunctionRate)},
+			aggregateByKey:    []string{run.aggregateBy},
+			groupsKey:         []string{run.groups},
+			limitKey:          []string{defaultNewPeersLimit},
+			filtersKey:        []string{filters.FormatExpression(run.withFilters(filterGroups))},
+			filterSyntaxKey:   []string{filterSyntaxExpression},
+			caseSensitiveKey:  []string{params.Get(caseSensitiveKey)},
+		}
+		if limit := params.Get(limitKey); limit != "" {
+			runParams.Set(limitKey, limit)
+		}
+		flows, code, err := h.getTopologyFlowsWithFallback(ctx, cl, runParams, ds)
+		if err != nil {
+			return nil, code, err
+		}
+		matrix, _ := flows.Result.(model.Matrix)
+		// rates are per second, over each step
+		for i := range matrix {
+			for j := range matrix[i].Values {
+				matrix[i].Values[j].Value *= pmodel.SampleValue(step.Seconds())
+			}
+		}
+		matrices = append(matrices, matrix)
+	}
+	return matrices, http.StatusOK, nil
+}
+
+// withFilters returns the filter groups of the run, requiring an empty owner for external addresses
+func (run *newPeersRun) withFilters(groups filters.MultiQueries) filters.MultiQueries {
+	if run.noOwner == "" {
+		return groups
+	}
+	noOwner := filters.NewMatch(run.noOwner, `""`)
+	if len(groups) == 0 {
+		return filters.MultiQueries{{noOwner}}
+	}
+	withNoOwner := make(filters.MultiQueries, 0, len(groups))
+	for _, group := range groups {
+		withNoOwner = append(withNoOwner, append(filters.SingleQuery{noOwner}, group...))
+	}
+	return withNoOwner
+}
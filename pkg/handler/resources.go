@@ -2,15 +2,20 @@ package handler
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	pmodel "github.com/prometheus/common/model"
 
+	"github.com/netobserv/network-observability-console-plugin/pkg/config"
 	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
-	"github.com/netobserv/network-observability-console-plugin/pkg/model"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
 	"github.com/netobserv/network-observability-console-plugin/pkg/prometheus"
@@ -30,8 +35,15 @@ func (h *Handlers) GetClusters(ctx context.Context) func(w http.ResponseWriter,
 			metrics.ObserveHTTPCall("GetClusters", code, startTime)
 		}()
 
+		rq, err := h.getResourceQuery(r.URL.Query())
+		if err != nil {
+			code = http.StatusBadRequest
+			writeError(w, code, err.Error())
+			return
+		}
+
 		// Fetch and merge values for K8S_ClusterName
-		values, code, err := h.getLabelValues(ctx, clients, fields.Cluster)
+		values, code, err := h.getLabelValues(ctx, clients, fields.Cluster, rq)
 		if err != nil {
 			writeError(w, code, "Error while fetching label cluster values from Loki: "+err.Error())
 			return
@@ -55,18 +67,25 @@ func (h *Handlers) GetZones(ctx context.Context) func(w http.ResponseWriter, r *
 			metrics.ObserveHTTPCall("GetZones", code, startTime)
 		}()
 
+		rq, err := h.getResourceQuery(r.URL.Query())
+		if err != nil {
+			code = http.StatusBadRequest
+			writeError(w, code, err.Error())
+			return
+		}
+
 		// Initialize values explicitly to avoid null json when empty
 		values := []string{}
 
 		// Fetch and merge values for SrcK8S_Zone and DstK8S_Zone
-		values1, code, err := h.getLabelValues(ctx, clients, fields.SrcZone)
+		values1, code, err := h.getLabelValues(ctx, clients, fields.SrcZone, rq)
 		if err != nil {
 			writeError(w, code, "Error while fetching label source zone values from Loki: "+err.Error())
 			return
 		}
 		values = append(values, values1...)
 
-		values2, code, err := h.getLabelValues(ctx, clients, fields.DstZone)
+		values2, code, err := h.getLabelValues(ctx, clients, fields.DstZone, rq)
 		if err != nil {
 			writeError(w, code, "Error while fetching label destination zone values from Loki: "+err.Error())
 			return
@@ -91,18 +110,25 @@ func (h *Handlers) GetNamespaces(ctx context.Context) func(w http.ResponseWriter
 			metrics.ObserveHTTPCall("GetNamespaces", code, startTime)
 		}()
 
+		rq, err := h.getResourceQuery(r.URL.Query())
+		if err != nil {
+			code = http.StatusBadRequest
+			writeError(w, code, err.Error())
+			return
+		}
+
 		// Initialize values explicitly to avoid null json when empty
 		values := []string{}
 
 		// Fetch and merge values for SrcK8S_Namespace and DstK8S_Namespace
-		values1, code, err := h.getLabelValues(ctx, clients, fields.SrcNamespace)
+		values1, code, err := h.getLabelValues(ctx, clients, fields.SrcNamespace, rq)
 		if err != nil {
 			writeError(w, code, "Error while fetching label source namespace values from Loki: "+err.Error())
 			return
 		}
 		values = append(values, values1...)
 
-		values2, code, err := h.getLabelValues(ctx, clients, fields.DstNamespace)
+		values2, code, err := h.getLabelValues(ctx, clients, fields.DstNamespace, rq)
 		if err != nil {
 			writeError(w, code, "Error while fetching label destination namespace values from Loki: "+err.Error())
 			return
@@ -114,17 +140,112 @@ func (h *Handlers) GetNamespaces(ctx context.Context) func(w http.ResponseWriter
 	}
 }
 
-func (h *Handlers) getLabelValues(ctx context.Context, cl clients, label string) ([]string, int, error) {
-	if h.PromInventory != nil && h.PromInventory.LabelExists(label) {
-		return prometheus.GetLabelValues(ctx, cl.prom, label, nil)
+const (
+	namePrefixKey = "prefix"
+
+	// defaultNamesLookback is used for names lookup metric queries when no start time is provided
+	defaultNamesLookback = time.Hour
+)
+
+// resourceQuery holds the optional time range and filters used to scope label values and names lookups
+type resourceQuery struct {
+	start        string
+	end          string
+	startTime    time.Time
+	endTime      time.Time
+	filterGroups filters.MultiQueries
+}
+
+// getResourceQuery reads the optional time range and filters of label values and names requests. Filters are
+// translated and expanded the same way as in flows requests
+func (h *Handlers) getResourceQuery(params url.Values) (*resourceQuery, error) {
+	rq := resourceQuery{}
+	var err error
+	rq.start, rq.startTime, err = getStartTime(params)
+	if err != nil {
+		return nil, err
+	}
+	rq.end, rq.endTime, err = getEndTime(params)
+	if err != nil {
+		return nil, err
+	}
+	if !rq.startTime.IsZero() && rq.startTime.After(rq.endTime) {
+		return nil, fmt.Errorf("start time %s is after end time %s", rq.start, rq.end)
+	}
+	rq.filterGroups, err = h.parseFilters(params)
+	if err != nil {
+		return nil, err
+	}
+	return &rq, nil
+}
+
+// groups returns the filter groups, with at least one (possibly empty) group
+func (rq *resourceQuery) groups() filters.MultiQueries {
+	if len(rq.filterGroups) == 0 {
+		return filters.MultiQueries{nil}
+	}
+	return rq.filterGroups
+}
+
+// lookback returns the query range duration, formatted for LogQL range vectors
+func (rq *resourceQuery) lookback() string {
+	if rq.startTime.IsZero() {
+		return pmodel.Duration(defaultNamesLookback).String()
+	}
+	return pmodel.Duration(rq.endTime.Sub(rq.startTime)).String()
+}
+
+func (h *Handlers) getLabelValues(ctx context.Context, cl clients, label string, rq *resourceQuery) ([]string, int, error) {
+	if h.PromInventory != nil && h.PromInventory.LabelExists(label) && h.promSupportsFilters(rq.groups()) {
+		return prometheus.GetLabelValues(ctx, cl.prom, label, promMatchers(&h.Cfg.Loki, rq.groups()), rq.startTime, rq.endTime)
 	}
 	if h.Cfg.IsLokiEnabled() {
-		return getLokiLabelValues(h.Cfg.Loki.URL, cl.loki, label)
+		var values []string
+		for _, group := range rq.groups() {
+			v, code, err := getLokiLabelValues(&h.Cfg.Loki, cl.loki, label, rq, group)
+			if err != nil {
+				return nil, code, err
+			}
+			values = append(values, v...)
+		}
+		return values, http.StatusOK, nil
 	}
 	// Loki disabled AND label not managed in metrics => send an error
 	return nil, http.StatusBadRequest, fmt.Errorf("label %s not found in Prometheus metrics", label)
 }
 
+// promSupportsFilters checks that every filter key is available as a label in Prometheus metrics
+func (h *Handlers) promSupportsFilters(groups filters.MultiQueries) bool {
+	if h.PromInventory == nil {
+		return false
+	}
+	for _, group := range groups {
+		labels, unsupportedReason := prometheus.FiltersToLabels(&h.Cfg.Loki, group)
+		if unsupportedReason != "" {
+			return false
+		}
+		for _, label := range labels {
+			if !h.PromInventory.LabelExists(label) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// promMatchers converts filter groups into a list of series selectors, to be used as match[] parameters (OR'ed)
+func promMatchers(cfg *config.Loki, groups filters.MultiQueries) []string {
+	var match []string
+	for _, group := range groups {
+		if len(group) == 0 {
+			// a group without any filter matches every series
+			return nil
+		}
+		match = append(match, prometheus.QueryFilters(cfg, "", group))
+	}
+	return match
+}
+
 func (h *Handlers) GetNames(ctx context.Context) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		clients, err := newClients(h.Cfg, r.Header, false)
@@ -137,34 +258,54 @@ func (h *Handlers) GetNames(ctx context.Context) func(w http.ResponseWriter, r *
 		defer func() {
 			metrics.ObserveHTTPCall("GetNames", code, startTime)
 		}()
+
+		rq, err := h.getResourceQuery(r.URL.Query())
+		if err != nil {
+			code = http.StatusBadRequest
+			writeError(w, code, err.Error())
+			return
+		}
 		params := mux.Vars(r)
 		namespace := params["namespace"]
 		kind := params["kind"]
+		namePrefix := r.URL.Query().Get(namePrefixKey)
 
 		// Initialize names explicitly to avoid null json when empty
 		names := []string{}
 
-		// TODO: parallelize
-		names1, code, err := h.getNamesForPrefix(ctx, clients, fields.Src, kind, namespace)
-		if err != nil {
-			writeError(w, code, err.Error())
-			return
+		// Run source and destination lookups in parallel
+		type namesResult struct {
+			names []string
+			code  int
+			err   error
+		}
+		results := make([]namesResult, 2)
+		var wg sync.WaitGroup
+		for i, prefix := range []string{fields.Src, fields.Dst} {
+			wg.Add(1)
+			go func(i int, prefix string) {
+				defer wg.Done()
+				names, code, err := h.getNamesForPrefix(ctx, clients, prefix, kind, namespace, namePrefix, rq)
+				results[i] = namesResult{names: names, code: code, err: err}
+			}(i, prefix)
 		}
-		names = append(names, names1...)
+		wg.Wait()
 
-		names2, code, err := h.getNamesForPrefix(ctx, clients, fields.Dst, kind, namespace)
-		if err != nil {
-			writeError(w, code, err.Error())
-			return
+		for _, res := range results {
+			if res.err != nil {
+				code = res.code
+				writeError(w, code, res.err.Error())
+				return
+			}
+			names = append(names, res.names...)
 		}
-		names = append(names, names2...)
 
 		code = http.StatusOK
 		writeJSON(w, code, utils.NonEmpty(utils.Dedup(names)))
 	}
 }
 
-func (h *Handlers) getNamesForPrefix(ctx context.Context, cl clients, prefix, kind, namespace string) ([]string, int, error) {
+func (h *Handlers) getNamesForPrefix(ctx context.Context, cl clients, prefix, kind, namespace, namePrefix string, rq *resourceQuery) ([]string, int, error) {
 	filts := filters.SingleQuery{}
 	if namespace != "" {
 		filts = append(filts, filters.NewMatch(prefix+fields.Namespace, exact(namespace)))
@@ -177,41 +318,51 @@ func (h *Handlers) getNamesForPrefix(ctx context.Context, cl clients, prefix, ki
 		filts = append(filts, filters.NewMatch(prefix+fields.Type, exact(kind)))
 		searchField = prefix + fields.Name
 	}
-
-	if h.Cfg.IsPromEnabled() {
-		// Label match query (any metric)
-		q := prometheus.QueryFilters("", filts)
-		return prometheus.GetLabelValues(ctx, cl.prom, searchField, []string{q})
+	if namePrefix != "" {
+		// "starts with", case sensitive: the prefix is quoted so that stars or commas it contains are literals
+		filts = append(filts, filters.NewRegexMatch(searchField, filters.EscapeValue(regexp.QuoteMeta(namePrefix)+".*")))
 	}
-	return getLokiNamesForPrefix(&h.Cfg.Loki, cl.loki, filts, searchField)
-}
 
-func exact(str string) string {
-	return `"` + str + `"`
-}
+	// Combine the resource filters with each group of user filters
+	var groups filters.MultiQueries
+	for _, group := range rq.groups() {
+		groups = append(groups, append(slices.Clone(filts), group...))
+	}
 
-func extractDistinctValues(field string, streams model.Streams) []string {
 	var values []string
-	for _, stream := range streams {
-		if v, ok := stream.Labels[field]; ok {
-			if len(v) > 0 {
-				values = append(values, v)
-			}
-		} else {
-			for _, entry := range stream.Entries {
-				var line map[string]interface{}
-				err := json.Unmarshal([]byte(entry.Line), &line)
-				if err != nil {
-					hlog.Errorf("Could not unmarshal line: %v. Error was: %v", entry.Line, err.Error())
-					continue
-				}
-				if v, ok := line[field]; ok {
-					if str, ok := v.(string); ok && len(str) > 0 {
-						values = append(values, str)
-					}
-				}
+	if h.Cfg.IsPromEnabled() && (!h.Cfg.IsLokiEnabled() || h.promSupportsFilters(groups)) {
+		// Label match query (any metric)
+		var code int
+		var err error
+		values, code, err = prometheus.GetLabelValues(ctx, cl.prom, searchField, promMatchers(&h.Cfg.Loki, groups), rq.startTime, rq.endTime)
+		if err != nil {
+			return nil, code, err
+		}
+	} else {
+		for _, group := range groups {
+			v, code, err := getLokiNamesForPrefix(&h.Cfg.Loki, cl.loki, rq, group, searchField)
+			if err != nil {
+				return nil, code, err
 			}
+			values = append(values, v...)
 		}
 	}
-	return utils.Dedup(values)
+	return filterPrefix(values, namePrefix), http.StatusOK, nil
+}
+
+func filterPrefix(values []string, prefix string) []string {
+	if prefix == "" {
+		return values
+	}
+	var filtered []string
+	for _, v := range values {
+		if strings.HasPrefix(v, prefix) {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
+}
+
+func exact(str string) string {
+	return `"` + filters.EscapeValue(str) + `"`
 }
@@ -2,12 +2,23 @@ package handler
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/config"
 	"github.com/netobserv/network-observability-console-plugin/pkg/httpclient/httpclienttest"
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/podlabels"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
 )
 
 var h = Handlers{Cfg: &config.Config{Loki: config.Loki{
@@ -17,17 +28,22 @@ var h = Handlers{Cfg: &config.Config{Loki: config.Loki{
 
 const testLokiBaseURL = "http://loki/loki/api/v1/"
 
+// lokiQueryURL returns the expected Loki URL for the given API path and LogQL query
+func lokiQueryURL(path, logQL string) string {
+	return testLokiBaseURL + path + "?query=" + url.QueryEscape(logQL)
+}
+
 func TestGetSourceOwnerNames(t *testing.T) {
 	lokiClientMock := new(httpclienttest.HTTPClientMock)
 	lokiClientMock.SpyURL(func(url string) {
 		assert.Equal(
 			t,
-			testLokiBaseURL+"query_range?query={app=\"netobserv-flowcollector\",_RecordType=\"flowLog\",SrcK8S_Namespace=\"default\"}|~`SrcK8S_OwnerType\":\"Deployment\"`",
+			lokiQueryURL("query", "count by(SrcK8S_OwnerName)(count_over_time({app=\"netobserv-flowcollector\",_RecordType=\"flowLog\",SrcK8S_Namespace=\"default\"}|~`SrcK8S_OwnerType\":\"Deployment\"`[1h]))"),
 			url,
 		)
 	})
 	cl := clients{loki: lokiClientMock}
-	_, _, _ = h.getNamesForPrefix(context.Background(), cl, "Src", "Deployment", "default")
+	_, _, _ = h.getNamesForPrefix(context.Background(), cl, "Src", "Deployment", "default", "", &resourceQuery{})
 
 	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
 }
@@ -37,12 +53,12 @@ func TestGetDestPodNames(t *testing.T) {
 	lokiClientMock.SpyURL(func(url string) {
 		assert.Equal(
 			t,
-			testLokiBaseURL+"query_range?query={app=\"netobserv-flowcollector\",_RecordType=\"flowLog\",DstK8S_Namespace=\"default\"}|~`DstK8S_Type\":\"Pod\"`",
+			lokiQueryURL("query", "count by(DstK8S_Name)(count_over_time({app=\"netobserv-flowcollector\",_RecordType=\"flowLog\",DstK8S_Namespace=\"default\"}|~`DstK8S_Type\":\"Pod\"`|~`\"DstK8S_Name\"`|json[1h]))"),
 			url,
 		)
 	})
 	cl := clients{loki: lokiClientMock}
-	_, _, _ = h.getNamesForPrefix(context.Background(), cl, "Dst", "Pod", "default")
+	_, _, _ = h.getNamesForPrefix(context.Background(), cl, "Dst", "Pod", "default", "", &resourceQuery{})
 
 	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
 }
@@ -52,12 +68,139 @@ func TestGetSourceNodeNames(t *testing.T) {
 	lokiClientMock.SpyURL(func(url string) {
 		assert.Equal(
 			t,
-			testLokiBaseURL+"query_range?query={app=\"netobserv-flowcollector\",_RecordType=\"flowLog\"}|~`SrcK8S_Type\":\"Node\"`",
+			lokiQueryURL("query", "count by(SrcK8S_Name)(count_over_time({app=\"netobserv-flowcollector\",_RecordType=\"flowLog\"}|~`SrcK8S_Type\":\"Node\"`|~`\"SrcK8S_Name\"`|json[1h]))"),
+			url,
+		)
+	})
+	cl := clients{loki: lokiClientMock}
+	_, _, _ = h.getNamesForPrefix(context.Background(), cl, "Src", "Node", "", "", &resourceQuery{})
+
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
+}
+
+func TestGetSourceOwnerNames_TimeAndFilters(t *testing.T) {
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	lokiClientMock.SpyURL(func(url string) {
+		assert.Equal(
+			t,
+			lokiQueryURL("query", "count by(SrcK8S_OwnerName)(count_over_time({app=\"netobserv-flowcollector\",_RecordType=\"flowLog\",SrcK8S_Namespace=\"default\",DstK8S_Namespace=\"other\"}|~`SrcK8S_OwnerType\":\"Deployment\"`[1d23h1s]))")+"&time=1641160801",
 			url,
 		)
 	})
 	cl := clients{loki: lokiClientMock}
-	_, _, _ = h.getNamesForPrefix(context.Background(), cl, "Src", "Node", "")
+	rq, err := h.getResourceQuery(url.Values{
+		"startTime": []string{"1640991600"},
+		"endTime":   []string{"1641160800"},
+		"filters":   []string{`DstK8S_Namespace="other"`},
+	})
+	require.NoError(t, err)
+	_, _, _ = h.getNamesForPrefix(context.Background(), cl, "Src", "Deployment", "default", "", rq)
+
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
+}
+
+func TestGetResourceQuery_InvalidTimeRange(t *testing.T) {
+	_, err := h.getResourceQuery(url.Values{
+		"startTime": []string{"1641160800"},
+		"endTime":   []string{"1640991600"},
+	})
+	assert.EqualError(t, err, "start time 1641160800 is after end time 1640991601")
+
+	// the request is rejected
+	rec := httptest.NewRecorder()
+	h.GetClusters(context.Background())(rec, httptest.NewRequest(http.MethodGet, "/api/resources/clusters?startTime=1641160800&endTime=1640991600", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// a single second is a valid range
+	_, err = h.getResourceQuery(url.Values{
+		"startTime": []string{"1641160800"},
+		"endTime":   []string{"1641160800"},
+	})
+	assert.NoError(t, err)
+}
+
+func TestGetResourceQuery_TranslatedFilters(t *testing.T) {
+	hn := Handlers{Cfg: &config.Config{Frontend: config.Frontend{PortNaming: config.PortNaming{Enable: true}}}}
+	rq, err := hn.getResourceQuery(url.Values{"filters": []string{`Proto=udp&DstPort=postgresql`}})
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{{
+		filters.NewMatch("Proto", "17"),
+		filters.NewMatch("DstPort", "5432"),
+	}}, rq.filterGroups)
+}
+
+func TestGetResourceQuery_LabelSelectors(t *testing.T) {
+	params := url.Values{filtersKey: {`SrcK8S_LabelSelector = "app=checkout"`}, filterSyntaxKey: {filterSyntaxExpression}}
+	hdl := Handlers{Cfg: h.Cfg}
+	_, err := hdl.getResourceQuery(params)
+	require.ErrorIs(t, err, errLabelSelectorsDisabled)
+
+	controller := true
+	hdl.PodLabels = podlabels.NewCache(fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:       "shop",
+		Name:            "checkout-0",
+		Labels:          map[string]string{"app": "checkout"},
+		OwnerReferences: []metav1.OwnerReference{{Kind: "StatefulSet", Name: "checkout", Controller: &controller}},
+	}}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hdl.PodLabels.Start(ctx)
+	require.Eventually(t, func() bool {
+		_, err := hdl.PodLabels.Match(nil)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	rq, err := hdl.getResourceQuery(params)
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{{
+		filters.NewMatch("SrcK8S_Namespace", `"shop"`),
+		filters.NewMatch("SrcK8S_OwnerName", `"checkout"`),
+	}}, rq.filterGroups)
+}
+
+func TestGetSourceOwnerNames_LabelsAPI(t *testing.T) {
+	hl := Handlers{Cfg: &config.Config{Loki: config.Loki{
+		URL:    "http://loki",
+		Labels: []string{"SrcK8S_Namespace", "SrcK8S_OwnerName", "SrcK8S_OwnerType"},
+	}}}
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	lokiClientMock.On("Get", lokiQueryURL("label/SrcK8S_OwnerName/values", "{app=\"netobserv-flowcollector\",SrcK8S_Namespace=\"default\",SrcK8S_OwnerType=\"Deployment\",SrcK8S_OwnerName=~\"loki.*\"}")).
+		Return([]byte(`{"status":"success","data":["loki","loki-gateway"]}`), 200, nil)
+	cl := clients{loki: lokiClientMock}
+	names, _, err := hl.getNamesForPrefix(context.Background(), cl, "Src", "Deployment", "default", "loki", &resourceQuery{})
+	require.NoError(t, err)
+
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
+	assert.Equal(t, []string{"loki", "loki-gateway"}, names)
+}
+
+func TestGetDestPodNames_Prefix(t *testing.T) {
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	lokiClientMock.On("Get", lokiQueryURL("query", "count by(DstK8S_Name)(count_over_time({app=\"netobserv-flowcollector\",_RecordType=\"flowLog\",DstK8S_Namespace=\"default\"}|~`DstK8S_Type\":\"Pod\"`|~`\"DstK8S_Name\"`|json|DstK8S_Name=~\"loki.*\"[1h]))")).
+		Return([]byte(`{"status":"success","data":{"resultType":"vector","result":[`+
+			`{"metric":{"DstK8S_Name":"loki-0"},"value":[1641160801,"12"]},`+
+			`{"metric":{"DstK8S_Name":"loki-1"},"value":[1641160801,"5"]}`+
+			`]}}`), 200, nil)
+	cl := clients{loki: lokiClientMock}
+	names, _, err := h.getNamesForPrefix(context.Background(), cl, "Dst", "Pod", "default", "loki", &resourceQuery{})
+	require.NoError(t, err)
+
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
+	assert.Equal(t, []string{"loki-0", "loki-1"}, names)
+}
+
+func TestGetSourceOwnerNames_EscapedPrefix(t *testing.T) {
+	hl := Handlers{Cfg: &config.Config{Loki: config.Loki{
+		URL:    "http://loki",
+		Labels: []string{"SrcK8S_Namespace", "SrcK8S_OwnerName", "SrcK8S_OwnerType"},
+	}}}
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	// stars and commas of the prefix are literals
+	lokiClientMock.On("Get", lokiQueryURL("label/SrcK8S_OwnerName/values", "{app=\"netobserv-flowcollector\",SrcK8S_Namespace=\"default\",SrcK8S_OwnerType=\"Deployment\",SrcK8S_OwnerName=~\"a\\\\*b,c.*\"}")).
+		Return([]byte(`{"status":"success","data":[]}`), 200, nil)
+	cl := clients{loki: lokiClientMock}
+	_, _, err := hl.getNamesForPrefix(context.Background(), cl, "Src", "Deployment", "default", "a*b,c", &resourceQuery{})
+	require.NoError(t, err)
 
 	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
 }
@@ -67,12 +210,54 @@ func TestGetLabelValues(t *testing.T) {
 	lokiClientMock.SpyURL(func(url string) {
 		assert.Equal(
 			t,
-			testLokiBaseURL+"label/DstK8S_Namespace/values",
+			lokiQueryURL("label/DstK8S_Namespace/values", "{app=\"netobserv-flowcollector\"}"),
 			url,
 		)
 	})
 	cl := clients{loki: lokiClientMock}
-	_, _, _ = h.getLabelValues(context.Background(), cl, "DstK8S_Namespace")
+	_, _, _ = h.getLabelValues(context.Background(), cl, "DstK8S_Namespace", &resourceQuery{})
 
 	lokiClientMock.AssertNumberOfCalls(t, "Get", 1)
 }
+
+func TestGetLabelValues_TimeAndFilters(t *testing.T) {
+	lokiClientMock := new(httpclienttest.HTTPClientMock)
+	var urls []string
+	lokiClientMock.On("Get", mock.AnythingOfType("string")).
+		Run(func(args mock.Arguments) { urls = append(urls, args[0].(string)) }).
+		Return([]byte(`{"status":"success","data":[]}`), 200, nil).Once()
+	lokiClientMock.On("Get", mock.AnythingOfType("string")).
+		Run(func(args mock.Arguments) { urls = append(urls, args[0].(string)) }).
+		Return([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"DstK8S_Namespace":"ns"},"value":[1641160801,"3"]}]}}`), 200, nil).Once()
+	cl := clients{loki: lokiClientMock}
+	rq, err := h.getResourceQuery(url.Values{
+		"startTime": []string{"1640991600"},
+		"endTime":   []string{"1641160800"},
+		// Non-label filters cannot be used in stream selectors: a metric query is used instead
+		"filters": []string{`SrcK8S_Namespace="bar"|SrcK8S_OwnerName="foo"&SrcPort=80`},
+	})
+	require.NoError(t, err)
+	values, _, err := h.getLabelValues(context.Background(), cl, "DstK8S_Namespace", rq)
+	require.NoError(t, err)
+
+	lokiClientMock.AssertNumberOfCalls(t, "Get", 2)
+	assert.Equal(t, []string{
+		lokiQueryURL("label/DstK8S_Namespace/values", "{app=\"netobserv-flowcollector\",SrcK8S_Namespace=\"bar\"}") + "&start=1640991600&end=1641160801",
+		lokiQueryURL("query", "count by(DstK8S_Namespace)(count_over_time({app=\"netobserv-flowcollector\",SrcK8S_OwnerName=\"foo\"}|~`SrcPort\":80[,}]`[1d23h1s]))") + "&time=1641160801",
+	}, urls)
+	assert.Equal(t, []string{"ns"}, values)
+}
+
+func TestPromMatchers(t *testing.T) {
+	groups, err := filters.Parse(`SrcK8S_Namespace="a"&DstK8S_Namespace="b"|SrcK8S_Namespace="c"`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`{SrcK8S_Namespace="a",DstK8S_Namespace="b"}`,
+		`{SrcK8S_Namespace="c"}`,
+	}, promMatchers(&config.Loki{}, groups))
+
+	// An empty group matches anything
+	groups, err = filters.Parse(`SrcK8S_Namespace="a"|`)
+	require.NoError(t, err)
+	assert.Nil(t, promMatchers(&config.Loki{}, groups))
+}
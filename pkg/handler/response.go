@@ -9,6 +9,7 @@ import (
 
 	csvdata "github.com/netobserv/network-observability-console-plugin/pkg/handler/csv"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/naming"
 )
 
 const codePrometheusUnsupported = 901 // code to use internally to notify a Bad Request, unsupported for prometheus queries
@@ -38,8 +39,8 @@ func writeJSON(w http.ResponseWriter, code int, payload interface{}) {
 	}
 }
 
-func writeCSV(w http.ResponseWriter, code int, qr *model.AggregatedQueryResponse, columns []string) {
-	data, err := csvdata.GetCSVData(qr, columns)
+func writeCSV(w http.ResponseWriter, code int, qr *model.AggregatedQueryResponse, columns []string, names *naming.Names) {
+	data, err := csvdata.GetCSVData(qr, columns, names)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/metrics"
+	"github.com/netobserv/network-observability-console-plugin/pkg/savedquery"
+)
+
+const maxSavedQueryBodySize = 1 << 20
+
+// savedQueryInput contains the attributes that can be set by users, others are managed by the backend
+type savedQueryInput struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	Visibility  savedquery.Visibility `json:"visibility"`
+	Namespace   string                `json:"namespace"`
+	Params      savedquery.Params     `json:"params"`
+}
+
+// sharedQuery is the response of a share link: the saved params, also encoded as API query parameters
+type sharedQuery struct {
+	Name   string            `json:"name"`
+	Params savedquery.Params `json:"params"`
+	Query  string            `json:"query"`
+}
+
+func (h *Handlers) GetSavedQueries(ctx context.Context) func(w http.ResponseWriter, r *http.Request) {
+	return h.withSavedQueries(ctx, "GetSavedQueries", func(w http.ResponseWriter, r *http.Request, user string) int {
+		queries, err := h.SavedQueries.List(ctx)
+		if err != nil {
+			return writeSavedQueryError(w, err)
+		}
+		// namespace access is checked once per namespace
+		nsAccess := map[string]bool{}
+		visible := []savedquery.SavedQuery{}
+		for i := range queries {
+			if h.canReadSavedQuery(ctx, r, user, &queries[i], nsAccess) {
+				visible = append(visible, queries[i])
+			}
+		}
+		writeJSON(w, http.StatusOK, visible)
+		return http.StatusOK
+	})
+}
+
+func (h *Handlers) GetSavedQuery(ctx context.Context) func(w http.ResponseWriter, r *http.Request) {
+	return h.withSavedQueries(ctx, "GetSavedQuery", func(w http.ResponseWriter, r *http.Request, user string) int {
+		q, code, err := h.getReadableSavedQuery(ctx, r, user, func() (*savedquery.SavedQuery, error) {
+			return h.SavedQueries.Get(ctx, mux.Vars(r)["id"])
+		})
+		if err != nil {
+			writeError(w, code, err.Error())
+			return code
+		}
+		writeJSON(w, http.StatusOK, q)
+		return http.StatusOK
+	})
+}
+
+func (h *Handlers) GetSharedQuery(ctx context.Context) func(w http.ResponseWriter, r *http.Request) {
+	return h.withSavedQueries(ctx, "GetSharedQuery", func(w http.ResponseWriter, r *http.Request, user string) int {
+		q, code, err := h.getReadableSavedQuery(ctx, r, user, func() (*savedquery.SavedQuery, error) {
+			return savedquery.FindByShareID(ctx, h.SavedQueries, mux.Vars(r)["shareId"])
+		})
+		if err != nil {
+			writeError(w, code, err.Error())
+			return code
+		}
+		writeJSON(w, http.StatusOK, sharedQuery{Name: q.Name, Params: q.Params, Query: q.Params.Values().Encode()})
+		return http.StatusOK
+	})
+}
+
+func (h *Handlers) CreateSavedQuery(ctx context.Context) func(w http.ResponseWriter, r *http.Request) {
+	return h.withSavedQueries(ctx, "CreateSavedQuery", func(w http.ResponseWriter, r *http.Request, user string) int {
+		input, code, err := h.readSavedQueryInput(ctx, w, r)
+		if err != nil {
+			writeError(w, code, err.Error())
+			return code
+		}
+		id, shareID, err := savedquery.NewIDs()
+		if err != nil {
+			return writeSavedQueryError(w, err)
+		}
+		now := time.Now().UTC()
+		q := savedquery.SavedQuery{ID: id, ShareID: shareID, Owner: user, CreatedAt: now}
+		input.applyTo(&q, now)
+		if err := h.SavedQueries.Put(ctx, &q); err != nil {
+			return writeSavedQueryError(w, err)
+		}
+		writeJSON(w, http.StatusCreated, q)
+		return http.StatusCreated
+	})
+}
+
+func (h *Handlers) UpdateSavedQuery(ctx context.Context) func(w http.ResponseWriter, r *http.Request) {
+	return h.withSavedQueries(ctx, "UpdateSavedQuery", func(w http.ResponseWriter, r *http.Request, user string) int {
+		q, code, err := h.getOwnedSavedQuery(ctx, r, user)
+		if err != nil {
+			writeError(w, code, err.Error())
+			return code
+		}
+		input, code, err := h.readSavedQueryInput(ctx, w, r)
+		if err != nil {
+			writeError(w, code, err.Error())
+			return code
+		}
+		input.applyTo(q, time.Now().UTC())
+		if err := h.SavedQueries.Put(ctx, q); err != nil {
+			return writeSavedQueryError(w, err)
+		}
+		writeJSON(w, http.StatusOK, q)
+		return http.StatusOK
+	})
+}
+
+func (h *Handlers) DeleteSavedQuery(ctx context.Context) func(w http.ResponseWriter, r *http.Request) {
+	return h.withSavedQueries(ctx, "DeleteSavedQuery", func(w http.ResponseWriter, r *http.Request, user string) int {
+		q, code, err := h.getOwnedSavedQuery(ctx, r, user)
+		if err != nil {
+			writeError(w, code, err.Error())
+			return code
+		}
+		if err := h.SavedQueries.Delete(ctx, q.ID); err != nil {
+			return writeSavedQueryError(w, err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return http.StatusNoContent
+	})
+}
+
+// withSavedQueries checks that the store is available and gets the user, before running the handler
+func (h *Handlers) withSavedQueries(ctx context.Context, name string, handle func(w http.ResponseWriter, r *http.Request, user string) int) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var code int
+		startTime := time.Now()
+		defer func() {
+			metrics.ObserveHTTPCall(name, code, startTime)
+		}()
+
+		if h.SavedQueries == nil {
+			code = http.StatusServiceUnavailable
+			writeError(w, code, "Saved queries are not available, check the plugin logs for details")
+			return
+		}
+		user, err := h.AuthChecker.GetUser(ctx, r.Header)
+		if err != nil {
+			code = http.StatusUnauthorized
+			writeError(w, code, err.Error())
+			return
+		}
+		code = handle(w, r, user)
+	}
+}
+
+func (h *Handlers) canReadSavedQuery(ctx context.Context, r *http.Request, user string, q *savedquery.SavedQuery, nsAccess map[string]bool) bool {
+	if q.Owner == user {
+		return true
+	}
+	switch q.Visibility {
+	case savedquery.VisibilityCluster:
+		return true
+	case savedquery.VisibilityNamespace:
+		allowed, checked := nsAccess[q.Namespace]
+		if !checked {
+			allowed = h.AuthChecker.CheckNamespace(ctx, r.Header, q.Namespace) == nil
+			nsAccess[q.Namespace] = allowed
+		}
+		return allowed
+	case savedquery.VisibilityPrivate:
+	}
+	return false
+}
+
+// getReadableSavedQuery returns the query if visible by the user. Queries that aren't visible are reported as not found.
+func (h *Handlers) getReadableSavedQuery(ctx context.Context, r *http.Request, user string, get func() (*savedquery.SavedQuery, error)) (*savedquery.SavedQuery, int, error) {
+	q, err := get()
+	if err != nil {
+		return nil, savedQueryErrorCode(err), err
+	}
+	if !h.canReadSavedQuery(ctx, r, user, q, map[string]bool{}) {
+		return nil, http.StatusNotFound, savedquery.ErrNotFound
+	}
+	return q, http.StatusOK, nil
+}
+
+// getOwnedSavedQuery returns the query if owned by the user, as only owners can modify their queries
+func (h *Handlers) getOwnedSavedQuery(ctx context.Context, r *http.Request, user string) (*savedquery.SavedQuery, int, error) {
+	q, code, err := h.getReadableSavedQuery(ctx, r, user, func() (*savedquery.SavedQuery, error) {
+		return h.SavedQueries.Get(ctx, mux.Vars(r)["id"])
+	})
+	if err != nil {
+		return nil, code, err
+	}
+	if q.Owner != user {
+		return nil, http.StatusForbidden, errors.New("only the owner can modify a saved query")
+	}
+	return q, http.StatusOK, nil
+}
+
+func (h *Handlers) readSavedQueryInput(ctx context.Context, w http.ResponseWriter, r *http.Request) (*savedQueryInput, int, error) {
+	var input savedQueryInput
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSavedQueryBodySize)).Decode(&input); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid saved query: %w", err)
+	}
+	if input.Visibility == "" {
+		input.Visibility = savedquery.VisibilityPrivate
+	}
+	q := savedquery.SavedQuery{Name: input.Name, Visibility: input.Visibility, Namespace: input.Namespace}
+	if err := q.Validate(); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid saved query: %w", err)
+	}
+	if err := validateSavedQueryParams(input.Params.Values()); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid saved query params: %w", err)
+	}
+	// users can only share queries with namespaces they have access to
+	if input.Visibility == savedquery.VisibilityNamespace {
+		if err := h.AuthChecker.CheckNamespace(ctx, r.Header, input.Namespace); err != nil {
+			return nil, http.StatusForbidden, err
+		}
+	}
+	return &input, http.StatusOK, nil
+}
+
+// validateSavedQueryParams checks the params the same way as API requests
+func validateSavedQueryParams(params url.Values) error {
+	if _, _, err := getStartTime(params); err != nil {
+		return err
+	}
+	if _, _, err := getEndTime(params); err != nil {
+		return err
+	}
+	if _, err := getRecordType(params); err != nil {
+		return err
+	}
+	if _, err := getDatasource(params); err != nil {
+		return err
+	}
+	if _, err := getPacketLoss(params); err != nil {
+		return err
+	}
+	if _, err := getFilters(params); err != nil {
+		return err
+	}
+	if params.Has(aggregateByKey) {
+		if _, err := getAggregate(params); err != nil {
+			return err
+		}
+	}
+	if _, err := getMetricType(params); err != nil {
+		return err
+	}
+	_, err := getMetricFunction(params)
+	return err
+}
+
+func (i *savedQueryInput) applyTo(q *savedquery.SavedQuery, now time.Time) {
+	q.Name = i.Name
+	q.Description = i.Description
+	q.Visibility = i.Visibility
+	q.Namespace = i.Namespace
+	q.Params = i.Params
+	q.UpdatedAt = now
+}
+
+func savedQueryErrorCode(err error) int {
+	if errors.Is(err, savedquery.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func writeSavedQueryError(w http.ResponseWriter, err error) int {
+	code := savedQueryErrorCode(err)
+	if code == http.StatusInternalServerError {
+		hlog.Errorf("Saved queries store error: %v", err)
+	}
+	writeError(w, code, err.Error())
+	return code
+}
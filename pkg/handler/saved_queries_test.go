@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/config"
+	"github.com/netobserv/network-observability-console-plugin/pkg/savedquery"
+)
+
+// userChecker reads the user from the bearer token, and grants access to the namespaces configured per user
+type userChecker struct {
+	namespaces map[string][]string
+}
+
+func (c *userChecker) CheckAuth(_ context.Context, _ http.Header) error { return nil }
+
+func (c *userChecker) CheckAdmin(_ context.Context, _ http.Header) error { return nil }
+
+func (c *userChecker) GetUser(_ context.Context, header http.Header) (string, error) {
+	user := strings.TrimPrefix(header.Get("Authorization"), "Bearer ")
+	if user == "" {
+		return "", errors.New("missing token")
+	}
+	return user, nil
+}
+
+func (c *userChecker) CheckNamespace(ctx context.Context, header http.Header, namespace string) error {
+	user, _ := c.GetUser(ctx, header)
+	for _, ns := range c.namespaces[user] {
+		if ns == namespace {
+			return nil
+		}
+	}
+	return errors.New("user cannot access namespace " + namespace)
+}
+
+func setupSavedQueries(t *testing.T) *mux.Router {
+	hdl := Handlers{
+		Cfg:          &config.Config{},
+		AuthChecker:  &userChecker{namespaces: map[string][]string{"alice": {"ns1"}, "bob": {"ns1"}}},
+		SavedQueries: savedquery.NewFileStore(filepath.Join(t.TempDir(), "queries.json")),
+	}
+	ctx := context.Background()
+	r := mux.NewRouter()
+	r.HandleFunc("/saved-queries", hdl.GetSavedQueries(ctx)).Methods(http.MethodGet)
+	r.HandleFunc("/saved-queries", hdl.CreateSavedQuery(ctx)).Methods(http.MethodPost)
+	r.HandleFunc("/saved-queries/share/{shareId}", hdl.GetSharedQuery(ctx)).Methods(http.MethodGet)
+	r.HandleFunc("/saved-queries/{id}", hdl.GetSavedQuery(ctx)).Methods(http.MethodGet)
+	r.HandleFunc("/saved-queries/{id}", hdl.UpdateSavedQuery(ctx)).Methods(http.MethodPut)
+	r.HandleFunc("/saved-queries/{id}", hdl.DeleteSavedQuery(ctx)).Methods(http.MethodDelete)
+	return r
+}
+
+func callSavedQueries(r http.Handler, user, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if user != "" {
+		req.Header.Set("Authorization", "Bearer "+user)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func createSavedQuery(t *testing.T, r http.Handler, user, body string) savedquery.SavedQuery {
+	rec := callSavedQueries(r, user, http.MethodPost, "/saved-queries", body)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var q savedquery.SavedQuery
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &q))
+	return q
+}
+
+func listSavedQueryNames(t *testing.T, r http.Handler, user string) []string {
+	rec := callSavedQueries(r, user, http.MethodGet, "/saved-queries", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var queries []savedquery.SavedQuery
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &queries))
+	names := []string{}
+	for i := range queries {
+		names = append(names, queries[i].Name)
+	}
+	return names
+}
+
+func TestSavedQueries_CRUD(t *testing.T) {
+	r := setupSavedQueries(t)
+
+	q := createSavedQuery(t, r, "alice", `{"name":"http","params":{"filters":"DstPort=80","recordType":"flowLog","timeRange":300}}`)
+	assert.NotEmpty(t, q.ID)
+	assert.NotEmpty(t, q.ShareID)
+	assert.Equal(t, "alice", q.Owner)
+	assert.Equal(t, savedquery.VisibilityPrivate, q.Visibility)
+	assert.Equal(t, "DstPort=80", q.Params.Filters)
+
+	rec := callSavedQueries(r, "alice", http.MethodGet, "/saved-queries/"+q.ID, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = callSavedQueries(r, "alice", http.MethodPut, "/saved-queries/"+q.ID, `{"name":"https","params":{"filters":"DstPort=443"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var updated savedquery.SavedQuery
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+	assert.Equal(t, "https", updated.Name)
+	assert.Equal(t, q.ShareID, updated.ShareID)
+	assert.Equal(t, q.CreatedAt, updated.CreatedAt)
+	assert.Equal(t, []string{"https"}, listSavedQueryNames(t, r, "alice"))
+
+	rec = callSavedQueries(r, "alice", http.MethodDelete, "/saved-queries/"+q.ID, "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = callSavedQueries(r, "alice", http.MethodGet, "/saved-queries/"+q.ID, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestSavedQueries_InvalidInput(t *testing.T) {
+	r := setupSavedQueries(t)
+
+	for _, body := range []string{
+		`not json`,
+		`{"name":""}`,
+		`{"name":"test","visibility":"public"}`,
+		`{"name":"test","params":{"recordType":"invalid"}}`,
+		`{"name":"test","params":{"filters":"SrcPort=80 AND (","filterSyntax":"expression"}}`,
+	} {
+		rec := callSavedQueries(r, "alice", http.MethodPost, "/saved-queries", body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+
+	// namespace not accessible by the user
+	rec := callSavedQueries(r, "alice", http.MethodPost, "/saved-queries", `{"name":"test","visibility":"namespace","namespace":"ns2"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// not authenticated
+	rec = callSavedQueries(r, "", http.MethodGet, "/saved-queries", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestSavedQueries_Visibility(t *testing.T) {
+	r := setupSavedQueries(t)
+
+	private := createSavedQuery(t, r, "alice", `{"name":"private"}`)
+	createSavedQuery(t, r, "alice", `{"name":"namespace","visibility":"namespace","namespace":"ns1"}`)
+	cluster := createSavedQuery(t, r, "alice", `{"name":"cluster","visibility":"cluster"}`)
+
+	assert.Equal(t, []string{"cluster", "namespace", "private"}, listSavedQueryNames(t, r, "alice"))
+	assert.Equal(t, []string{"cluster", "namespace"}, listSavedQueryNames(t, r, "bob"))
+	assert.Equal(t, []string{"cluster"}, listSavedQueryNames(t, r, "carol"))
+
+	// private queries of other users look like they don't exist
+	rec := callSavedQueries(r, "bob", http.MethodGet, "/saved-queries/"+private.ID, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = callSavedQueries(r, "bob", http.MethodDelete, "/saved-queries/"+private.ID, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// only owners can modify visible queries
+	rec = callSavedQueries(r, "bob", http.MethodPut, "/saved-queries/"+cluster.ID, `{"name":"renamed"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = callSavedQueries(r, "bob", http.MethodDelete, "/saved-queries/"+cluster.ID, "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestSavedQueries_Share(t *testing.T) {
+	r := setupSavedQueries(t)
+
+	q := createSavedQuery(t, r, "alice", `{"name":"dns","visibility":"cluster","params":{"filters":"DstPort=53","recordType":"flowLog"}}`)
+
+	rec := callSavedQueries(r, "bob", http.MethodGet, "/saved-queries/share/"+q.ShareID, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var shared sharedQuery
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &shared))
+	assert.Equal(t, "dns", shared.Name)
+	assert.Equal(t, "filters=DstPort%3D53&recordType=flowLog", shared.Query)
+
+	rec = callSavedQueries(r, "bob", http.MethodGet, "/saved-queries/share/unknown", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestSavedQueries_Disabled(t *testing.T) {
+	hdl := Handlers{Cfg: &config.Config{}, AuthChecker: &userChecker{}}
+	rec := httptest.NewRecorder()
+	hdl.GetSavedQueries(context.Background())(rec, httptest.NewRequest(http.MethodGet, "/saved-queries", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
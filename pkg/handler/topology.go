@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/config"
@@ -17,20 +18,32 @@ import (
 	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
 
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	pmodel "github.com/prometheus/common/model"
 )
 
 const (
 	metricTypeKey     = "type"
 	metricFunctionKey = "function"
 
-	aggregateByKey  = "aggregateBy"
-	groupsKey       = "groups"
-	rateIntervalKey = "rateInterval"
-	stepKey         = "step"
+	aggregateByKey    = "aggregateBy"
+	groupsKey         = "groups"
+	rateIntervalKey   = "rateInterval"
+	stepKey           = "step"
+	topologyFormatKey = "format"
+	compareToKey      = "compareTo"
+	lookbackKey       = "lookback"
+	othersKey         = "others"
+
+	topologyFormatMatrix = "matrix"
+	topologyFormatGraph  = "graph"
 
 	defaultRateInterval = "1m"
 	defaultStep         = "30s"
 	defaultStepDuration = time.Second * 30
+	defaultLookback     = time.Hour * 24
+
+	// anomalyCandidatesFactor is the number of series queried per series returned, when ranking by anomaly
+	anomalyCandidatesFactor = 5
 )
 
 func (h *Handlers) GetTopology(ctx context.Context) func(w http.ResponseWriter, r *http.Request) {
@@ -54,32 +67,254 @@ func (h *Handlers) GetTopology(ctx context.Context) func(w http.ResponseWriter,
 			return
 		}
 
-		flows, code, err := h.getTopologyFlows(ctx, clients, params, ds)
-		var dsErr *datasourceError
-		if err != nil &&
-			ds == constants.DataSourceAuto &&
-			h.Cfg.IsLokiEnabled() &&
-			(code == http.StatusForbidden || code == http.StatusUnauthorized) &&
-			errors.As(err, &dsErr) &&
-			dsErr.datasource == constants.DataSourceProm {
-			// In case this was a prometheus 401 / 403 error, the query is repeated with Loki
-			// This is because multi-tenancy is currently not managed for prom datasource, hence such queries have to go with Loki
-			// Unfortunately we don't know a safe and generic way to pre-flight check if the user will be authorized
-			hlog.Info("Retrying with Loki...")
-			flows, code, err = h.getTopologyFlows(ctx, clients, params, constants.DataSourceLoki)
-		}
+		resp, code, err := h.getTopologyResponse(ctx, clients, params, ds)
 		if err != nil {
 			writeError(w, code, err.Error())
 			return
 		}
 
 		code = http.StatusOK
-		writeJSON(w, code, flows)
+		writeJSON(w, code, resp)
 	}
 }
 
+// getTopologyResponse runs the topology queries, and returns their result in the requested format: metrics
+// matrix (default) or graph, metrics compared with a past time window, or scored for anomalies
+func (h *Handlers) getTopologyResponse(ctx context.Context, cl clients, params url.Values, ds constants.DataSource) (any, int, error) {
+	params, err := h.resolveAutoIntervals(ctx, cl, params, ds)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	format, err := getTopologyFormat(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	compareTo, err := getCompareTo(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	mf, err := getMetricFunction(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if mf == constants.MetricFunctionAnomaly {
+		if compareTo > 0 || format == topologyFormatGraph || params.Get(othersKey) == "true" {
+			return nil, http.StatusBadRequest, errors.New("the anomaly function is not supported with compareTo, others or the graph format")
+		}
+		return h.getTopologyAnomalies(ctx, cl, params, ds)
+	}
+	if compareTo > 0 {
+		if format == topologyFormatGraph {
+			return nil, http.StatusBadRequest, errors.New("compareTo is not supported with the graph format")
+		}
+		return h.getTopologyComparison(ctx, cl, params, ds, compareTo)
+	}
+
+	flows, code, err := h.getTopologyFlowsWithFallback(ctx, cl, params, ds)
+	if err != nil {
+		return nil, code, err
+	}
+	if format == topologyFormatGraph {
+		graph, err := toTopologyGraph(flows, params, h.Cfg.Frontend.GetScopes())
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		return graph, http.StatusOK, nil
+	}
+	return flows, http.StatusOK, nil
+}
+
+func (h *Handlers) getTopologyFlowsWithFallback(ctx context.Context, cl clients, params url.Values, ds constants.DataSource) (*model.AggregatedQueryResponse, int, error) {
+	flows, code, err := h.getTopologyFlows(ctx, cl, params, ds)
+	var dsErr *datasourceError
+	if err != nil &&
+		ds == constants.DataSourceAuto &&
+		h.Cfg.IsLokiEnabled() &&
+		(code == http.StatusForbidden || code == http.StatusUnauthorized) &&
+		errors.As(err, &dsErr) &&
+		dsErr.datasource == constants.DataSourceProm {
+		// In case this was a prometheus 401 / 403 error, the query is repeated with Loki
+		// This is because multi-tenancy is currently not managed for prom datasource, hence such queries have to go with Loki
+		// Unfortunately we don't know a safe and generic way to pre-flight check if the user will be authorized
+		hlog.Info("Retrying with Loki...")
+		flows, code, err = h.getTopologyFlows(ctx, cl, params, constants.DataSourceLoki)
+	}
+	return flows, code, err
+}
+
+// getTopologyComparison runs the topology query on the requested time window, and on the same window shifted back
+// by compareTo, then compares each series with its baseline
+func (h *Handlers) getTopologyComparison(ctx context.Context, cl clients, params url.Values, ds constants.DataSource, compareTo time.Duration) (*model.ComparisonQueryResponse, int, error) {
+	// both windows are pinned, so that they don't drift between queries
+	currentParams, err := shiftTimeWindow(params, 0, 0)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("compareTo %w", err)
+	}
+	baselineParams, err := shiftTimeWindow(params, compareTo, compareTo)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("compareTo %w", err)
+	}
+	current, code, err := h.getTopologyFlowsWithFallback(ctx, cl, currentParams, ds)
+	if err != nil {
+		return nil, code, err
+	}
+	baseline, code, err := h.getTopologyFlowsWithFallback(ctx, cl, baselineParams, ds)
+	if err != nil {
+		return nil, code, fmt.Errorf("baseline query failed: %w", err)
+	}
+	currentMatrix, _ := current.Result.(model.Matrix)
+	baselineMatrix, _ := baseline.Result.(model.Matrix)
+	// each window is ranked separately: a series missing from a truncated window may be just below the limit
+	_, reqLimit, err := getLimit(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	currentLimited := reqLimit > 0 && current.Stats.LimitReached
+	baselineLimited := reqLimit > 0 && baseline.Stats.LimitReached
+	steps, err := windowSteps(currentParams)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return &model.ComparisonQueryResponse{
+		Result:        model.CompareMatrices(currentMatrix, baselineMatrix, steps, currentLimited, baselineLimited),
+		CompareTo:     params.Get(compareToKey),
+		Stats:         current.Stats,
+		BaselineStats: baseline.Stats,
+		UnixTimestamp: current.UnixTimestamp,
+	}, http.StatusOK, nil
+}
+
+// shiftTimeWindow returns a copy of the params with an explicit time window, its start and end moved back by
+// the given offsets
+func shiftTimeWindow(params url.Values, startOffset, endOffset time.Duration) (url.Values, error) {
+	start, sTime, err := getStartTime(params)
+	if err != nil {
+		return nil, err
+	}
+	if start == "" {
+		return nil, errors.New("requires a startTime or a timeRange")
+	}
+	_, eTime, err := getEndTime(params)
+	if err != nil {
+		return nil, err
+	}
+	shifted := url.Values{}
+	for k, v := range params {
+		shifted[k] = v
+	}
+	shifted.Del(timeRangeKey)
+	shifted.Set(startTimeKey, strconv.FormatInt(sTime.Add(-startOffset).Unix(), 10))
+	// end time is ceiled to the next second when read, which must not be applied twice
+	shifted.Set(endTimeKey, strconv.FormatInt(eTime.Add(-endOffset).Unix()-1, 10))
+	return shifted, nil
+}
+
+// windowSteps returns the number of samples expected per series over the time window, evaluated at each step
+// from its start to its end included
+func windowSteps(params url.Values) (int, error) {
+	_, sTime, err := getStartTime(params)
+	if err != nil {
+		return 0, err
+	}
+	_, eTime, err := getEndTime(params)
+	if err != nil {
+		return 0, err
+	}
+	_, step, err := getStep(params)
+	if err != nil {
+		return 0, err
+	}
+	if step <= 0 || eTime.Before(sTime) {
+		return 0, nil
+	}
+	return int(eTime.Sub(sTime)/step) + 1, nil
+}
+
+// getTopologyAnomalies runs the topology query over the requested time window extended back by the look-back
+// window, then scores the series of the requested window against their baseline. As series are ranked by anomaly
+// rather than by volume, more candidates than the limit are queried
+func (h *Handlers) getTopologyAnomalies(ctx context.Context, cl clients, params url.Values, ds constants.DataSource) (*model.AnomalyQueryResponse, int, error) {
+	lookback, err := getLookback(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	_, reqLimit, err := getLimit(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	_, step, err := getStep(params)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	extended, err := shiftTimeWindow(params, lookback, 0)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("the anomaly function %w", err)
+	}
+	// scored points start from the requested start time
+	from, _ := strconv.ParseInt(extended.Get(startTimeKey), 10, 64)
+	to, _ := strconv.ParseInt(extended.Get(endTimeKey), 10, 64)
+	// the look-back window multiplies the points per series: the step is raised to keep them below the limit
+	if minStep := (time.Duration(to-from)*time.Second/maxStepsPerQuery + time.Second - 1).Truncate(time.Second); step < minStep {
+		step = minStep
+		extended.Set(stepKey, pmodel.Duration(step).String())
+	}
+	from += int64(lookback.Seconds())
+	extended.Set(metricFunctionKey, string(anomalyBaseFunction(extended.Get(metricTypeKey))))
+	if reqLimit > 0 {
+		extended.Set(limitKey, strconv.Itoa(reqLimit*anomalyCandidatesFactor))
+	}
+
+	flows, code, err := h.getTopologyFlowsWithFallback(ctx, cl, extended, ds)
+	if err != nil {
+		return nil, code, err
+	}
+	var baselinePoints int
+	if step > 0 {
+		baselinePoints = int(lookback / step)
+	}
+	matrix, _ := flows.Result.(model.Matrix)
+	return &model.AnomalyQueryResponse{
+		Result:        model.ScoreAnomalies(matrix, pmodel.TimeFromUnix(from), baselinePoints, reqLimit),
+		Lookback:      pmodel.Duration(lookback).String(),
+		Stats:         flows.Stats,
+		UnixTimestamp: flows.UnixTimestamp,
+	}, http.StatusOK, nil
+}
+
+// anomalyBaseFunction returns the function of the values scored for anomalies: averages for latencies, rates otherwise
+func anomalyBaseFunction(metricType string) constants.MetricFunction {
+	switch metricType {
+	case constants.MetricTypeDNSLatency, constants.MetricTypeFlowRTT:
+		return constants.MetricFunctionAvg
+	default:
+		return constants.MetricFunctionRate
+	}
+}
+
+// toTopologyGraph builds the graph response from the topology metrics, with nodes identified by the scopes labels
+// and grouped as in the groups parameter
+func toTopologyGraph(flows *model.AggregatedQueryResponse, params url.Values, scopes []config.Scope) (*model.TopologyGraphResponse, error) {
+	matrix, _ := flows.Result.(model.Matrix)
+	mf, _ := getMetricFunction(params)
+	// the step actually used, which may result from the auto step; rate totals can't be computed without it
+	step, err := pmodel.ParseDuration(flows.Stats.Step)
+	if err != nil {
+		return nil, fmt.Errorf("can't compute graph totals from step %q: %w", flows.Stats.Step, err)
+	}
+	return &model.TopologyGraphResponse{
+		Graph:         model.BuildTopologyGraph(matrix, scopes, params.Get(groupsKey), mf == constants.MetricFunctionRate, time.Duration(step)),
+		Stats:         flows.Stats,
+		UnixTimestamp: flows.UnixTimestamp,
+	}, nil
+}
+
 func (h *Handlers) extractTopologyQueryParams(params url.Values, ds constants.DataSource) (*loki.TopologyInput, filters.MultiQueries, v1.Range, int, error) {
-	in := loki.TopologyInput{DedupMark: h.Cfg.Frontend.Deduper.Mark, DataSource: ds}
+	in := loki.TopologyInput{
+		DedupMark:      h.Cfg.Frontend.Deduper.Mark,
+		DataSource:     ds,
+		ReportersMerge: h.Cfg.Frontend.Deduper.GetMergeStrategy(),
+		Scopes:         h.Cfg.Frontend.GetScopes(),
+	}
 	qr := v1.Range{}
 	var reqLimit int
 	var err error
@@ -104,7 +339,10 @@ func (h *Handlers) extractTopologyQueryParams(params url.Values, ds constants.Da
 	if err != nil {
 		return nil, nil, qr, reqLimit, err
 	}
-	in.DataField = getMetricType(params)
+	in.DataField, err = getMetricType(params)
+	if err != nil {
+		return nil, nil, qr, reqLimit, err
+	}
 	in.MetricFunction, err = getMetricFunction(params)
 	if err != nil {
 		return nil, nil, qr, reqLimit, err
@@ -121,19 +359,23 @@ func (h *Handlers) extractTopologyQueryParams(params url.Values, ds constants.Da
 	if err != nil {
 		return nil, nil, qr, reqLimit, err
 	}
+	if err = loki.CheckAggregate(&h.Cfg.Loki, in.Scopes, in.Aggregate); err != nil {
+		return nil, nil, qr, reqLimit, err
+	}
 	in.Groups = params.Get(groupsKey)
-	rawFilters := params.Get(filtersKey)
-	filterGroups, err := filters.Parse(rawFilters)
+	filterGroups, err := h.parseFilters(params)
 	if err != nil {
 		return nil, nil, qr, reqLimit, err
 	}
 
-	if shouldMergeReporters(in.DataField) {
+	// with the max strategy, both directions are compared within each query instead, see buildTopologyQuery
+	if shouldMergeReporters(in.DataField) && in.ReportersMerge != constants.ReportersMergeMax {
 		filterGroups = expandReportersMergeQueries(
 			filterGroups,
+			in.ReportersMerge,
 			func(filters filters.SingleQuery) bool {
 				// Do not expand if this is managed from prometheus
-				sr, _ := getEligiblePromMetric(h.PromInventory, filters, &in)
+				sr, _ := getEligiblePromMetric(&h.Cfg.Loki, h.PromInventory, filters, &in)
 				return sr != nil && len(sr.Found) > 0
 			},
 		)
@@ -154,16 +396,69 @@ func (h *Handlers) getTopologyFlows(ctx context.Context, cl clients, params url.
 	if err != nil {
 		return nil, http.StatusBadRequest, err
 	}
+	others := params.Get(othersKey) == "true"
+	if others && !isAdditive(in) {
+		return nil, http.StatusBadRequest, errors.New("others is only supported with the rate, sum or count functions, and not on latencies")
+	}
 
 	merger := loki.NewMatrixMerger(reqLimit)
+	code, err := h.fetchTopology(ctx, cl, filterGroups, in, &qr, merger, dataSources)
+	if err != nil {
+		return nil, code, err
+	}
+
+	qresp := merger.Get()
+	if others {
+		// the total is queried with the same filters and datasources, then the top series are subtracted from it
+		totalIn := *in
+		totalIn.Total = true
+		totalMerger := loki.NewMatrixMerger(reqLimit)
+		code, err = h.fetchTopology(ctx, cl, filterGroups, &totalIn, &qr, totalMerger, dataSources)
+		if err != nil {
+			return nil, code, fmt.Errorf("total query failed: %w", err)
+		}
+		total := totalMerger.Get()
+		top, _ := qresp.Result.(model.Matrix)
+		totalMatrix, _ := total.Result.(model.Matrix)
+		top = append(top, model.OthersSeries(top, totalMatrix))
+		qresp.Result = top
+		qresp.Stats.NumQueries += total.Stats.NumQueries
+		qresp.Stats.QueriesStats = append(qresp.Stats.QueriesStats, total.Stats.QueriesStats...)
+	}
+	qresp.Stats.DataSources = []constants.DataSource{}
+	for str, ok := range dataSources {
+		if ok {
+			qresp.Stats.DataSources = append(qresp.Stats.DataSources, str)
+		}
+	}
+	qresp.Stats.Step = in.Step
+	qresp.Stats.RateInterval = in.RateInterval
+	if shouldMergeReporters(in.DataField) {
+		qresp.Stats.ReportersMerge = in.ReportersMerge
+	}
+	qresp.UnixTimestamp = time.Now().Unix()
+	hlog.Tracef("GetTopology response: %v", qresp)
+	return qresp, http.StatusOK, nil
+}
+
+// fetchTopology runs the topology queries of the filter groups, flagging the datasources used
+func (h *Handlers) fetchTopology(
+	ctx context.Context,
+	cl clients,
+	filterGroups filters.MultiQueries,
+	in *loki.TopologyInput,
+	qr *v1.Range,
+	merger loki.Merger,
+	dataSources map[constants.DataSource]bool,
+) (int, error) {
 	if len(filterGroups) > 1 {
 		// match any, and multiple filters => run in parallel then aggregate
 		var lokiQ []string
 		var promQ []*prometheus.Query
 		for _, filters := range filterGroups {
-			lq, pq, code, err := buildTopologyQuery(h.Cfg, h.PromInventory, filters, in, &qr)
+			lq, pq, code, err := buildTopologyQuery(h.Cfg, h.PromInventory, filters, in, qr)
 			if err != nil {
-				return nil, code, errors.New("Can't build query: " + err.Error())
+				return code, errors.New("Can't build query: " + err.Error())
 			}
 			if pq != nil {
 				promQ = append(promQ, pq)
@@ -173,49 +468,50 @@ func (h *Handlers) getTopologyFlows(ctx context.Context, cl clients, params url.
 				dataSources[constants.DataSourceLoki] = true
 			}
 		}
-		code, err := cl.fetchParallel(ctx, lokiQ, promQ, merger)
-		if err != nil {
-			return nil, code, err
-		}
-	} else {
-		// else, run all at once
-		var filters filters.SingleQuery
-		if len(filterGroups) > 0 {
-			filters = filterGroups[0]
-		}
-		lokiQ, promQ, code, err := buildTopologyQuery(h.Cfg, h.PromInventory, filters, in, &qr)
-		if err != nil {
-			return nil, code, err
-		}
-		if len(lokiQ) > 0 {
-			dataSources[constants.DataSourceLoki] = true
-		}
-		if promQ != nil {
-			dataSources[constants.DataSourceProm] = true
-		}
-		code, err = cl.fetchSingle(ctx, lokiQ, promQ, merger)
-		if err != nil {
-			return nil, code, err
-		}
+		return cl.fetchParallel(ctx, lokiQ, promQ, merger)
+	}
+	// else, run all at once
+	var filters filters.SingleQuery
+	if len(filterGroups) > 0 {
+		filters = filterGroups[0]
+	}
+	lokiQ, promQ, code, err := buildTopologyQuery(h.Cfg, h.PromInventory, filters, in, qr)
+	if err != nil {
+		return code, err
 	}
+	if len(lokiQ) > 0 {
+		dataSources[constants.DataSourceLoki] = true
+	}
+	if promQ != nil {
+		dataSources[constants.DataSourceProm] = true
+	}
+	return cl.fetchSingle(ctx, lokiQ, promQ, merger)
+}
 
-	qresp := merger.Get()
-	qresp.Stats.DataSources = []constants.DataSource{}
-	for str, ok := range dataSources {
-		if ok {
-			qresp.Stats.DataSources = append(qresp.Stats.DataSources, str)
-		}
+// isAdditive returns true when the top series values can be subtracted from the total, ie. they are sums
+func isAdditive(in *loki.TopologyInput) bool {
+	switch in.DataField {
+	case constants.MetricTypeDNSLatency, constants.MetricTypeFlowRTT:
+		// averages or quantiles with Prometheus
+		return false
+	}
+	switch in.MetricFunction {
+	case constants.MetricFunctionRate, constants.MetricFunctionSum, constants.MetricFunctionCount:
+		return true
+	default:
+		return false
 	}
-	qresp.UnixTimestamp = time.Now().Unix()
-	hlog.Tracef("GetTopology response: %v", qresp)
-	return qresp, http.StatusOK, nil
 }
 
 func shouldMergeReporters(metricType string) bool {
 	return metricType == constants.MetricTypeBytes || metricType == constants.MetricTypePackets
 }
 
-func expandReportersMergeQueries(queries filters.MultiQueries, isForProm func(filters filters.SingleQuery) bool) filters.MultiQueries {
+func expandReportersMergeQueries(
+	queries filters.MultiQueries,
+	strategy constants.ReportersMerge,
+	isForProm func(filters filters.SingleQuery) bool,
+) filters.MultiQueries {
 	var out filters.MultiQueries
 	for _, q := range queries {
 		// Do not expand if this is managed from prometheus
@@ -223,7 +519,7 @@ func expandReportersMergeQueries(queries filters.MultiQueries, isForProm func(fi
 			out = append(out, q)
 			continue
 		}
-		q1, q2 := filters.SplitForReportersMerge(q)
+		q1, q2 := filters.SplitForReportersMerge(q, strategy)
 		if q1 != nil {
 			out = append(out, q1)
 		}
@@ -241,12 +537,12 @@ func buildTopologyQuery(
 	in *loki.TopologyInput,
 	qr *v1.Range,
 ) (string, *prometheus.Query, int, error) {
-	search, unsupportedReason := getEligiblePromMetric(promInventory, filters, in)
+	search, unsupportedReason := getEligiblePromMetric(&cfg.Loki, promInventory, filters, in)
 	if unsupportedReason != "" {
 		hlog.Debugf("Unsupported Prometheus query; reason: %s.", unsupportedReason)
 	} else if search != nil && len(search.Found) > 0 {
 		// Success, we can use Prometheus
-		qb := prometheus.NewQuery(in, qr, filters, search.Found)
+		qb := prometheus.NewQuery(&cfg.Loki, in, qr, filters, search.Found)
 		q := qb.Build()
 		return "", &q, http.StatusOK, nil
 	}
@@ -275,18 +571,47 @@ func buildTopologyQuery(
 			"this request could not be performed with Prometheus metrics%s: it requires installing and enabling Loki", reason)
 	}
 
-	qb, err := loki.NewTopologyQuery(&cfg.Loki, in)
+	lq, err := buildLokiTopologyQuery(cfg, in, filters)
 	if err != nil {
 		return "", nil, http.StatusBadRequest, err
 	}
-	err = qb.Filters(filters)
+	return lq, nil, http.StatusOK, nil
+}
+
+// buildLokiTopologyQuery builds the Loki query. With the max merge strategy, both reporters are compared in the query
+func buildLokiTopologyQuery(cfg *config.Config, in *loki.TopologyInput, q filters.SingleQuery) (string, error) {
+	if shouldMergeReporters(in.DataField) && in.ReportersMerge == constants.ReportersMergeMax {
+		if q1, q2 := filters.SplitForReportersMerge(q, in.ReportersMerge); q2 != nil {
+			qb1, err := newTopologyQueryBuilder(cfg, in, q1)
+			if err != nil {
+				return "", err
+			}
+			qb2, err := newTopologyQueryBuilder(cfg, in, q2)
+			if err != nil {
+				return "", err
+			}
+			return qb1.BuildMax(qb2), nil
+		}
+	}
+	qb, err := newTopologyQueryBuilder(cfg, in, q)
 	if err != nil {
-		return "", nil, http.StatusBadRequest, err
+		return "", err
+	}
+	return qb.Build(), nil
+}
+
+func newTopologyQueryBuilder(cfg *config.Config, in *loki.TopologyInput, filters filters.SingleQuery) (*loki.TopologyQueryBuilder, error) {
+	qb, err := loki.NewTopologyQuery(&cfg.Loki, in)
+	if err != nil {
+		return nil, err
 	}
-	return EncodeQuery(qb.Build()), nil, http.StatusOK, nil
+	if err = qb.Filters(filters); err != nil {
+		return nil, err
+	}
+	return qb, nil
 }
 
-func getEligiblePromMetric(promInventory *prometheus.Inventory, filters filters.SingleQuery, in *loki.TopologyInput) (*prometheus.SearchResult, string) {
+func getEligiblePromMetric(cfg *config.Loki, promInventory *prometheus.Inventory, filters filters.SingleQuery, in *loki.TopologyInput) (*prometheus.SearchResult, string) {
 	if in.DataSource != constants.DataSourceAuto && in.DataSource != constants.DataSourceProm {
 		return nil, ""
 	}
@@ -296,9 +621,15 @@ func getEligiblePromMetric(promInventory *prometheus.Inventory, filters filters.
 	if in.RecordType != "" && in.RecordType != constants.RecordTypeLog {
 		return nil, fmt.Sprintf("RecordType not managed: %s", in.RecordType)
 	}
+	if in.MetricFunction == constants.MetricFunctionStddev || in.MetricFunction == constants.MetricFunctionLast {
+		// these apply to individual flows, that metrics don't have
+		return nil, fmt.Sprintf("MetricFunction not managed: %s", in.MetricFunction)
+	}
 
-	labelsNeeded, _ := prometheus.GetLabelsAndFilter(in.Aggregate, in.Groups)
-	fromFilters, unsupportedReason := prometheus.FiltersToLabels(filters)
+	labelsNeeded, _ := prometheus.GetLabelsAndFilter(in.Scopes, in.Aggregate, in.Groups)
+	// comparisons on the value field are applied after aggregation, they don't require labels
+	labelFilters, _ := prometheus.SplitValueFilters(filters, in.DataField)
+	fromFilters, unsupportedReason := prometheus.FiltersToLabels(cfg, labelFilters)
 	if unsupportedReason != "" {
 		return nil, unsupportedReason
 	}
@@ -5,9 +5,15 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/podlabels"
+	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/naming"
 	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
+	pmodel "github.com/prometheus/common/model"
 )
 
 func getStartTime(params url.Values) (string, time.Time, error) {
@@ -108,20 +114,86 @@ func getPacketLoss(params url.Values) (constants.PacketLoss, error) {
 	return "", fmt.Errorf("invalid packet loss: %s", pl)
 }
 
+// getFilters parses the filters parameter, using either the legacy syntax (default) or the expression language.
+// Patterns are case-insensitive and regular expressions case-sensitive, unless the caseSensitive parameter is set,
+// in which case it applies to both
+func getFilters(params url.Values) (filters.MultiQueries, error) {
+	groups, err := parseFiltersSyntax(params)
+	if err != nil {
+		return nil, err
+	}
+	if caseSensitive := params.Get(caseSensitiveKey); caseSensitive != "" {
+		if caseSensitive != "true" && caseSensitive != "false" {
+			return nil, fmt.Errorf("invalid caseSensitive: %s", caseSensitive)
+		}
+		for g := range groups {
+			for i := range groups[g] {
+				groups[g][i].CaseSensitive = caseSensitive == "true"
+			}
+		}
+	}
+	return groups, nil
+}
+
+func parseFiltersSyntax(params url.Values) (filters.MultiQueries, error) {
+	raw := params.Get(filtersKey)
+	switch syntax := params.Get(filterSyntaxKey); syntax {
+	case "", filterSyntaxLegacy:
+		return filters.Parse(raw)
+	case filterSyntaxExpression:
+		return filters.ParseExpression(raw)
+	default:
+		return nil, fmt.Errorf("invalid filter syntax: %s", syntax)
+	}
+}
+
+var errLabelSelectorsDisabled = errors.New("label selector filters are disabled, they must be enabled in the plugin configuration")
+
+// parseFilters parses the filters, with service and protocol names translated into numbers
+// and label selectors expanded into namespaces and owners
+func (h *Handlers) parseFilters(params url.Values) (filters.MultiQueries, error) {
+	groups, err := getFilters(params)
+	if err != nil {
+		return nil, err
+	}
+	naming.New(&h.Cfg.Frontend).TranslateFilters(groups)
+	if !podlabels.HasSelectors(groups) {
+		return groups, nil
+	}
+	if h.PodLabels == nil {
+		return nil, errLabelSelectorsDisabled
+	}
+	return h.PodLabels.ExpandFilters(groups)
+}
+
 func getAggregate(params url.Values) (string, error) {
 	agg := params.Get(aggregateByKey)
 	if agg == "" {
 		return "", errors.New("aggregateBy parameter is required")
 	}
-	return agg, nil
+	// aggregation is a comma-separated list of keys or fields, written as is in queries
+	aggFields := loki.AggregateFields(agg)
+	if len(aggFields) == 0 {
+		return "", fmt.Errorf("invalid aggregateBy: %s", agg)
+	}
+	for _, field := range aggFields {
+		if !filters.IsValidKey(field) {
+			return "", fmt.Errorf("invalid aggregateBy: %s", field)
+		}
+	}
+	return strings.Join(aggFields, ","), nil
 }
 
-func getMetricType(params url.Values) string {
+func getMetricType(params url.Values) (string, error) {
 	mt := params.Get(metricTypeKey)
 	if mt == "" {
-		return constants.DefaultMetricType
+		return constants.DefaultMetricType, nil
+	}
+	// metric type is written as is in queries
+	if !filters.IsValidKey(mt) {
+		return "", fmt.Errorf("invalid metric type: %s", mt)
 	}
-	return mt
+	return mt, nil
 }
 
 func getMetricFunction(params url.Values) (constants.MetricFunction, error) {
@@ -135,9 +207,14 @@ func getMetricFunction(params url.Values) (constants.MetricFunction, error) {
 		metricFunction == constants.MetricFunctionAvg ||
 		metricFunction == constants.MetricFunctionMin ||
 		metricFunction == constants.MetricFunctionMax ||
-		metricFunction == constants.MetricFunctionP90 ||
-		metricFunction == constants.MetricFunctionP99 ||
-		metricFunction == constants.MetricFunctionRate {
+		metricFunction == constants.MetricFunctionStddev ||
+		metricFunction == constants.MetricFunctionLast ||
+		metricFunction == constants.MetricFunctionRate ||
+		metricFunction == constants.MetricFunctionAnomaly {
+		return metricFunction, nil
+	}
+	// quantiles, such as p50 or p999
+	if _, ok := loki.Quantile(metricFunction); ok {
 		return metricFunction, nil
 	}
 	return "", fmt.Errorf("invalid metric function: %s", mf)
@@ -154,14 +231,51 @@ func getRateInterval(params url.Values) (string, error) {
 	return rateInterval, nil
 }
 
+func getTopologyFormat(params url.Values) (string, error) {
+	format := params.Get(topologyFormatKey)
+	switch format {
+	case "", topologyFormatMatrix:
+		return topologyFormatMatrix, nil
+	case topologyFormatGraph:
+		return format, nil
+	}
+	return "", fmt.Errorf("invalid topology format: %s", format)
+}
+
+// getCompareTo returns the offset of the baseline time window, such as "1w", or 0 when there is no comparison
+func getCompareTo(params url.Values) (time.Duration, error) {
+	return getPositiveDuration(params, compareToKey, 0)
+}
+
+func getLookback(params url.Values) (time.Duration, error) {
+	return getPositiveDuration(params, lookbackKey, defaultLookback)
+}
+
+// getPositiveDuration parses a duration parameter, that can use days and weeks such as "1w"
+func getPositiveDuration(params url.Values, key string, defaultValue time.Duration) (time.Duration, error) {
+	value := params.Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := pmodel.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %s: %w", key, value, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s %s: must be positive", key, value)
+	}
+	return time.Duration(d), nil
+}
+
 func getStep(params url.Values) (string, time.Duration, error) {
 	step := params.Get(stepKey)
 	if step == "" {
 		return defaultStep, defaultStepDuration, nil
 	}
-	d, err := time.ParseDuration(step)
+	// as Loki and Prometheus, accept days and weeks such as "1d"
+	d, err := pmodel.ParseDuration(step)
 	if err != nil {
 		return "", 0, fmt.Errorf("invalid step %s: %w", step, err)
 	}
-	return step, d, nil
+	return step, time.Duration(d), nil
 }
@@ -76,18 +76,83 @@ func TestGetRecordType(t *testing.T) {
 	assert.Equal(t, constants.DefaultRecordType, rec)
 }
 
+func TestGetAggregate(t *testing.T) {
+	for agg, expected := range map[string]string{
+		"namespace":              "namespace",
+		"DstPort,Proto":          "DstPort,Proto",
+		"namespace, DstPort ,":   "namespace,DstPort",
+		"host,Dscp":              "host,Dscp",
+		"K8S_ClusterName,zone,,": "K8S_ClusterName,zone",
+	} {
+		params := url.Values{
+			aggregateByKey: []string{agg},
+		}
+		a, err := getAggregate(params)
+		assert.NoError(t, err, agg)
+		assert.Equal(t, expected, a, agg)
+	}
+
+	// Required
+	_, err := getAggregate(url.Values{})
+	assert.Error(t, err)
+
+	// Invalid
+	for _, agg := range []string{",", "DstPort,Bytes[1h])", "DstPort;Proto"} {
+		params := url.Values{
+			aggregateByKey: []string{agg},
+		}
+		_, err = getAggregate(params)
+		assert.Error(t, err, agg)
+	}
+}
+
 func TestGetMetricType(t *testing.T) {
 	// Valid
 	params := url.Values{
 		metricTypeKey: []string{"Packets"},
 	}
-	m := getMetricType(params)
+	m, err := getMetricType(params)
+	assert.NoError(t, err)
 	assert.Equal(t, constants.MetricTypePackets, m)
 
 	// Default
 	params = url.Values{}
-	m = getMetricType(params)
+	m, err = getMetricType(params)
+	assert.NoError(t, err)
 	assert.Equal(t, constants.DefaultMetricType, m)
+
+	// Invalid
+	params = url.Values{
+		metricTypeKey: []string{"Bytes[1h])"},
+	}
+	_, err = getMetricType(params)
+	assert.Error(t, err)
+}
+
+func TestGetMetricFunction(t *testing.T) {
+	for _, mf := range []string{"p50", "p95", "p999", "p75", "stddev", "last", "rate"} {
+		params := url.Values{
+			metricFunctionKey: []string{mf},
+		}
+		m, err := getMetricFunction(params)
+		assert.NoError(t, err)
+		assert.Equal(t, constants.MetricFunction(mf), m)
+	}
+
+	// Default
+	params := url.Values{}
+	m, err := getMetricFunction(params)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.DefaultMetricFunction, m)
+
+	// Invalid
+	for _, mf := range []string{"p0", "p9x", "p100", "p500", "median"} {
+		params = url.Values{
+			metricFunctionKey: []string{mf},
+		}
+		_, err = getMetricFunction(params)
+		assert.Error(t, err, mf)
+	}
 }
 
 func TestGetPacketLoss(t *testing.T) {
@@ -148,6 +213,15 @@ func TestGetStep(t *testing.T) {
 	assert.Equal(t, "10s", step)
 	assert.Equal(t, 10*time.Second, sd)
 
+	// Days
+	params = url.Values{
+		stepKey: []string{"1d"},
+	}
+	step, sd, err = getStep(params)
+	assert.NoError(t, err)
+	assert.Equal(t, "1d", step)
+	assert.Equal(t, 24*time.Hour, sd)
+
 	// Invalid
 	params = url.Values{
 		stepKey: []string{"invalid"},
@@ -163,3 +237,105 @@ func TestGetStep(t *testing.T) {
 	assert.Equal(t, defaultStep, step)
 	assert.Equal(t, defaultStepDuration, sd)
 }
+
+func TestGetCompareTo(t *testing.T) {
+	// Valid
+	params := url.Values{
+		compareToKey: []string{"1w"},
+	}
+	d, err := getCompareTo(params)
+	assert.NoError(t, err)
+	assert.Equal(t, 7*24*time.Hour, d)
+
+	// Invalid
+	params = url.Values{
+		compareToKey: []string{"-1h"},
+	}
+	_, err = getCompareTo(params)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid compareTo")
+
+	// Default: no comparison
+	params = url.Values{}
+	d, err = getCompareTo(params)
+	assert.NoError(t, err)
+	assert.Zero(t, d)
+}
+
+func TestGetLookback(t *testing.T) {
+	// Valid
+	params := url.Values{
+		lookbackKey: []string{"2d"},
+	}
+	d, err := getLookback(params)
+	assert.NoError(t, err)
+	assert.Equal(t, 48*time.Hour, d)
+
+	// Invalid
+	params = url.Values{
+		lookbackKey: []string{"invalid"},
+	}
+	_, err = getLookback(params)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid lookback")
+
+	// Default
+	params = url.Values{}
+	d, err = getLookback(params)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultLookback, d)
+}
+
+func TestGetFilters(t *testing.T) {
+	// Legacy syntax by default
+	params := url.Values{
+		filtersKey: []string{url.QueryEscape("SrcPort=80|DstPort=80")},
+	}
+	groups, err := getFilters(params)
+	assert.NoError(t, err)
+	assert.Len(t, groups, 2)
+
+	// Expression syntax
+	params = url.Values{
+		filtersKey:      []string{`SrcPort = 80 or DstPort = 80`},
+		filterSyntaxKey: []string{filterSyntaxExpression},
+	}
+	groups, err = getFilters(params)
+	assert.NoError(t, err)
+	assert.Len(t, groups, 2)
+
+	// Expression syntax error
+	params = url.Values{
+		filtersKey:      []string{`SrcPort = 80 or`},
+		filterSyntaxKey: []string{filterSyntaxExpression},
+	}
+	_, err = getFilters(params)
+	assert.ErrorContains(t, err, "position 15")
+
+	// Invalid syntax
+	params = url.Values{
+		filterSyntaxKey: []string{"foo"},
+	}
+	_, err = getFilters(params)
+	assert.Error(t, err)
+
+	// Patterns are case-insensitive and regexes case-sensitive by default
+	params = url.Values{
+		filtersKey: []string{url.QueryEscape("SrcK8S_Name=api&DstK8S_Name=~db-.*")},
+	}
+	groups, err = getFilters(params)
+	assert.NoError(t, err)
+	assert.False(t, groups[0][0].CaseSensitive)
+	assert.True(t, groups[0][1].CaseSensitive)
+
+	// unless the flag is set
+	params.Set(caseSensitiveKey, "false")
+	groups, err = getFilters(params)
+	assert.NoError(t, err)
+	assert.False(t, groups[0][0].CaseSensitive)
+	assert.False(t, groups[0][1].CaseSensitive)
+
+	params.Set(caseSensitiveKey, "yes")
+	_, err = getFilters(params)
+	assert.EqualError(t, err, "invalid caseSensitive: yes")
+}
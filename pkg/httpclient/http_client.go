@@ -6,7 +6,9 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -14,6 +16,7 @@ import (
 
 type Caller interface {
 	Get(url string) ([]byte, int, error)
+	Post(url string, form url.Values) ([]byte, int, error)
 }
 
 type httpClient struct {
@@ -74,6 +77,20 @@ func (hc *httpClient) Get(url string) ([]byte, int, error) {
 	if err != nil {
 		return nil, 0, err
 	}
+	return hc.do(req)
+}
+
+// Post sends the provided form as an url-encoded body
+func (hc *httpClient) Post(url string, form url.Values) ([]byte, int, error) {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return hc.do(req)
+}
+
+func (hc *httpClient) do(req *http.Request) ([]byte, int, error) {
 	for k, v := range hc.headers {
 		req.Header[k] = v
 	}
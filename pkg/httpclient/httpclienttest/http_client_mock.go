@@ -1,6 +1,8 @@
 package httpclienttest
 
 import (
+	"net/url"
+
 	"github.com/stretchr/testify/mock"
 )
 
@@ -18,3 +20,14 @@ func (o *HTTPClientMock) SpyURL(fn func(url string)) {
 		Run(func(args mock.Arguments) { fn(args[0].(string)) }).
 		Return([]byte{}, 0, nil)
 }
+
+func (o *HTTPClientMock) Post(url string, form url.Values) ([]byte, int, error) {
+	args := o.Called(url, form)
+	return args.Get(0).([]byte), args.Int(1), args.Error(2)
+}
+
+func (o *HTTPClientMock) SpyPost(fn func(url string, form url.Values)) {
+	o.On("Post", mock.AnythingOfType("string"), mock.AnythingOfType("url.Values")).
+		Run(func(args mock.Arguments) { fn(args[0].(string), args[1].(url.Values)) }).
+		Return([]byte{}, 0, nil)
+}
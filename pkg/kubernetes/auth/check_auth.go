@@ -23,11 +23,17 @@ const (
 	CheckAdmin         CheckType = "admin"
 	CheckDenyAll       CheckType = "denyAll"
 	CheckNone          CheckType = "none"
+	// AnonymousUser is the user name returned when auth is disabled
+	AnonymousUser = "system:anonymous"
 )
 
 type Checker interface {
 	CheckAuth(ctx context.Context, header http.Header) error
 	CheckAdmin(ctx context.Context, header http.Header) error
+	// GetUser returns the name of the authenticated user
+	GetUser(ctx context.Context, header http.Header) (string, error)
+	// CheckNamespace checks that the user has access to the namespace
+	CheckNamespace(ctx context.Context, header http.Header, namespace string) error
 }
 
 func NewChecker(typez CheckType, apiProvider client.APIProvider) (Checker, error) {
@@ -59,6 +65,16 @@ func (b *NoopChecker) CheckAdmin(_ context.Context, _ http.Header) error {
 	return nil
 }
 
+func (b *NoopChecker) GetUser(_ context.Context, _ http.Header) (string, error) {
+	hlog.Debug("noop auth checker: anonymous user")
+	return AnonymousUser, nil
+}
+
+func (b *NoopChecker) CheckNamespace(_ context.Context, _ http.Header, _ string) error {
+	hlog.Debug("noop auth checker: ignore auth")
+	return nil
+}
+
 type DenyAllChecker struct {
 	Checker
 }
@@ -73,6 +89,16 @@ func (b *DenyAllChecker) CheckAdmin(_ context.Context, _ http.Header) error {
 	return errors.New("deny all auth mode selected")
 }
 
+func (b *DenyAllChecker) GetUser(_ context.Context, _ http.Header) (string, error) {
+	hlog.Debug("deny all auth checker: deny auth")
+	return "", errors.New("deny all auth mode selected")
+}
+
+func (b *DenyAllChecker) CheckNamespace(_ context.Context, _ http.Header, _ string) error {
+	hlog.Debug("deny all auth checker: deny auth")
+	return errors.New("deny all auth mode selected")
+}
+
 func getUserToken(header http.Header) (string, error) {
 	authValue := header.Get(AuthHeader)
 	if authValue != "" {
@@ -101,18 +127,23 @@ func runTokenReview(ctx context.Context, apiProvider client.APIProvider, token s
 type authPredicate func(context.Context, client.KubeAPI, string) error
 
 func mustBeAuthenticated(ctx context.Context, cl client.KubeAPI, token string) error {
+	_, err := reviewToken(ctx, cl, token)
+	return err
+}
+
+func reviewToken(ctx context.Context, cl client.KubeAPI, token string) (*authv1.TokenReview, error) {
 	rvw, err := cl.CreateTokenReview(ctx, &authv1.TokenReview{
 		Spec: authv1.TokenReviewSpec{
 			Token: token,
 		},
 	}, &metav1.CreateOptions{})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if !rvw.Status.Authenticated {
-		return errors.New("user not authenticated")
+		return nil, errors.New("user not authenticated")
 	}
-	return nil
+	return rvw, nil
 }
 
 func mustBeClusterAdmin(ctx context.Context, cl client.KubeAPI, token string) error {
@@ -161,3 +192,36 @@ func (c *BearerTokenChecker) CheckAdmin(ctx context.Context, header http.Header)
 	hlog.Debug("Checking admin: passed")
 	return nil
 }
+
+func (c *BearerTokenChecker) GetUser(ctx context.Context, header http.Header) (string, error) {
+	hlog.Debug("Getting user")
+	token, err := getUserToken(header)
+	if err != nil {
+		return "", err
+	}
+	client, err := c.apiProvider()
+	if err != nil {
+		return "", err
+	}
+	rvw, err := reviewToken(ctx, client, token)
+	if err != nil {
+		return "", err
+	}
+	return rvw.Status.User.Username, nil
+}
+
+func (c *BearerTokenChecker) CheckNamespace(ctx context.Context, header http.Header, namespace string) error {
+	hlog.Debugf("Checking access to namespace %s", namespace)
+	token, err := getUserToken(header)
+	if err != nil {
+		return err
+	}
+	client, err := c.apiProvider()
+	if err != nil {
+		return err
+	}
+	if err = client.CheckNamespaceAccess(ctx, token, namespace); err != nil {
+		return fmt.Errorf("user cannot access namespace %s", namespace)
+	}
+	return nil
+}
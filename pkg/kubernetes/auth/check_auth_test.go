@@ -169,6 +169,49 @@ func TestCheckAuth_APIError(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestGetUser(t *testing.T) {
+	m := AuthCheckMock{}
+	m.mockNormalUser()
+	checker := setupChecker(CheckAuthenticated, &m)
+
+	// No header => fail
+	_, err := checker.GetUser(context.TODO(), http.Header{})
+	require.Error(t, err)
+
+	user, err := checker.GetUser(context.TODO(), http.Header{"Authorization": []string{"Bearer abcdef"}})
+	require.NoError(t, err)
+	require.Equal(t, "user1", user)
+
+	// Not authenticated => fail
+	m = AuthCheckMock{}
+	m.mockNoAuth()
+	checker = setupChecker(CheckAuthenticated, &m)
+	_, err = checker.GetUser(context.TODO(), http.Header{"Authorization": []string{"Bearer abcdef"}})
+	require.Error(t, err)
+	require.Equal(t, "user not authenticated", err.Error())
+
+	// Noop mode => anonymous
+	user, err = (&NoopChecker{}).GetUser(context.TODO(), http.Header{})
+	require.NoError(t, err)
+	require.Equal(t, AnonymousUser, user)
+}
+
+func TestCheckNamespace(t *testing.T) {
+	m := AuthCheckMock{}
+	m.On("CheckNamespaceAccess", mock.Anything, "abcdef", "allowed").Return(nil)
+	m.On("CheckNamespaceAccess", mock.Anything, "abcdef", "forbidden").Return(errors.New("forbidden"))
+	checker := setupChecker(CheckAuthenticated, &m)
+
+	header := http.Header{"Authorization": []string{"Bearer abcdef"}}
+	require.NoError(t, checker.CheckNamespace(context.TODO(), header, "allowed"))
+	err := checker.CheckNamespace(context.TODO(), header, "forbidden")
+	require.Error(t, err)
+	require.Equal(t, "user cannot access namespace forbidden", err.Error())
+
+	// Deny All mode
+	require.Error(t, (&DenyAllChecker{}).CheckNamespace(context.TODO(), header, "allowed"))
+}
+
 type AuthCheckMock struct {
 	mock.Mock
 	client.KubeAPI
@@ -184,6 +227,11 @@ func (m *AuthCheckMock) CheckAdmin(ctx context.Context, token string) error {
 	return args.Error(0)
 }
 
+func (m *AuthCheckMock) CheckNamespaceAccess(ctx context.Context, token, namespace string) error {
+	args := m.Called(ctx, token, namespace)
+	return args.Error(0)
+}
+
 func (m *AuthCheckMock) mockError() {
 	m.On("CreateTokenReview", mock.Anything, mock.Anything, mock.Anything).Return(&authv1.TokenReview{}, errors.New(fakeError))
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	authv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -13,6 +14,14 @@ import (
 type KubeAPI interface {
 	CreateTokenReview(ctx context.Context, tr *authv1.TokenReview, opts *metav1.CreateOptions) (*authv1.TokenReview, error)
 	CheckAdmin(ctx context.Context, token string) error
+	CheckNamespaceAccess(ctx context.Context, token, namespace string) error
+}
+
+// ConfigMapAPI gives access to config maps, using the plugin service account
+type ConfigMapAPI interface {
+	GetConfigMap(ctx context.Context, namespace, name string) (*corev1.ConfigMap, error)
+	CreateConfigMap(ctx context.Context, cm *corev1.ConfigMap) (*corev1.ConfigMap, error)
+	UpdateConfigMap(ctx context.Context, cm *corev1.ConfigMap) (*corev1.ConfigMap, error)
 }
 
 type APIProvider func() (KubeAPI, error)
@@ -27,22 +36,65 @@ func (c *InCluster) CreateTokenReview(ctx context.Context, tr *authv1.TokenRevie
 }
 
 func (c *InCluster) CheckAdmin(ctx context.Context, token string) error {
-	config, err := rest.InClusterConfig()
+	client, err := newUserClient(token)
 	if err != nil {
 		return err
 	}
-	config.BearerToken = token
-	config.BearerTokenFile = ""
+	_, err = client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	return err
+}
 
-	client, err := kubernetes.NewForConfig(config)
+// CheckNamespaceAccess checks that the user can list pods in the namespace
+func (c *InCluster) CheckNamespaceAccess(ctx context.Context, token, namespace string) error {
+	client, err := newUserClient(token)
 	if err != nil {
 		return err
 	}
-	_, err = client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	_, err = client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{Limit: 1})
 	return err
 }
 
+func (c *InCluster) GetConfigMap(ctx context.Context, namespace, name string) (*corev1.ConfigMap, error) {
+	return c.client.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (c *InCluster) CreateConfigMap(ctx context.Context, cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	return c.client.CoreV1().ConfigMaps(cm.Namespace).Create(ctx, cm, metav1.CreateOptions{})
+}
+
+func (c *InCluster) UpdateConfigMap(ctx context.Context, cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	return c.client.CoreV1().ConfigMaps(cm.Namespace).Update(ctx, cm, metav1.UpdateOptions{})
+}
+
+// newUserClient creates a client acting on behalf of the user
+func newUserClient(token string) (*kubernetes.Clientset, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.BearerToken = token
+	config.BearerTokenFile = ""
+	return kubernetes.NewForConfig(config)
+}
+
 func NewInCluster() (KubeAPI, error) {
+	return newInCluster()
+}
+
+func NewInClusterConfigMaps() (ConfigMapAPI, error) {
+	return newInCluster()
+}
+
+// NewInClusterClientset returns a clientset using the plugin service account, e.g. to feed shared informers
+func NewInClusterClientset() (kubernetes.Interface, error) {
+	c, err := newInCluster()
+	if err != nil {
+		return nil, err
+	}
+	return c.client, nil
+}
+
+func newInCluster() (*InCluster, error) {
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		return nil, err
@@ -0,0 +1,135 @@
+// Package podlabels resolves pod label selectors into the workloads found in flows, using a shared informer
+// on pods
+package podlabels
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+var log = logrus.WithField("module", "podlabels")
+
+// podTemplateHashLabel is set by deployments on their replica sets and pods
+const podTemplateHashLabel = "pod-template-hash"
+
+// Workloads are the pods matching a selector in a namespace, and their owners as written in flows
+type Workloads struct {
+	Owners []string `json:"owners"`
+	Pods   []string `json:"pods"`
+}
+
+// Cache keeps the labels and owners of all pods, from a shared informer
+type Cache struct {
+	factory informers.SharedInformerFactory
+	lister  listersv1.PodLister
+	synced  cache.InformerSynced
+}
+
+func NewCache(clientset kubernetes.Interface) *Cache {
+	factory := informers.NewSharedInformerFactory(clientset, 0)
+	pods := factory.Core().V1().Pods()
+	informer := pods.Informer()
+	// only names, labels and owners are needed: drop the rest to save memory
+	if err := informer.SetTransform(stripPod); err != nil {
+		log.WithError(err).Warn("Can't set pods transform")
+	}
+	return &Cache{factory: factory, lister: pods.Lister(), synced: informer.HasSynced}
+}
+
+// Start runs the informer in background, until the context is done
+func (c *Cache) Start(ctx context.Context) {
+	c.factory.Start(ctx.Done())
+	go func() {
+		if !cache.WaitForCacheSync(ctx.Done(), c.synced) {
+			log.Debug("Pods informer stopped before sync")
+			return
+		}
+		log.Debug("Pods informer synced")
+	}()
+}
+
+func stripPod(obj interface{}) (interface{}, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return obj, nil
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       pod.Namespace,
+			Name:            pod.Name,
+			ResourceVersion: pod.ResourceVersion,
+			Labels:          pod.Labels,
+			OwnerReferences: pod.OwnerReferences,
+		},
+	}, nil
+}
+
+// podOwner returns the owner name as written in flows: the deployment for pods of its replica sets,
+// else the controller, else the pod itself
+func podOwner(pod *corev1.Pod) string {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		return pod.Name
+	}
+	if ref.Kind == "ReplicaSet" {
+		// replica sets created by deployments are named after the deployment and the pod template hash
+		if hash := pod.Labels[podTemplateHashLabel]; hash != "" && strings.HasSuffix(ref.Name, "-"+hash) {
+			return strings.TrimSuffix(ref.Name, "-"+hash)
+		}
+	}
+	return ref.Name
+}
+
+// Match returns the workloads per namespace of the pods matching any of the selectors
+func (c *Cache) Match(selectors []labels.Selector) (map[string]Workloads, error) {
+	if !c.synced() {
+		return nil, errors.New("pod labels are not available yet, try again later")
+	}
+	pods, err := c.lister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	owners := map[string]map[string]struct{}{}
+	matches := map[string]Workloads{}
+	for _, pod := range pods {
+		if !matchesAny(selectors, labels.Set(pod.Labels)) {
+			continue
+		}
+		if owners[pod.Namespace] == nil {
+			owners[pod.Namespace] = map[string]struct{}{}
+		}
+		owners[pod.Namespace][podOwner(pod)] = struct{}{}
+		w := matches[pod.Namespace]
+		w.Pods = append(w.Pods, pod.Name)
+		matches[pod.Namespace] = w
+	}
+	for ns, w := range matches {
+		for owner := range owners[ns] {
+			w.Owners = append(w.Owners, owner)
+		}
+		sort.Strings(w.Owners)
+		sort.Strings(w.Pods)
+		matches[ns] = w
+	}
+	return matches, nil
+}
+
+func matchesAny(selectors []labels.Selector, set labels.Set) bool {
+	for _, selector := range selectors {
+		if selector.Matches(set) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package podlabels
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newPod(ns, name string, podLabels map[string]string, ownerKind, ownerName string) corev1.Pod {
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name, Labels: podLabels}}
+	if ownerKind != "" {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: ownerKind, Name: ownerName, Controller: &controller}}
+	}
+	return pod
+}
+
+func testPods() []corev1.Pod {
+	return []corev1.Pod{
+		newPod("shop", "checkout-7d9f8b-x1", map[string]string{"app": "checkout", "pod-template-hash": "7d9f8b"}, "ReplicaSet", "checkout-7d9f8b"),
+		newPod("shop", "checkout-7d9f8b-x2", map[string]string{"app": "checkout", "pod-template-hash": "7d9f8b"}, "ReplicaSet", "checkout-7d9f8b"),
+		newPod("shop", "cart-0", map[string]string{"app": "cart", "tier": "backend"}, "StatefulSet", "cart"),
+		newPod("shop", "debug", map[string]string{"app": "checkout"}, "", ""),
+		newPod("payments", "gateway-abc", map[string]string{"app": "checkout", "tier": "backend"}, "DaemonSet", "gateway"),
+	}
+}
+
+func podObjects(pods []corev1.Pod) []runtime.Object {
+	objects := make([]runtime.Object, 0, len(pods))
+	for i := range pods {
+		objects = append(objects, &pods[i])
+	}
+	return objects
+}
+
+// startedCache starts a cache on the given clientset and waits for it to be synced
+func startedCache(t *testing.T, clientset *fake.Clientset) *Cache {
+	cache := NewCache(clientset)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	cache.Start(ctx)
+	require.Eventually(t, func() bool {
+		_, err := cache.Match(nil)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	return cache
+}
+
+func TestCache_Match(t *testing.T) {
+	clientset := fake.NewSimpleClientset(podObjects(testPods())...)
+
+	// not synced yet
+	_, err := NewCache(clientset).Match([]labels.Selector{labels.Everything()})
+	require.Error(t, err)
+
+	cache := startedCache(t, clientset)
+	selector, err := labels.Parse("app=checkout")
+	require.NoError(t, err)
+	matches, err := cache.Match([]labels.Selector{selector})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Workloads{
+		"shop":     {Owners: []string{"checkout", "debug"}, Pods: []string{"checkout-7d9f8b-x1", "checkout-7d9f8b-x2", "debug"}},
+		"payments": {Owners: []string{"gateway"}, Pods: []string{"gateway-abc"}},
+	}, matches)
+
+	// changes are watched
+	newCart := newPod("shop", "cart-1", map[string]string{"app": "checkout"}, "StatefulSet", "cart")
+	_, err = clientset.CoreV1().Pods("shop").Create(context.Background(), &newCart, metav1.CreateOptions{})
+	require.NoError(t, err)
+	err = clientset.CoreV1().Pods("payments").Delete(context.Background(), "gateway-abc", metav1.DeleteOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		matches, _ := cache.Match([]labels.Selector{selector})
+		_, hasPayments := matches["payments"]
+		return !hasPayments && len(matches["shop"].Owners) == 3
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPodOwner(t *testing.T) {
+	pods := testPods()
+	assert.Equal(t, "checkout", podOwner(&pods[0]))
+	assert.Equal(t, "cart", podOwner(&pods[2]))
+	assert.Equal(t, "debug", podOwner(&pods[3]))
+
+	// replica set not created by a deployment
+	pod := newPod("ns", "rs-x1", map[string]string{}, "ReplicaSet", "rs")
+	assert.Equal(t, "rs", podOwner(&pod))
+}
@@ -0,0 +1,226 @@
+package podlabels
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+// ExpandedKeys returns the keys of the filters a label selector filter is expanded into. The second value is false
+// if the key is not a label selector
+func ExpandedKeys(key string) ([]string, bool) {
+	prefix, ok := selectorPrefix(key)
+	if !ok {
+		return nil, false
+	}
+	return []string{prefix + fields.Namespace, prefix + fields.OwnerName}, true
+}
+
+// HasSelectors returns true if any of the filters is a label selector
+func HasSelectors(mq filters.MultiQueries) bool {
+	for _, group := range mq {
+		for i := range group {
+			if _, ok := selectorPrefix(group[i].Key); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ExpandFilters replaces label selector filters by filters on the namespaces and owner names of the matching pods,
+// before queries are built. A selector matching pods of several namespaces results in several groups.
+func (c *Cache) ExpandFilters(mq filters.MultiQueries) (filters.MultiQueries, error) {
+	var expanded filters.MultiQueries
+	for _, group := range mq {
+		groups, err := c.expandGroup(group)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, groups...)
+		if len(expanded) > filters.MaxGroups {
+			return nil, tooManyGroupsError()
+		}
+	}
+	if len(expanded) == 0 {
+		return nil, fmt.Errorf("no pod matches the label selector filters")
+	}
+	return expanded, nil
+}
+
+// expandGroup returns the alternative groups replacing the group, which are none when a selector matches no pod
+func (c *Cache) expandGroup(group filters.SingleQuery) (filters.MultiQueries, error) {
+	result := filters.MultiQueries{{}}
+	scopes := namespaceScopes(group)
+	for i := range group {
+		prefix, ok := selectorPrefix(group[i].Key)
+		if !ok {
+			for j := range result {
+				result[j] = append(result[j], group[i])
+			}
+			continue
+		}
+		alternatives, err := c.selectorAlternatives(prefix, &group[i], scopes[prefix])
+		if err != nil {
+			return nil, err
+		}
+		if len(result)*len(alternatives) > filters.MaxGroups {
+			return nil, tooManyGroupsError()
+		}
+		var product filters.MultiQueries
+		for _, r := range result {
+			for _, alt := range alternatives {
+				product = append(product, append(append(filters.SingleQuery{}, r...), alt...))
+			}
+		}
+		result = product
+	}
+	return result, nil
+}
+
+// selectorAlternatives returns the filters equivalent to a selector filter, in disjunctive form.
+// Namespaces outside of the scope, when set, are ignored.
+func (c *Cache) selectorAlternatives(prefix string, m *filters.Match, scope map[string]bool) (filters.MultiQueries, error) {
+	selectors, err := ParseSelectors(m)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := c.Match(selectors)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := make([]string, 0, len(matches))
+	for ns := range matches {
+		if scope == nil || scope[ns] {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	sort.Strings(namespaces)
+
+	nsKey, ownerKey := prefix+fields.Namespace, prefix+fields.OwnerName
+	if !m.Not {
+		// (namespace1 AND owners1) OR (namespace2 AND owners2)...
+		alternatives := make(filters.MultiQueries, 0, len(namespaces))
+		for _, ns := range namespaces {
+			alternatives = append(alternatives, filters.SingleQuery{
+				filters.NewMatch(nsKey, exactValues([]string{ns})),
+				filters.NewMatch(ownerKey, exactValues(matches[ns].Owners)),
+			})
+		}
+		return alternatives, nil
+	}
+	if len(namespaces) == 0 {
+		return filters.MultiQueries{{}}, nil
+	}
+	// (NOT namespace1 OR NOT owners1) AND (NOT namespace2 OR NOT owners2)... is, as namespaces are distinct:
+	// (NOT namespaces) OR (namespace1 AND NOT owners1) OR (namespace2 AND NOT owners2)...
+	alternatives := make(filters.MultiQueries, 0, len(namespaces)+1)
+	if scope == nil || len(namespaces) < len(scope) {
+		alternatives = append(alternatives, filters.SingleQuery{filters.NewNotMatch(nsKey, exactValues(namespaces))})
+	}
+	for _, ns := range namespaces {
+		alternatives = append(alternatives, filters.SingleQuery{
+			filters.NewMatch(nsKey, exactValues([]string{ns})),
+			filters.NewNotMatch(ownerKey, exactValues(matches[ns].Owners)),
+		})
+	}
+	return alternatives, nil
+}
+
+// ParseSelectors reads the selectors of a label selector filter. Exact values are OR'ed selectors, such as written with the
+// IN operator of filter expressions; otherwise values are a single selector in which commas are AND'ed requirements
+func ParseSelectors(m *filters.Match) ([]labels.Selector, error) {
+	if m.IsComparison() {
+		return nil, fmt.Errorf("numeric comparison is not supported on label selector %s", m.Key)
+	}
+	if m.Regex {
+		return nil, fmt.Errorf("regular expression is not supported on label selector %s", m.Key)
+	}
+	values := m.SplitValues()
+	raws := make([]string, 0, len(values))
+	allExact := true
+	for _, value := range values {
+		raw, ok := unquoteExact(value)
+		if !ok {
+			allExact = false
+			break
+		}
+		raws = append(raws, raw)
+	}
+	if !allExact {
+		raws = []string{strings.Trim(strings.Join(values, ","), `"`)}
+	}
+	selectors := make([]labels.Selector, 0, len(raws))
+	for _, raw := range raws {
+		if strings.TrimSpace(raw) == "" {
+			return nil, fmt.Errorf("empty label selector in %s filter", m.Key)
+		}
+		selector, err := labels.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector in %s filter: %w", m.Key, err)
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+// namespaceScopes returns the namespaces the group is restricted to by exact namespace filters, per prefix
+func namespaceScopes(group filters.SingleQuery) map[string]map[string]bool {
+	scopes := map[string]map[string]bool{}
+	for _, prefix := range []string{fields.Src, fields.Dst} {
+		for i := range group {
+			m := &group[i]
+			if m.Key != prefix+fields.Namespace || m.Not || m.Regex || m.IsComparison() {
+				continue
+			}
+			scope := map[string]bool{}
+			for _, value := range m.SplitValues() {
+				ns, ok := unquoteExact(value)
+				if !ok {
+					// partial matches can't be resolved
+					scope = nil
+					break
+				}
+				scope[ns] = true
+			}
+			if scope != nil {
+				scopes[prefix] = scope
+			}
+		}
+	}
+	return scopes
+}
+
+func selectorPrefix(key string) (string, bool) {
+	switch key {
+	case fields.SrcLabelSelector:
+		return fields.Src, true
+	case fields.DstLabelSelector:
+		return fields.Dst, true
+	}
+	return "", false
+}
+
+func unquoteExact(value string) (string, bool) {
+	if len(value) < 2 || !strings.HasPrefix(value, `"`) || !strings.HasSuffix(value, `"`) {
+		return "", false
+	}
+	return value[1 : len(value)-1], true
+}
+
+func exactValues(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, `"`+filters.EscapeValue(v)+`"`)
+	}
+	return strings.Join(quoted, ",")
+}
+
+func tooManyGroupsError() error {
+	return fmt.Errorf("label selector filters match pods in too many namespaces: more than %d alternatives, try adding a namespace filter", filters.MaxGroups)
+}
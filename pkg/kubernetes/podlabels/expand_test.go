@@ -0,0 +1,118 @@
+package podlabels
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+func syncedCache(t *testing.T) *Cache {
+	return startedCache(t, fake.NewSimpleClientset(podObjects(testPods())...))
+}
+
+func TestExpandFilters(t *testing.T) {
+	cache := syncedCache(t)
+	expanded, err := cache.ExpandFilters(filters.MultiQueries{
+		{filters.NewMatch("SrcK8S_LabelSelector", `"app=checkout"`), filters.NewMatch("DstPort", "443")},
+		{filters.NewMatch("Proto", "17")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{
+		{
+			filters.NewMatch("SrcK8S_Namespace", `"payments"`),
+			filters.NewMatch("SrcK8S_OwnerName", `"gateway"`),
+			filters.NewMatch("DstPort", "443"),
+		},
+		{
+			filters.NewMatch("SrcK8S_Namespace", `"shop"`),
+			filters.NewMatch("SrcK8S_OwnerName", `"checkout","debug"`),
+			filters.NewMatch("DstPort", "443"),
+		},
+		{filters.NewMatch("Proto", "17")},
+	}, expanded)
+}
+
+func TestExpandFilters_Selectors(t *testing.T) {
+	cache := syncedCache(t)
+
+	// commas are AND'ed requirements
+	expanded, err := cache.ExpandFilters(filters.MultiQueries{{filters.NewMatch("DstK8S_LabelSelector", "app in (checkout,cart),tier=backend")}})
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{
+		{filters.NewMatch("DstK8S_Namespace", `"payments"`), filters.NewMatch("DstK8S_OwnerName", `"gateway"`)},
+		{filters.NewMatch("DstK8S_Namespace", `"shop"`), filters.NewMatch("DstK8S_OwnerName", `"cart"`)},
+	}, expanded)
+
+	// exact values are OR'ed selectors, with escaped commas; namespace filters restrict the expansion
+	expanded, err = cache.ExpandFilters(filters.MultiQueries{{
+		filters.NewMatch("DstK8S_Namespace", `"shop"`),
+		filters.NewMatch("DstK8S_LabelSelector", `"app=cart","app=checkout\,pod-template-hash"`),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{
+		{filters.NewMatch("DstK8S_Namespace", `"shop"`), filters.NewMatch("DstK8S_Namespace", `"shop"`), filters.NewMatch("DstK8S_OwnerName", `"cart","checkout"`)},
+	}, expanded)
+}
+
+func TestExpandFilters_Not(t *testing.T) {
+	cache := syncedCache(t)
+	expanded, err := cache.ExpandFilters(filters.MultiQueries{{filters.NewNotMatch("SrcK8S_LabelSelector", `"tier=backend"`)}})
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{
+		{filters.NewNotMatch("SrcK8S_Namespace", `"payments","shop"`)},
+		{filters.NewMatch("SrcK8S_Namespace", `"payments"`), filters.NewNotMatch("SrcK8S_OwnerName", `"gateway"`)},
+		{filters.NewMatch("SrcK8S_Namespace", `"shop"`), filters.NewNotMatch("SrcK8S_OwnerName", `"cart"`)},
+	}, expanded)
+
+	// all namespaces in scope are matched
+	expanded, err = cache.ExpandFilters(filters.MultiQueries{{filters.NewMatch("SrcK8S_Namespace", `"shop"`), filters.NewNotMatch("SrcK8S_LabelSelector", `"tier=backend"`)}})
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{
+		{filters.NewMatch("SrcK8S_Namespace", `"shop"`), filters.NewMatch("SrcK8S_Namespace", `"shop"`), filters.NewNotMatch("SrcK8S_OwnerName", `"cart"`)},
+	}, expanded)
+
+	// nothing to exclude
+	expanded, err = cache.ExpandFilters(filters.MultiQueries{{filters.NewNotMatch("SrcK8S_LabelSelector", `"app=unknown"`), filters.NewMatch("Proto", "6")}})
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{{filters.NewMatch("Proto", "6")}}, expanded)
+}
+
+func TestExpandFilters_NotManyNamespaces(t *testing.T) {
+	var pods []corev1.Pod
+	for i := 0; i < 10; i++ {
+		pods = append(pods, newPod(fmt.Sprintf("ns%d", i), "db-0", map[string]string{"tier": "backend"}, "StatefulSet", "db"))
+	}
+	cache := startedCache(t, fake.NewSimpleClientset(podObjects(pods)...))
+	expanded, err := cache.ExpandFilters(filters.MultiQueries{{filters.NewNotMatch("SrcK8S_LabelSelector", `"tier=backend"`)}})
+	require.NoError(t, err)
+	require.Len(t, expanded, 11)
+	assert.Equal(t, filters.SingleQuery{filters.NewNotMatch("SrcK8S_Namespace", `"ns0","ns1","ns2","ns3","ns4","ns5","ns6","ns7","ns8","ns9"`)}, expanded[0])
+	assert.Equal(t, filters.SingleQuery{filters.NewMatch("SrcK8S_Namespace", `"ns9"`), filters.NewNotMatch("SrcK8S_OwnerName", `"db"`)}, expanded[10])
+}
+
+func TestExpandFilters_Errors(t *testing.T) {
+	cache := syncedCache(t)
+
+	_, err := cache.ExpandFilters(filters.MultiQueries{{filters.NewMatch("SrcK8S_LabelSelector", `"app=unknown"`)}})
+	assert.EqualError(t, err, "no pod matches the label selector filters")
+
+	_, err = cache.ExpandFilters(filters.MultiQueries{{filters.NewMatch("SrcK8S_LabelSelector", `"app in checkout"`)}})
+	assert.ErrorContains(t, err, "invalid label selector in SrcK8S_LabelSelector filter")
+
+	_, err = cache.ExpandFilters(filters.MultiQueries{{filters.NewMoreThanOrEqualMatch("SrcK8S_LabelSelector", "1")}})
+	assert.EqualError(t, err, "numeric comparison is not supported on label selector SrcK8S_LabelSelector")
+
+	// a group matching nothing is dropped
+	expanded, err := cache.ExpandFilters(filters.MultiQueries{
+		{filters.NewMatch("SrcK8S_LabelSelector", `"app=unknown"`)},
+		{filters.NewMatch("Proto", "6")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, filters.MultiQueries{{filters.NewMatch("Proto", "6")}}, expanded)
+}
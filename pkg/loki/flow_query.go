@@ -3,7 +3,8 @@ package loki
 
 import (
 	"fmt"
-	"regexp"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/config"
@@ -14,16 +15,28 @@ import (
 
 const (
 	recordTypeField = "_RecordType"
+	timeParam       = "time"
 	startParam      = "start"
 	endParam        = "end"
 	limitParam      = "limit"
 	queryRangePath  = "/loki/api/v1/query_range?query="
-	jsonOrJoiner    = "+or+"
+	queryPath       = "/loki/api/v1/query?query="
+	labelValuesPath = "/loki/api/v1/label/%s/values?query="
+	jsonOrJoiner    = " or "
 	emptyMatch      = `""`
 )
 
-// can contains only alphanumeric / '-' / '_' / '.' / ',' / '"' / '*' / ':' / '/' characteres
-var filterRegexpValidation = regexp.MustCompile(`^[\w-_.,\"*:/]*$`)
+// FilterTarget tells how a filter is written in LogQL queries
+type FilterTarget string
+
+const (
+	// FilterTargetLabel filters are stream selectors on indexed labels, the most efficient ones
+	FilterTargetLabel FilterTarget = "label"
+	// FilterTargetLine filters are text matches on raw log lines
+	FilterTargetLine FilterTarget = "line"
+	// FilterTargetJSON filters require parsing the log lines as JSON, the least efficient ones
+	FilterTargetJSON FilterTarget = "json"
+)
 
 // FlowQueryBuilder stores a state to build a LogQL query
 type FlowQueryBuilder struct {
@@ -60,7 +73,7 @@ func NewFlowQueryBuilder(cfg *config.Loki, start, end, limit string, dedup bool,
 		if cfg.IsLabel(fields.Duplicate) {
 			labelFilters = append(labelFilters, filters.NotStringLabelFilter(fields.Duplicate, "true"))
 		} else {
-			lineFilters = append(lineFilters, filters.NewEmptyLineFilter(fields.Duplicate, true, false, true).MatchTrue())
+			lineFilters = append(lineFilters, filters.NewEmptyLineFilter(fields.Duplicate, true, true).MatchTrue())
 		}
 	}
 
@@ -108,51 +121,209 @@ func (q *FlowQueryBuilder) Filters(queryFilters filters.SingleQuery) error {
 }
 
 func (q *FlowQueryBuilder) addFilter(filter filters.Match) error {
-	if !filterRegexpValidation.MatchString(filter.Values) {
-		return fmt.Errorf("unauthorized sign in flows request: %s", filter.Values)
+	// values are escaped when written in the query, but keys are not
+	if !filters.IsValidKey(filter.Key) {
+		return fmt.Errorf("invalid filter key in flows request: %s", filter.Key)
+	}
+	field, err := q.getField(filter.Key)
+	if err != nil {
+		return err
+	}
+	// filter names are resolved to field names
+	filter.Key = field.Name
+
+	values := filter.SplitValues()
+	if filter.IsComparison() {
+		if field.Type != config.FieldTypeNumber {
+			return fmt.Errorf("numeric comparison is not supported on %s field %s in flows request", field.Type, field.Name)
+		}
+		if err := validateNumbers(filter.Key, values); err != nil {
+			return err
+		}
+		return q.addComparisonFilter(filter, values)
+	}
+	if filter.Regex {
+		return q.addRegexFilter(filter, field)
 	}
 
-	values := strings.Split(filter.Values, ",")
+	switch field.Type {
+	case config.FieldTypeNumber, config.FieldTypeNumberArray:
+		if err := validateNumbers(filter.Key, values); err != nil {
+			return err
+		}
+	case config.FieldTypeBoolean:
+		if err := validateBooleans(filter.Key, values); err != nil {
+			return err
+		}
+	}
 
 	// Stream selector labels
 	if q.config.IsLabel(filter.Key) {
 		if lf, ok := filter.ToLabelFilter(); ok {
 			q.labelFilters = append(q.labelFilters, lf)
 		}
-	} else if fields.IsIP(filter.Key) {
+	} else if field.Type == config.FieldTypeIP {
 		q.addIPFilters(filter.Key, values, filter.Not)
 	} else {
-		q.addLineFilters(filter.Key, values, filter.Not, filter.MoreThanOrEqual)
+		q.addLineFilters(filter.Key, field.Type, values, filter.Not, filter.CaseSensitive)
 	}
 
 	return nil
 }
 
-func (q *FlowQueryBuilder) addLineFilters(key string, values []string, not bool, moreThan bool) {
+// CheckFilter validates a filter the same way as flows requests. It returns the field name the filter key
+// resolves to, and how the filter is written in LogQL
+func CheckFilter(cfg *config.Loki, filter filters.Match) (string, FilterTarget, error) {
+	q := &FlowQueryBuilder{config: cfg}
+	if err := q.addFilter(filter); err != nil {
+		return "", "", err
+	}
+	field, _ := q.getField(filter.Key)
+	switch {
+	case len(q.jsonFilters) > 0:
+		return field.Name, FilterTargetJSON, nil
+	case len(q.lineFilters) > 0:
+		return field.Name, FilterTargetLine, nil
+	}
+	return field.Name, FilterTargetLabel, nil
+}
+
+// getField returns the configured field for a filter key. When no field is configured, the type is inferred from built-in fields
+func (q *FlowQueryBuilder) getField(key string) (*config.FieldConfig, error) {
+	if !q.config.HasFields() {
+		return &config.FieldConfig{Name: key, Type: builtInFieldType(key)}, nil
+	}
+	if field, ok := q.config.GetField(key); ok {
+		return field, nil
+	}
+	for i := range q.config.Fields {
+		if strings.EqualFold(q.config.Fields[i].Name, key) {
+			return nil, fmt.Errorf("unknown filter field in flows request: %s (did you mean %s?)", key, q.config.Fields[i].Name)
+		}
+	}
+	return nil, fmt.Errorf("unknown filter field in flows request: %s; filters must use fields defined in the frontend configuration", key)
+}
+
+func builtInFieldType(key string) string {
+	switch {
+	case fields.IsNumeric(key):
+		return config.FieldTypeNumber
+	case fields.IsIP(key):
+		return config.FieldTypeIP
+	case fields.IsArray(key):
+		return config.FieldTypeStringArray
+	}
+	return config.FieldTypeString
+}
+
+// validateNumbers ensures numeric values can be written as is in the query. Empty exact matches are allowed
+func validateNumbers(key string, values []string) error {
+	for _, value := range values {
+		if value == emptyMatch {
+			continue
+		}
+		if !filters.IsValidNumber(strings.Trim(value, `"`)) {
+			return fmt.Errorf("invalid numeric value for %s in flows request: %s", key, value)
+		}
+	}
+	return nil
+}
+
+// validateBooleans ensures boolean values are either true or false
+func validateBooleans(key string, values []string) error {
+	for _, value := range values {
+		if v := strings.Trim(value, `"`); v != "true" && v != "false" {
+			return fmt.Errorf("invalid boolean value for %s in flows request: %s", key, value)
+		}
+	}
+	return nil
+}
+
+// addComparisonFilter adds a numeric comparison as a JSON label filter. Since FLP omits zero values,
+// records without the field are also matched when zero is within bounds. Non-strict keys, such as Port,
+// match either the source or the destination field
+func (q *FlowQueryBuilder) addComparisonFilter(filter filters.Match, values []string) error {
+	if len(values) != 1 || values[0] == emptyMatch {
+		return fmt.Errorf("numeric comparison on %s requires a single value in flows request: %s", filter.Key, filter.Values)
+	}
+	keys := []string{filter.Key}
+	if fields.IsNumeric(fields.Src+filter.Key) && fields.IsNumeric(fields.Dst+filter.Key) {
+		keys = []string{fields.Src + filter.Key, fields.Dst + filter.Key}
+	}
+	value, _ := strconv.ParseFloat(strings.Trim(values[0], `"`), 64)
+	zeroInBounds := (filter.MoreThanOrEqual && value <= 0) || (filter.LessThanOrEqual && value >= 0)
+	var filtersPerKey []filters.LabelFilter
+	for _, key := range keys {
+		keyFilter := filter
+		keyFilter.Key = key
+		lf, _ := keyFilter.ToLabelFilter()
+		filtersPerKey = append(filtersPerKey, lf)
+		if zeroInBounds {
+			filtersPerKey = append(filtersPerKey, filters.StringEqualLabelFilter(key, ""))
+		}
+	}
+	q.jsonFilters = append(q.jsonFilters, filtersPerKey)
+	return nil
+}
+
+// addRegexFilter adds regular expressions as stream selectors on labels, else as JSON label filters: unlike patterns,
+// a regular expression cannot be bounded to the field value in raw JSON lines
+func (q *FlowQueryBuilder) addRegexFilter(filter filters.Match, field *config.FieldConfig) error {
+	if field.Type == config.FieldTypeStringArray || field.Type == config.FieldTypeNumberArray {
+		return fmt.Errorf("regular expressions are not supported on %s field %s in flows request", field.Type, field.Name)
+	}
+	if err := filter.ValidateRegexes(); err != nil {
+		return err
+	}
+	lf, _ := filter.ToLabelFilter()
+	if q.config.IsLabel(filter.Key) {
+		q.labelFilters = append(q.labelFilters, lf)
+	} else {
+		q.jsonFilters = append(q.jsonFilters, []filters.LabelFilter{lf})
+	}
+	return nil
+}
+
+func (q *FlowQueryBuilder) addLineFilters(key, fieldType string, values []string, not, caseSensitive bool) {
 	if len(values) == 0 {
 		return
 	}
 
-	if fields.IsArray(key) {
-		q.lineFilters = append(q.lineFilters, filters.ArrayLineFilter(key, values, not))
-	} else {
+	switch fieldType {
+	case config.FieldTypeStringArray, config.FieldTypeNumberArray:
+		q.lineFilters = append(q.lineFilters, filters.ArrayLineFilter(key, values, not, caseSensitive))
+	case config.FieldTypeBoolean:
+		q.lineFilters = append(q.lineFilters, filters.BoolLineFilter(key, values, not))
+	default:
 		var lf filters.LineFilter
 		var hasEmptyMatch bool
-		if fields.IsNumeric(key) {
-			lf, hasEmptyMatch = filters.NumericLineFilter(key, values, not, moreThan)
+		if fieldType == config.FieldTypeNumber {
+			lf, hasEmptyMatch = filters.NumericLineFilter(key, values, not)
 		} else {
-			lf, hasEmptyMatch = filters.StringLineFilterCheckExact(key, values, not)
+			lf, hasEmptyMatch = filters.StringLineFilterCheckExact(key, values, not, caseSensitive)
 		}
 		// if there is at least an empty exact match, there is no uniform/safe way to filter by text,
 		// so we should use JSON label matchers instead of text line matchers
 		if hasEmptyMatch {
-			q.jsonFilters = append(q.jsonFilters, lf.AsLabelFilters())
+			q.addJSONFilters(lf.AsLabelFilters(), not)
 		} else {
 			q.lineFilters = append(q.lineFilters, lf)
 		}
 	}
 }
 
+// addJSONFilters adds JSON label filters matching any of the values, or none of them when negated
+func (q *FlowQueryBuilder) addJSONFilters(filtersPerKey []filters.LabelFilter, not bool) {
+	if !not {
+		q.jsonFilters = append(q.jsonFilters, filtersPerKey)
+		return
+	}
+	// each negated value is excluded: key!=a AND key!=b
+	for _, lf := range filtersPerKey {
+		q.jsonFilters = append(q.jsonFilters, []filters.LabelFilter{lf})
+	}
+}
+
 // addIPFilters assumes that we are searching for that IP addresses as part
 // of the log line (not in the stream selector labels)
 func (q *FlowQueryBuilder) addIPFilters(key string, values []string, not bool) {
@@ -173,13 +344,15 @@ func (q *FlowQueryBuilder) addIPFilters(key string, values []string, not bool) {
 			}
 		}
 	}
-	q.jsonFilters = append(q.jsonFilters, filtersPerKey)
+	q.addJSONFilters(filtersPerKey, not)
 }
 
-func (q *FlowQueryBuilder) createStringBuilderURL() *strings.Builder {
+// createStringBuilderURL starts a Loki API URL with the given path, followed by the URL-encoded LogQL query
+func (q *FlowQueryBuilder) createStringBuilderURL(path, logQL string) *strings.Builder {
 	sb := strings.Builder{}
 	sb.WriteString(strings.TrimRight(q.config.URL, "/"))
-	sb.WriteString(queryRangePath)
+	sb.WriteString(path)
+	sb.WriteString(url.QueryEscape(logQL))
 	return &sb
 }
 
@@ -269,17 +442,65 @@ func (q *FlowQueryBuilder) appendQueryParams(sb *strings.Builder) {
 }
 
 func (q *FlowQueryBuilder) Build() string {
-	sb := q.createStringBuilderURL()
-	q.appendLabels(sb)
-	q.appendLineFilters(sb)
-	q.appendJSON(sb, false)
+	logQL := strings.Builder{}
+	q.appendLabels(&logQL)
+	q.appendLineFilters(&logQL)
+	q.appendJSON(&logQL, false)
+	sb := q.createStringBuilderURL(queryRangePath, logQL.String())
 	q.appendQueryParams(sb)
 	return sb.String()
 }
 
+// BuildLabelValues builds a label values query for the given label. Only stream selector labels
+// can be used to scope it: line and JSON filters are ignored
+func (q *FlowQueryBuilder) BuildLabelValues(label string) string {
+	logQL := strings.Builder{}
+	q.appendLabels(&logQL)
+	sb := q.createStringBuilderURL(fmt.Sprintf(labelValuesPath, url.PathEscape(label)), logQL.String())
+	q.appendQueryParams(sb)
+	return sb.String()
+}
+
+// IsStreamSelectorOnly returns true when all filters are managed as stream selector labels
+func (q *FlowQueryBuilder) IsStreamSelectorOnly() bool {
+	return len(q.lineFilters) == 0 && len(q.jsonFilters) == 0
+}
+
+// BuildCountBy builds an instant metric query counting records per distinct value of the given field,
+// over the provided range (e.g. "1h"), ending at the query end time
+func (q *FlowQueryBuilder) BuildCountBy(field, rangeInterval string) string {
+	isLabel := q.config.IsLabel(field)
+	// Build query like:
+	// /<url path>?query=
+	//		count by(<field>)(
+	//			count_over_time(
+	//				{<label filters>}|<line filters>|json|<json filters>[<range>]
+	//			)
+	//		)
+	//		&time=<end>
+	logQL := strings.Builder{}
+	logQL.WriteString("count by(")
+	logQL.WriteString(field)
+	logQL.WriteString(")(count_over_time(")
+	q.appendLabels(&logQL)
+	q.appendLineFilters(&logQL)
+	if !isLabel {
+		q.appendFilter(&logQL, field)
+	}
+	q.appendJSON(&logQL, !isLabel)
+	logQL.WriteRune('[')
+	logQL.WriteString(rangeInterval)
+	logQL.WriteString("]))")
+	sb := q.createStringBuilderURL(queryPath, logQL.String())
+	if len(q.endTime) > 0 {
+		appendQueryParam(sb, timeParam, q.endTime)
+	}
+	return sb.String()
+}
+
 func appendQueryParam(sb *strings.Builder, key, value string) {
 	sb.WriteByte('&')
 	sb.WriteString(key)
 	sb.WriteByte('=')
-	sb.WriteString(value)
+	sb.WriteString(url.QueryEscape(value))
 }
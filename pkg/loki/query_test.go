@@ -1,6 +1,8 @@
 package loki
 
 import (
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/config"
@@ -16,14 +18,139 @@ func TestFlowQuery_AddLabelFilters(t *testing.T) {
 	require.NoError(t, err)
 	err = query.addFilter(filters.NewMatch("flis", `"flas"`))
 	require.NoError(t, err)
-	urlQuery := query.Build()
+	urlQuery := unescape(t, query.Build())
 	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector",foo="bar",flis="flas"}`, urlQuery)
 }
 
-func TestQuery_BackQuote_Error(t *testing.T) {
+// unescape decodes the built URL, for readability
+func unescape(t *testing.T, rawURL string) string {
+	decoded, err := url.QueryUnescape(rawURL)
+	require.NoError(t, err)
+	return decoded
+}
+
+func TestQuery_BackQuote_Escaped(t *testing.T) {
 	cfg := config.Loki{URL: "/", Labels: []string{"lab1", "lab2"}}
 	query := NewFlowQueryBuilderWithDefaults(&cfg)
-	assert.Error(t, query.addFilter(filters.NewMatch("key", "backquoted`val")))
+	err := query.addFilter(filters.NewMatch("key", "backquoted`val"))
+	require.NoError(t, err)
+	err = query.addFilter(filters.NewMatch("lab1", "backquoted`val"))
+	require.NoError(t, err)
+	urlQuery := unescape(t, query.Build())
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector",lab1=~"(?i).*backquoted`+"`"+`val.*"}|~`+backtick(`key":"(?i)[^"]*backquoted\x60val.*"`), urlQuery)
+}
+
+func TestQuery_SpecialCharacters_Escaped(t *testing.T) {
+	cfg := config.Loki{URL: "/", Labels: []string{"lab1"}}
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	err := query.addFilter(filters.NewMatch("lab1", `"`+filters.EscapeValue(`my\"pod+1`)+`"`))
+	require.NoError(t, err)
+	err = query.addFilter(filters.NewMatch("SrcK8S_Name", `"ns 1/pod+1 ünïcode"`))
+	require.NoError(t, err)
+	err = query.addFilter(filters.NewMatch("DnsFlagsResponseCode", `a|b&c`))
+	require.NoError(t, err)
+	urlQuery := query.Build()
+	assert.NotContains(t, urlQuery, "&c")
+	assert.Equal(
+		t,
+		`/loki/api/v1/query_range?query={app="netobserv-flowcollector",lab1="my\\\"pod+1"}`+
+			`|~`+backtick(`SrcK8S_Name":"ns 1/pod\+1 ünïcode"`)+
+			`|~`+backtick(`DnsFlagsResponseCode":"(?i)[^"]*a\|b&c.*"`),
+		unescape(t, urlQuery),
+	)
+}
+
+func TestQuery_InvalidKey_Error(t *testing.T) {
+	cfg := config.Loki{URL: "/"}
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	assert.Error(t, query.addFilter(filters.NewMatch("key`} or vector(1)", "val")))
+}
+
+func TestQuery_InvalidNumber_Error(t *testing.T) {
+	cfg := config.Loki{URL: "/"}
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	assert.Error(t, query.addFilter(filters.NewMatch("SrcPort", "80[,}]|.*")))
+	assert.Error(t, query.addFilter(filters.NewMoreThanOrEqualMatch("Bytes", "1`")))
+	assert.NoError(t, query.addFilter(filters.NewMatch("SrcPort", `80,"443",""`)))
+}
+
+func TestFlowQuery_AddComparisonFilters(t *testing.T) {
+	cfg := config.Loki{URL: "/", Labels: []string{"foo"}}
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	require.NoError(t, query.addFilter(filters.NewMoreThanOrEqualMatch("Bytes", "100")))
+	require.NoError(t, query.addFilter(filters.NewLessThanOrEqualMatch("Bytes", "200")))
+	require.NoError(t, query.addFilter(filters.NewLessThanOrEqualMatch("DnsLatencyMs", "5.5")))
+	urlQuery := unescape(t, query.Build())
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector"}|json|Bytes>=100|Bytes<=200 or Bytes=""|DnsLatencyMs<=5.5 or DnsLatencyMs=""`, urlQuery)
+
+	// a comparison needs exactly one value
+	assert.Error(t, query.addFilter(filters.NewLessThanOrEqualMatch("Bytes", "1,2")))
+
+	// non-strict keys match the source or destination field
+	query = NewFlowQueryBuilderWithDefaults(&cfg)
+	require.NoError(t, query.addFilter(filters.NewMoreThanOrEqualMatch("Port", "443")))
+	require.NoError(t, query.addFilter(filters.NewLessThanOrEqualMatch("Port", "8080")))
+	urlQuery = unescape(t, query.Build())
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector"}|json|SrcPort>=443 or DstPort>=443|SrcPort<=8080 or SrcPort="" or DstPort<=8080 or DstPort=""`, urlQuery)
+}
+
+func TestFlowQuery_ConfiguredFields(t *testing.T) {
+	cfg := config.Loki{URL: "/", Labels: []string{"SrcK8S_Namespace"}, Fields: []config.FieldConfig{
+		{Name: "SrcK8S_Namespace", Type: config.FieldTypeString},
+		{Name: "IcmpType", Type: config.FieldTypeNumber, Filter: "icmp_type"},
+		{Name: "XlatSrcAddr", Type: config.FieldTypeIP},
+		{Name: "IfDirections", Type: config.FieldTypeNumberArray},
+		{Name: "Sampled", Type: config.FieldTypeBoolean},
+		{Name: "DnsFlagsResponseCode", Type: config.FieldTypeString},
+	}}
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	require.NoError(t, query.addFilter(filters.NewMatch("SrcK8S_Namespace", `"ns"`)))
+	require.NoError(t, query.addFilter(filters.NewMatch("icmp_type", "8")))
+	require.NoError(t, query.addFilter(filters.NewMatch("XlatSrcAddr", "10.0.0.0/8")))
+	require.NoError(t, query.addFilter(filters.NewMatch("IfDirections", "1")))
+	require.NoError(t, query.addFilter(filters.NewNotMatch("Sampled", "true")))
+	urlQuery := unescape(t, query.Build())
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector",SrcK8S_Namespace="ns"}`+
+		"|~`IcmpType\":8[,}]`|~`IfDirections\":\\[(?i)[^]]*1[^]]*]`|~`\"Sampled\"`!~`\"Sampled\":true`"+
+		`|json|XlatSrcAddr=ip("10.0.0.0/8")`, urlQuery)
+
+	// values must match the field type
+	assert.ErrorContains(t, query.addFilter(filters.NewMatch("icmp_type", "echo")), "invalid numeric value for IcmpType")
+	assert.ErrorContains(t, query.addFilter(filters.NewMatch("Sampled", "yes")), "invalid boolean value for Sampled")
+	assert.ErrorContains(t, query.addFilter(filters.NewMoreThanOrEqualMatch("DnsFlagsResponseCode", "1")), "numeric comparison is not supported on string field DnsFlagsResponseCode")
+
+	// unknown fields are rejected
+	assert.EqualError(t, query.addFilter(filters.NewMatch("icmptype", "8")), "unknown filter field in flows request: icmptype (did you mean IcmpType?)")
+	assert.EqualError(t, query.addFilter(filters.NewMatch("Foo", "8")), "unknown filter field in flows request: Foo; filters must use fields defined in the frontend configuration")
+}
+
+func TestCheckFilter(t *testing.T) {
+	cfg := config.Loki{URL: "/", Labels: []string{"SrcK8S_Namespace"}, Fields: []config.FieldConfig{
+		{Name: "SrcK8S_Namespace", Type: config.FieldTypeString},
+		{Name: "SrcK8S_Name", Type: config.FieldTypeString},
+		{Name: "IcmpType", Type: config.FieldTypeNumber, Filter: "icmp_type"},
+		{Name: "SrcAddr", Type: config.FieldTypeIP},
+	}}
+	for _, tc := range []struct {
+		filter filters.Match
+		field  string
+		target FilterTarget
+	}{
+		{filter: filters.NewMatch("SrcK8S_Namespace", `"ns"`), field: "SrcK8S_Namespace", target: FilterTargetLabel},
+		{filter: filters.NewMatch("SrcK8S_Name", "api"), field: "SrcK8S_Name", target: FilterTargetLine},
+		{filter: filters.NewMatch("SrcK8S_Name", `""`), field: "SrcK8S_Name", target: FilterTargetJSON},
+		{filter: filters.NewMatch("icmp_type", "8"), field: "IcmpType", target: FilterTargetLine},
+		{filter: filters.NewMoreThanOrEqualMatch("IcmpType", "8"), field: "IcmpType", target: FilterTargetJSON},
+		{filter: filters.NewMatch("SrcAddr", "10.0.0.0/8"), field: "SrcAddr", target: FilterTargetJSON},
+	} {
+		field, target, err := CheckFilter(&cfg, tc.filter)
+		require.NoError(t, err, tc.filter)
+		assert.Equal(t, tc.field, field, tc.filter)
+		assert.Equal(t, tc.target, target, tc.filter)
+	}
+
+	_, _, err := CheckFilter(&cfg, filters.NewMatch("icmp_type", "echo"))
+	assert.EqualError(t, err, "invalid numeric value for IcmpType in flows request: echo")
 }
 
 func TestFlowQuery_AddNotLabelFilters(t *testing.T) {
@@ -33,7 +160,7 @@ func TestFlowQuery_AddNotLabelFilters(t *testing.T) {
 	require.NoError(t, err)
 	err = query.addFilter(filters.NewNotMatch("flis", `"flas"`))
 	require.NoError(t, err)
-	urlQuery := query.Build()
+	urlQuery := unescape(t, query.Build())
 	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector",foo="bar",flis!="flas"}`, urlQuery)
 }
 
@@ -46,7 +173,7 @@ func TestFlowQuery_AddLineFilterMultipleValues(t *testing.T) {
 	query := NewFlowQueryBuilderWithDefaults(&cfg)
 	err := query.addFilter(filters.NewMatch("foo", `bar,baz`))
 	require.NoError(t, err)
-	urlQuery := query.Build()
+	urlQuery := unescape(t, query.Build())
 	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector"}|~`+backtick(`foo":"(?i)[^"]*bar.*"|foo":"(?i)[^"]*baz.*"`), urlQuery)
 }
 
@@ -57,7 +184,7 @@ func TestFlowQuery_AddNotLineFilters(t *testing.T) {
 	require.NoError(t, err)
 	err = query.addFilter(filters.NewNotMatch("flis", `"flas"`))
 	require.NoError(t, err)
-	urlQuery := query.Build()
+	urlQuery := unescape(t, query.Build())
 	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector"}|~`+backtick(`foo":"bar"`)+`|~`+backtick(`"flis"`)+`!~`+backtick(`flis":"flas"`), urlQuery)
 }
 
@@ -68,10 +195,48 @@ func TestFlowQuery_AddLineFiltersWithEmpty(t *testing.T) {
 	require.NoError(t, err)
 	err = query.addFilter(filters.NewMatch("flis", `""`))
 	require.NoError(t, err)
-	urlQuery := query.Build()
+	urlQuery := unescape(t, query.Build())
 	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector"}|~`+backtick(`foo":"bar"`)+`|json|flis=""`, urlQuery)
 }
 
+func TestFlowQuery_AddRegexFilters(t *testing.T) {
+	cfg := config.Loki{URL: "/", Labels: []string{"SrcK8S_Namespace"}}
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	require.NoError(t, query.addFilter(filters.NewRegexMatch("SrcK8S_Namespace", "kube-.*")))
+	require.NoError(t, query.addFilter(filters.NewNotRegexMatch("DstK8S_Name", filters.EscapeValue(`db-\d{1,3}`))))
+	require.NoError(t, query.addFilter(filters.NewRegexMatch("SrcAddr", `10\\.0\\..*`)))
+	urlQuery := unescape(t, query.Build())
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector",SrcK8S_Namespace=~"kube-.*"}|json|DstK8S_Name!~"db-\\d{1,3}"|SrcAddr=~"10\\.0\\..*"`, urlQuery)
+
+	assert.EqualError(t, query.addFilter(filters.NewRegexMatch("Interfaces", "eth.*")), "regular expressions are not supported on string[] field Interfaces in flows request")
+	assert.EqualError(t, query.addFilter(filters.NewRegexMatch("SrcK8S_Name", "a(")), "invalid regular expression in SrcK8S_Name filter: error parsing regexp: missing closing ): `a(`")
+}
+
+func TestFlowQuery_CaseSensitive(t *testing.T) {
+	cfg := config.Loki{URL: "/", Labels: []string{"SrcK8S_Namespace"}}
+	query := NewFlowQueryBuilderWithDefaults(&cfg)
+	for _, m := range []filters.Match{
+		filters.NewMatch("SrcK8S_Namespace", "Kube"),
+		filters.NewMatch("DstK8S_Name", "Api"),
+		filters.NewMatch("Interfaces", "Eth"),
+		filters.NewRegexMatch("SrcK8S_Name", "API-.*"),
+	} {
+		m.CaseSensitive = true
+		require.NoError(t, query.addFilter(m))
+	}
+	urlQuery := unescape(t, query.Build())
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector",SrcK8S_Namespace=~".*Kube.*"}`+
+		`|~`+backtick(`DstK8S_Name":"[^"]*Api.*"`)+`|~`+backtick(`Interfaces":\[[^]]*Eth[^]]*]`)+`|json|SrcK8S_Name=~"API-.*"`, urlQuery)
+
+	// regular expressions are case-sensitive unless the flag is unset
+	query = NewFlowQueryBuilderWithDefaults(&cfg)
+	m := filters.NewRegexMatch("SrcK8S_Namespace", "Kube-.*")
+	m.CaseSensitive = false
+	require.NoError(t, query.addFilter(m))
+	urlQuery = unescape(t, query.Build())
+	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector",SrcK8S_Namespace=~"(?i)Kube-.*"}`, urlQuery)
+}
+
 func TestFlowQuery_AddRecordTypeLabelFilter(t *testing.T) {
 	cfg := config.Loki{URL: "/", Labels: []string{"foo", "flis", "_RecordType"}}
 	query := NewFlowQueryBuilderWithDefaults(&cfg)
@@ -79,6 +244,109 @@ func TestFlowQuery_AddRecordTypeLabelFilter(t *testing.T) {
 	require.NoError(t, err)
 	err = query.addFilter(filters.NewMatch("flis", `"flas"`))
 	require.NoError(t, err)
-	urlQuery := query.Build()
+	urlQuery := unescape(t, query.Build())
 	assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector",_RecordType="flowLog",foo="bar",flis="flas"}`, urlQuery)
 }
+
+func FuzzFlowQuery_Build(f *testing.F) {
+	for _, seed := range []string{"bar", `"bar"`, "a&start=0", "a%26b", "a+b c", "back`quote", `"}|json`, `\`} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		cfg := config.Loki{URL: "/", Labels: []string{"lab1"}}
+		query := NewFlowQueryBuilder(&cfg, "1640991600", "", "50", false, "", "")
+		require.NoError(t, query.addFilter(filters.NewMatch("lab1", value)))
+		require.NoError(t, query.addFilter(filters.NewMatch("SrcK8S_Name", value)))
+		require.NoError(t, query.addFilter(filters.NewMatch("SrcAddr", value)))
+		urlQuery := query.Build()
+
+		// the values must not leak outside of the query parameter
+		path, rawQuery, found := strings.Cut(urlQuery, "?")
+		require.True(t, found)
+		assert.Equal(t, "/loki/api/v1/query_range", path)
+		params, err := url.ParseQuery(rawQuery)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"1640991600"}, params[startParam])
+		assert.Equal(t, []string{"50"}, params[limitParam])
+		require.Len(t, params["query"], 1)
+		assert.True(t, strings.HasPrefix(params["query"][0], `{app="netobserv-flowcollector",lab1`), params["query"][0])
+		assert.Len(t, params, 3)
+	})
+}
+
+func TestFlowQuery_NegatedMultiValues(t *testing.T) {
+	// negated filters match none of their values, whatever the kind of filter
+	cfg := config.Loki{URL: "/", Labels: []string{"SrcK8S_Namespace"}, Fields: []config.FieldConfig{
+		{Name: "SrcK8S_Namespace", Type: config.FieldTypeString},
+		{Name: "SrcK8S_Name", Type: config.FieldTypeString},
+		{Name: "SrcPort", Type: config.FieldTypeNumber},
+		{Name: "Sampled", Type: config.FieldTypeBoolean},
+		{Name: "Interfaces", Type: config.FieldTypeStringArray},
+		{Name: "SrcAddr", Type: config.FieldTypeIP},
+	}}
+	for _, tc := range []struct {
+		name     string
+		filter   filters.Match
+		expected string
+	}{{
+		name:     "label exact",
+		filter:   filters.NewNotMatch("SrcK8S_Namespace", `"a","b"`),
+		expected: `,SrcK8S_Namespace!~"^a$|^b$"}`,
+	}, {
+		name:     "label pattern",
+		filter:   filters.NewNotMatch("SrcK8S_Namespace", `a,"b*"`),
+		expected: `,SrcK8S_Namespace!~"(?i).*a.*|^b.*"}`,
+	}, {
+		name:     "label regex",
+		filter:   filters.NewNotRegexMatch("SrcK8S_Namespace", `a.*,b.*`),
+		expected: `,SrcK8S_Namespace!~"(?:a.*)|(?:b.*)"}`,
+	}, {
+		name:     "line string",
+		filter:   filters.NewNotMatch("SrcK8S_Name", `"a",b`),
+		expected: "}|~`\"SrcK8S_Name\"`!~" + backtick(`SrcK8S_Name":"a"|SrcK8S_Name":"(?i)[^"]*b.*"`),
+	}, {
+		name:     "line number",
+		filter:   filters.NewNotMatch("SrcPort", `80,443`),
+		expected: "}|~`\"SrcPort\"`!~" + backtick(`SrcPort":80[,}]|SrcPort":443[,}]`),
+	}, {
+		name:     "line boolean",
+		filter:   filters.NewNotMatch("Sampled", `true,false`),
+		expected: "}|~`\"Sampled\"`!~" + backtick(`"Sampled":true|"Sampled":false`),
+	}, {
+		name:     "line array",
+		filter:   filters.NewNotMatch("Interfaces", `eth0,br-ex`),
+		expected: "}|~`\"Interfaces\"`!~" + backtick(`Interfaces":\[(?i)[^]]*eth0[^]]*]|Interfaces":\[(?i)[^]]*br-ex[^]]*]`),
+	}, {
+		name:     "json string with empty",
+		filter:   filters.NewNotMatch("SrcK8S_Name", `"a",""`),
+		expected: `}|json|SrcK8S_Name!="a"|SrcK8S_Name!=""`,
+	}, {
+		name:     "json number with empty",
+		filter:   filters.NewNotMatch("SrcPort", `80,""`),
+		expected: `}|json|SrcPort!=80|SrcPort!=""`,
+	}, {
+		name:     "json regex",
+		filter:   filters.NewNotRegexMatch("SrcK8S_Name", `a.*,b.*`),
+		expected: `}|json|SrcK8S_Name!~"(?:a.*)|(?:b.*)"`,
+	}, {
+		name:     "json ip",
+		filter:   filters.NewNotMatch("SrcAddr", `10.0.0.0/8,192.168.0.0/16`),
+		expected: `}|json|SrcAddr!=ip("10.0.0.0/8")|SrcAddr!=ip("192.168.0.0/16")`,
+	}, {
+		name:     "json ip with empty",
+		filter:   filters.NewNotMatch("SrcAddr", `10.0.0.0/8,""`),
+		expected: `}|json|SrcAddr!=ip("10.0.0.0/8")|SrcAddr!=""`,
+	}, {
+		// for comparison, positive values are OR'ed
+		name:     "json ip, not negated",
+		filter:   filters.NewMatch("SrcAddr", `10.0.0.0/8,192.168.0.0/16`),
+		expected: `}|json|SrcAddr=ip("10.0.0.0/8") or SrcAddr=ip("192.168.0.0/16")`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			query := NewFlowQueryBuilderWithDefaults(&cfg)
+			require.NoError(t, query.addFilter(tc.filter))
+			urlQuery := unescape(t, query.Build())
+			assert.Equal(t, `/loki/api/v1/query_range?query={app="netobserv-flowcollector"`+tc.expected, urlQuery)
+		})
+	}
+}
@@ -11,28 +11,7 @@ import (
 
 const (
 	topologyDefaultLimit = "100"
-)
-
-var (
-	aggregateKeyLabels = map[string][]string{
-		"app":          {"app"},
-		"droppedState": {"PktDropLatestState"},
-		"droppedCause": {"PktDropLatestDropCause"},
-		"dnsRCode":     {"DnsFlagsResponseCode"},
-		"cluster":      {"K8S_ClusterName"},
-		"zone":         {"SrcK8S_Zone", "DstK8S_Zone"},
-		"host":         {"SrcK8S_HostName", "DstK8S_HostName"},
-		"namespace":    {"SrcK8S_Namespace", "DstK8S_Namespace"},
-		"owner":        {"SrcK8S_OwnerName", "SrcK8S_OwnerType", "DstK8S_OwnerName", "DstK8S_OwnerType", "SrcK8S_Namespace", "DstK8S_Namespace"},
-		"resource":     {"SrcK8S_Name", "SrcK8S_Type", "SrcK8S_OwnerName", "SrcK8S_OwnerType", "SrcK8S_Namespace", "SrcAddr", "SrcK8S_HostName", "DstK8S_Name", "DstK8S_Type", "DstK8S_OwnerName", "DstK8S_OwnerType", "DstK8S_Namespace", "DstAddr", "DstK8S_HostName"},
-	}
-	groupKeyLabels = map[string][]string{
-		"clusters":   {"K8S_ClusterName"},
-		"zones":      {"SrcK8S_Zone", "DstK8S_Zone"},
-		"hosts":      {"SrcK8S_HostName", "DstK8S_HostName"},
-		"namespaces": {"SrcK8S_Namespace", "DstK8S_Namespace"},
-		"owners":     {"SrcK8S_OwnerName", "SrcK8S_OwnerType", "DstK8S_OwnerName", "DstK8S_OwnerType"},
-	}
+	maxQuantileDigits    = 4
 )
 
 type TopologyInput struct {
@@ -49,6 +28,12 @@ type TopologyInput struct {
 	Aggregate      string
 	Groups         string
 	DedupMark      bool
+	// Total builds the sum over all series instead of the top series, to compute the remainder of the limit
+	Total bool
+	// ReportersMerge is how flows reported by both the ingress and egress nodes are counted once
+	ReportersMerge constants.ReportersMerge
+	// Scopes are the aggregation scopes and groups, such as namespace or owner, giving the labels to aggregate on
+	Scopes []config.Scope
 }
 
 type TopologyQueryBuilder struct {
@@ -74,25 +59,69 @@ func NewTopologyQuery(cfg *config.Loki, in *TopologyInput) (*TopologyQueryBuilde
 	}, nil
 }
 
-func GetLabelsAndFilter(aggregate, groups string) ([]string, string) {
-	var fields []string
-	var filter string
-	if fields = aggregateKeyLabels[aggregate]; fields == nil {
-		fields = []string{aggregate}
-		filter = aggregate
-	}
-	if groups != "" {
-		for gr, labels := range groupKeyLabels {
-			if strings.Contains(groups, gr) {
-				for _, label := range labels {
-					if !slices.Contains(fields, label) {
-						fields = append(fields, label)
-					}
-				}
+// AggregateFields splits a comma-separated aggregation into its keys or field names
+func AggregateFields(aggregate string) []string {
+	var aggFields []string
+	for _, agg := range strings.Split(aggregate, ",") {
+		if agg = strings.TrimSpace(agg); agg != "" {
+			aggFields = append(aggFields, agg)
+		}
+	}
+	return aggFields
+}
+
+// CheckAggregate returns an error when an aggregation field is unknown, fields being configured.
+// Aggregation scopes such as "namespace" or "owner" are always allowed
+func CheckAggregate(cfg *config.Loki, scopes []config.Scope, aggregate string) error {
+	if !cfg.HasFields() {
+		return nil
+	}
+	scopes = scopesOrDefault(scopes)
+	for _, agg := range AggregateFields(aggregate) {
+		if _, isScope := config.FindScope(scopes, agg); isScope {
+			continue
+		}
+		// aggregations are written as is in queries: filter names are not resolved
+		if field, ok := cfg.GetField(agg); !ok || field.Name != agg {
+			return fmt.Errorf("unknown aggregateBy field: %s; aggregations must use fields defined in the frontend configuration", agg)
+		}
+	}
+	return nil
+}
+
+// GetLabelsAndFilter returns the labels to aggregate on, from the scopes or raw fields of the aggregation and from the
+// groups, and the raw fields among them that must be present in flows
+func GetLabelsAndFilter(scopes []config.Scope, aggregate, groups string) ([]string, []string) {
+	scopes = scopesOrDefault(scopes)
+	var labels, filters []string
+	add := func(label string) {
+		if !slices.Contains(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	for _, agg := range AggregateFields(aggregate) {
+		if scope, isScope := config.FindScope(scopes, agg); isScope {
+			for _, label := range scope.GetLabels(scopes) {
+				add(label)
 			}
+			continue
 		}
+		add(agg)
+		if !slices.Contains(filters, agg) {
+			filters = append(filters, agg)
+		}
+	}
+	for _, label := range config.GetGroupLabels(scopes, groups) {
+		add(label)
+	}
+	return labels, filters
+}
+
+func scopesOrDefault(scopes []config.Scope) []config.Scope {
+	if len(scopes) == 0 {
+		return config.DefaultScopes
 	}
-	return fields, filter
+	return scopes
 }
 
 func getField(metricType string) string {
@@ -113,7 +142,33 @@ func getFactor(metricType string) string {
 	}
 }
 
+// Quantile returns the quantile of a pNN metric function, NN being a percentile: "0.05" for p5, "0.5" for p50,
+// "0.95" for p95 or "0.999" for p999. Digits beyond two are the decimals of the percentile, hence a trailing zero
+// is rejected: p100 would read as the 10th percentile, and p500 as an alias of p50
+func Quantile(metricFunction constants.MetricFunction) (string, bool) {
+	digits, ok := strings.CutPrefix(string(metricFunction), "p")
+	if !ok || digits == "" || len(digits) > maxQuantileDigits || (len(digits) > 2 && strings.HasSuffix(digits, "0")) {
+		return "", false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", false
+		}
+	}
+	if len(digits) == 1 {
+		digits = "0" + digits
+	}
+	digits = strings.TrimRight(digits, "0")
+	if digits == "" {
+		return "", false
+	}
+	return "0." + digits, true
+}
+
 func GetFunctionWithQuantile(metricFunction constants.MetricFunction) (string, string) {
+	if quantile, ok := Quantile(metricFunction); ok {
+		return "quantile_over_time", quantile
+	}
 	switch metricFunction {
 	case constants.MetricFunctionCount:
 		return "count_over_time", ""
@@ -125,10 +180,10 @@ func GetFunctionWithQuantile(metricFunction constants.MetricFunction) (string, s
 		return "min_over_time", ""
 	case constants.MetricFunctionAvg:
 		return "avg_over_time", ""
-	case constants.MetricFunctionP90:
-		return "quantile_over_time", "0.9"
-	case constants.MetricFunctionP99:
-		return "quantile_over_time", "0.99"
+	case constants.MetricFunctionStddev:
+		return "stddev_over_time", ""
+	case constants.MetricFunctionLast:
+		return "last_over_time", ""
 	case constants.MetricFunctionRate:
 		return "rate", ""
 	default:
@@ -137,44 +192,82 @@ func GetFunctionWithQuantile(metricFunction constants.MetricFunction) (string, s
 }
 
 func (q *TopologyQueryBuilder) Build() string {
+	return q.build(q.appendExpression)
+}
+
+// BuildMax builds the query of the largest values per series between the two builders, which differ only by their
+// filters, such as the ingress and egress flows of the same traffic:
+//
+//	(<expression>)>=(<other expression>)or(<other expression>)or(<expression>)
+func (q *TopologyQueryBuilder) BuildMax(other *TopologyQueryBuilder) string {
+	return q.build(func(sb *strings.Builder) {
+		sb.WriteRune('(')
+		q.appendExpression(sb)
+		sb.WriteString(")>=(")
+		other.appendExpression(sb)
+		sb.WriteString(")or(")
+		other.appendExpression(sb)
+		sb.WriteString(")or(")
+		q.appendExpression(sb)
+		sb.WriteRune(')')
+	})
+}
+
+func (q *TopologyQueryBuilder) build(appendExpression func(sb *strings.Builder)) string {
 	top := q.topology.Top
 	if top == "" {
 		top = topologyDefaultLimit
 	}
+	function, _ := GetFunctionWithQuantile(q.topology.MetricFunction)
 
-	labels, extraFilter := GetLabelsAndFilter(q.topology.Aggregate, q.topology.Groups)
-	if q.config.IsLabel(extraFilter) {
-		extraFilter = ""
-	}
-	strLabels := strings.Join(labels, ",")
-
-	dataField := getField(q.topology.DataField)
-	factor := getFactor(q.topology.DataField)
-	function, quantile := GetFunctionWithQuantile(q.topology.MetricFunction)
-
-	sumBy := function == "rate" || function == "count_over_time" || function == "sum_over_time"
 	// Build topology query like:
 	// /<url path>?query=
 	//		topk | bottomk(
 	// 			<k>,
-	//			<sum | avg> by(<aggregations>) (
-	//				<function>(
-	//					{<label filters>}|<line filters>|json|<json filters>
-	//						|unwrap Bytes|__error__=""[<interval>]
-	//				) <factor>
-	//			)
+	//			<expression>
 	//		)
 	//		&<query params>&step=<step>
-	sb := q.createStringBuilderURL()
-	if function == "min_over_time" {
-		sb.WriteString("bottomk")
+	//
+	// Or, for the total, replacing topk | bottomk(<k>, by sum(
+	sb := &strings.Builder{}
+	if q.topology.Total {
+		sb.WriteString("sum(")
 	} else {
-		sb.WriteString("topk")
+		if function == "min_over_time" {
+			sb.WriteString("bottomk")
+		} else {
+			sb.WriteString("topk")
+		}
+		sb.WriteRune('(')
+		sb.WriteString(top)
+		sb.WriteRune(',')
 	}
-	sb.WriteRune('(')
-	sb.WriteString(top)
-	sb.WriteRune(',')
+	appendExpression(sb)
+	sb.WriteRune(')')
 
+	u := q.createStringBuilderURL(queryRangePath, sb.String())
+	q.appendQueryParams(u)
+	appendQueryParam(u, "step", q.topology.Step)
+
+	return u.String()
+}
+
+func (q *TopologyQueryBuilder) appendExpression(sb *strings.Builder) {
+	labels, extraFilters := GetLabelsAndFilter(q.topology.Scopes, q.topology.Aggregate, q.topology.Groups)
+	strLabels := strings.Join(labels, ",")
+
+	dataField := getField(q.topology.DataField)
+	factor := getFactor(q.topology.DataField)
+	function, quantile := GetFunctionWithQuantile(q.topology.MetricFunction)
+
+	sumBy := function == "rate" || function == "count_over_time" || function == "sum_over_time"
+	// Build topology expression like:
+	//	<sum | avg> by(<aggregations>) (
+	//		<function>(
+	//			{<label filters>}|<line filters>|json|<json filters>
+	//				|unwrap Bytes|__error__=""[<interval>]
+	//		) <factor>
+	//	)
 	if sumBy {
 		sb.WriteString("sum by(")
 		sb.WriteString(strLabels)
@@ -191,8 +284,10 @@ func (q *TopologyQueryBuilder) Build() string {
 	q.appendLabels(sb)
 	q.appendLineFilters(sb)
 
-	if len(extraFilter) > 0 {
-		q.appendFilter(sb, extraFilter)
+	for _, extraFilter := range extraFilters {
+		if !q.config.IsLabel(extraFilter) {
+			q.appendFilter(sb, extraFilter)
+		}
 	}
 
 	if dataField == constants.MetricTypeDNSLatency {
@@ -227,11 +322,4 @@ func (q *TopologyQueryBuilder) Build() string {
 	if len(factor) > 0 {
 		sb.WriteString(factor)
 	}
-	sb.WriteRune(')')
-
-	q.appendQueryParams(sb)
-	sb.WriteString("&step=")
-	sb.WriteString(q.topology.Step)
-
-	return sb.String()
 }
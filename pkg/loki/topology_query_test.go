@@ -1,9 +1,11 @@
 package loki
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/config"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
 	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -30,7 +32,7 @@ func TestBuildTopologyQuery_SimpleAggregate(t *testing.T) {
 	}
 	q, err := NewTopologyQuery(&lokiConfig, &in)
 	require.NoError(t, err)
-	result := q.Build()
+	result := unescape(t, q.Build())
 	assert.Equal(
 		t,
 		"http://loki/loki/api/v1/query_range?query="+
@@ -56,7 +58,7 @@ func TestBuildTopologyQuery_GroupsAndAggregate(t *testing.T) {
 	}
 	q, err := NewTopologyQuery(&lokiConfig, &in)
 	require.NoError(t, err)
-	result := q.Build()
+	result := unescape(t, q.Build())
 	assert.Equal(
 		t,
 		"http://loki/loki/api/v1/query_range?query="+
@@ -81,7 +83,7 @@ func TestBuildTopologyQuery_CustomAggregate(t *testing.T) {
 	}
 	q, err := NewTopologyQuery(&lokiConfig, &in)
 	require.NoError(t, err)
-	result := q.Build()
+	result := unescape(t, q.Build())
 	assert.Equal(
 		t,
 		"http://loki/loki/api/v1/query_range?query="+
@@ -106,7 +108,7 @@ func TestBuildTopologyQuery_CustomLabelAggregate(t *testing.T) {
 	}
 	q, err := NewTopologyQuery(&lokiConfig, &in)
 	require.NoError(t, err)
-	result := q.Build()
+	result := unescape(t, q.Build())
 	assert.Equal(
 		t,
 		"http://loki/loki/api/v1/query_range?query="+
@@ -114,3 +116,194 @@ func TestBuildTopologyQuery_CustomLabelAggregate(t *testing.T) {
 		result,
 	)
 }
+
+func TestBuildTopologyQuery_MultiFieldAggregate(t *testing.T) {
+	in := TopologyInput{
+		Start:          "(start)",
+		End:            "",
+		Top:            "50",
+		RateInterval:   "2m",
+		Step:           "10s",
+		DataField:      "Bytes",
+		MetricFunction: constants.MetricFunctionRate,
+		RecordType:     constants.RecordTypeLog,
+		DataSource:     constants.DataSourceAuto,
+		Aggregate:      "namespace,DstPort,Proto,FlowDirection",
+		DedupMark:      true,
+	}
+	q, err := NewTopologyQuery(&lokiConfig, &in)
+	require.NoError(t, err)
+	result := unescape(t, q.Build())
+	assert.Equal(
+		t,
+		"http://loki/loki/api/v1/query_range?query="+
+			"topk(50,sum by(SrcK8S_Namespace,DstK8S_Namespace,DstPort,Proto,FlowDirection)(rate({app=\"netobserv-flowcollector\"}!~`Duplicate\":true`|~`\"DstPort\"`|~`\"Proto\"`|json|unwrap Bytes|__error__=\"\"[2m])))&start=(start)&limit=50&step=10s",
+		result,
+	)
+}
+
+func TestBuildTopologyQuery_Total(t *testing.T) {
+	in := TopologyInput{
+		Start:          "(start)",
+		End:            "",
+		Top:            "50",
+		RateInterval:   "2m",
+		Step:           "10s",
+		DataField:      "Bytes",
+		MetricFunction: constants.MetricFunctionRate,
+		RecordType:     constants.RecordTypeLog,
+		DataSource:     constants.DataSourceAuto,
+		Aggregate:      "namespace",
+		DedupMark:      true,
+		Total:          true,
+	}
+	q, err := NewTopologyQuery(&lokiConfig, &in)
+	require.NoError(t, err)
+	result := unescape(t, q.Build())
+	assert.Equal(
+		t,
+		"http://loki/loki/api/v1/query_range?query="+
+			"sum(sum by(SrcK8S_Namespace,DstK8S_Namespace)(rate({app=\"netobserv-flowcollector\"}!~`Duplicate\":true`|json|unwrap Bytes|__error__=\"\"[2m])))&start=(start)&limit=50&step=10s",
+		result,
+	)
+}
+
+func TestBuildTopologyQuery_Max(t *testing.T) {
+	in := TopologyInput{
+		Start:          "(start)",
+		End:            "",
+		Top:            "50",
+		RateInterval:   "2m",
+		Step:           "10s",
+		DataField:      "Bytes",
+		MetricFunction: constants.MetricFunctionRate,
+		RecordType:     constants.RecordTypeLog,
+		DataSource:     constants.DataSourceAuto,
+		Aggregate:      "namespace",
+		DedupMark:      true,
+		ReportersMerge: constants.ReportersMergeMax,
+	}
+	q1, err := NewTopologyQuery(&lokiConfig, &in)
+	require.NoError(t, err)
+	require.NoError(t, q1.Filters(filters.SingleQuery{filters.NewMatch("FlowDirection", `"0","2"`)}))
+	q2, err := NewTopologyQuery(&lokiConfig, &in)
+	require.NoError(t, err)
+	require.NoError(t, q2.Filters(filters.SingleQuery{filters.NewMatch("FlowDirection", `"1","2"`)}))
+	result := unescape(t, q1.BuildMax(q2))
+	ingress := "sum by(SrcK8S_Namespace,DstK8S_Namespace)(rate({app=\"netobserv-flowcollector\",FlowDirection=~\"^0$|^2$\"}!~`Duplicate\":true`|json|unwrap Bytes|__error__=\"\"[2m]))"
+	egress := "sum by(SrcK8S_Namespace,DstK8S_Namespace)(rate({app=\"netobserv-flowcollector\",FlowDirection=~\"^1$|^2$\"}!~`Duplicate\":true`|json|unwrap Bytes|__error__=\"\"[2m]))"
+	assert.Equal(
+		t,
+		"http://loki/loki/api/v1/query_range?query="+
+			"topk(50,("+ingress+")>=("+egress+")or("+egress+")or("+ingress+"))&start=(start)&limit=50&step=10s",
+		result,
+	)
+}
+
+func TestCheckAggregate(t *testing.T) {
+	// any field is allowed when none are configured
+	require.NoError(t, CheckAggregate(&lokiConfig, nil, "namespace,SomeField"))
+
+	cfg := config.Loki{
+		Fields: []config.FieldConfig{
+			{Name: "DstPort", Type: config.FieldTypeNumber, Filter: "dst_port"},
+			{Name: "Proto", Type: config.FieldTypeNumber},
+		},
+	}
+	require.NoError(t, CheckAggregate(&cfg, nil, "namespace,DstPort,Proto"))
+	require.NoError(t, CheckAggregate(&cfg, nil, "host"))
+	assert.EqualError(t, CheckAggregate(&cfg, nil, "DstPort,Dscp"), "unknown aggregateBy field: Dscp; aggregations must use fields defined in the frontend configuration")
+	// filter names are not field names
+	assert.Error(t, CheckAggregate(&cfg, nil, "dst_port"))
+	// configured scopes are allowed
+	scopes := append(slices.Clone(config.DefaultScopes), config.Scope{Name: "subnet", Labels: []config.ScopeLabel{{Src: "SrcSubnetLabel", Dst: "DstSubnetLabel"}}})
+	require.NoError(t, CheckAggregate(&cfg, scopes, "subnet,DstPort"))
+	assert.Error(t, CheckAggregate(&cfg, nil, "subnet"))
+}
+
+func TestBuildTopologyQuery_Scopes(t *testing.T) {
+	scopes := append(
+		slices.Clone(config.DefaultScopes),
+		config.Scope{Name: "subnet", Labels: []config.ScopeLabel{{Src: "SrcSubnetLabel", Dst: "DstSubnetLabel"}}, Group: "subnets"},
+		config.Scope{Name: "team", Labels: []config.ScopeLabel{{Src: "SrcK8S_Team", Dst: "DstK8S_Team"}}, Parent: "namespace"},
+	)
+	in := TopologyInput{
+		Start:          "(start)",
+		End:            "",
+		Top:            "50",
+		RateInterval:   "2m",
+		Step:           "10s",
+		DataField:      "Bytes",
+		MetricFunction: constants.MetricFunctionRate,
+		RecordType:     constants.RecordTypeLog,
+		DataSource:     constants.DataSourceAuto,
+		Aggregate:      "team",
+		Groups:         "hosts+subnets",
+		DedupMark:      true,
+		Scopes:         scopes,
+	}
+	q, err := NewTopologyQuery(&lokiConfig, &in)
+	require.NoError(t, err)
+	result := unescape(t, q.Build())
+	assert.Equal(
+		t,
+		"http://loki/loki/api/v1/query_range?query="+
+			"topk(50,sum by(SrcK8S_Team,DstK8S_Team,SrcK8S_Namespace,DstK8S_Namespace,SrcK8S_HostName,DstK8S_HostName,SrcSubnetLabel,DstSubnetLabel)"+
+			"(rate({app=\"netobserv-flowcollector\"}!~`Duplicate\":true`|json|unwrap Bytes|__error__=\"\"[2m])))&start=(start)&limit=50&step=10s",
+		result,
+	)
+}
+
+func TestBuildTopologyQuery_Functions(t *testing.T) {
+	for _, tc := range []struct {
+		function constants.MetricFunction
+		expected string
+	}{
+		{function: "p50", expected: "topk(50,(quantile_over_time(0.5,{app=\"netobserv-flowcollector\"}!~`Duplicate\":true`|json|unwrap Bytes|__error__=\"\"[10s]) by(SrcK8S_Namespace,DstK8S_Namespace)))"},
+		{function: "p95", expected: "topk(50,(quantile_over_time(0.95,{app=\"netobserv-flowcollector\"}!~`Duplicate\":true`|json|unwrap Bytes|__error__=\"\"[10s]) by(SrcK8S_Namespace,DstK8S_Namespace)))"},
+		{function: "p999", expected: "topk(50,(quantile_over_time(0.999,{app=\"netobserv-flowcollector\"}!~`Duplicate\":true`|json|unwrap Bytes|__error__=\"\"[10s]) by(SrcK8S_Namespace,DstK8S_Namespace)))"},
+		{function: "p75", expected: "topk(50,(quantile_over_time(0.75,{app=\"netobserv-flowcollector\"}!~`Duplicate\":true`|json|unwrap Bytes|__error__=\"\"[10s]) by(SrcK8S_Namespace,DstK8S_Namespace)))"},
+		{function: constants.MetricFunctionStddev, expected: "topk(50,(stddev_over_time({app=\"netobserv-flowcollector\"}!~`Duplicate\":true`|json|unwrap Bytes|__error__=\"\"[10s]) by(SrcK8S_Namespace,DstK8S_Namespace)))"},
+		{function: constants.MetricFunctionLast, expected: "topk(50,(last_over_time({app=\"netobserv-flowcollector\"}!~`Duplicate\":true`|json|unwrap Bytes|__error__=\"\"[10s]) by(SrcK8S_Namespace,DstK8S_Namespace)))"},
+	} {
+		t.Run(string(tc.function), func(t *testing.T) {
+			in := TopologyInput{
+				Start:          "(start)",
+				Top:            "50",
+				RateInterval:   "2m",
+				Step:           "10s",
+				DataField:      "Bytes",
+				MetricFunction: tc.function,
+				RecordType:     constants.RecordTypeLog,
+				DataSource:     constants.DataSourceAuto,
+				Aggregate:      "namespace",
+				DedupMark:      true,
+			}
+			q, err := NewTopologyQuery(&lokiConfig, &in)
+			require.NoError(t, err)
+			result := unescape(t, q.Build())
+			assert.Equal(t, "http://loki/loki/api/v1/query_range?query="+tc.expected+"&start=(start)&limit=50&step=10s", result)
+		})
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	for function, expected := range map[constants.MetricFunction]string{
+		"p1":    "0.01",
+		"p5":    "0.05",
+		"p50":   "0.5",
+		"p90":   "0.9",
+		"p99":   "0.99",
+		"p999":  "0.999",
+		"p05":   "0.05",
+		"p9999": "0.9999",
+	} {
+		quantile, ok := Quantile(function)
+		assert.True(t, ok, function)
+		assert.Equal(t, expected, quantile, function)
+	}
+	for _, function := range []constants.MetricFunction{"p", "p0", "p00", "p9a", "p-9", "p99999", "p100", "p500", "p9990", "sum", "q50"} {
+		_, ok := Quantile(function)
+		assert.False(t, ok, function)
+	}
+}
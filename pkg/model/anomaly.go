@@ -0,0 +1,114 @@
+package model
+
+import (
+	"math"
+	"sort"
+
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// MaxAnomalyScore caps scores; it is also the score of any point deviating from a constant baseline
+	MaxAnomalyScore = 10.0
+	// madToStdDev scales the median absolute deviation into a standard deviation, for normally distributed values
+	madToStdDev = 1.4826
+	// meanADToStdDev scales the mean absolute deviation into a standard deviation, for normally distributed values
+	meanADToStdDev = 1.2533
+)
+
+// AnomalyQueryResponse represents the json response to a topology query using the anomaly function
+type AnomalyQueryResponse struct {
+	Result        []AnomalySeries `json:"result"`
+	Lookback      string          `json:"lookback"`
+	Stats         AggregatedStats `json:"stats"`
+	UnixTimestamp int64           `json:"unixTimestamp"`
+}
+
+// AnomalySeries is a series of the requested time window, scored against its baseline from the look-back window.
+// Baseline is the median of the look-back values and Deviation their robust standard deviation. Scores holds the
+// score of each point, as its distance to the baseline in deviations, and Score is the highest of them
+type AnomalySeries struct {
+	Metric    model.Metric       `json:"metric"`
+	Values    []model.SamplePair `json:"values"`
+	Scores    []model.SamplePair `json:"scores"`
+	Score     float64            `json:"score"`
+	Baseline  float64            `json:"baseline"`
+	Deviation float64            `json:"deviation"`
+}
+
+// ScoreAnomalies scores the points of each series from the from time, against a baseline computed on the earlier
+// points (median / MAD). Missing points of the look-back window count as zeros, baselinePoints being their expected
+// number. Series are ranked by decreasing score, then limited to limit series when positive
+func ScoreAnomalies(matrix Matrix, from model.Time, baselinePoints, limit int) []AnomalySeries {
+	result := make([]AnomalySeries, 0, len(matrix))
+	for i := range matrix {
+		var baseline []float64
+		as := AnomalySeries{Metric: matrix[i].Metric, Values: []model.SamplePair{}, Scores: []model.SamplePair{}}
+		for _, v := range matrix[i].Values {
+			switch {
+			case math.IsNaN(float64(v.Value)):
+			case v.Timestamp.Before(from):
+				baseline = append(baseline, float64(v.Value))
+			default:
+				as.Values = append(as.Values, v)
+			}
+		}
+		for len(baseline) < baselinePoints {
+			baseline = append(baseline, 0)
+		}
+		if len(as.Values) == 0 || len(baseline) == 0 {
+			// nothing to score, or nothing to score against
+			continue
+		}
+		as.Baseline, as.Deviation = robustStats(baseline)
+		for _, v := range as.Values {
+			score := anomalyScore(float64(v.Value), as.Baseline, as.Deviation)
+			as.Scores = append(as.Scores, model.SamplePair{Timestamp: v.Timestamp, Value: model.SampleValue(score)})
+			as.Score = math.Max(as.Score, score)
+		}
+		result = append(result, as)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Score > result[j].Score })
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// robustStats returns the median of the values, and their standard deviation estimated from the median absolute
+// deviation; the mean absolute deviation is used instead when more than half of the values are equal
+func robustStats(values []float64) (float64, float64) {
+	med := median(values)
+	deviations := make([]float64, len(values))
+	var sum float64
+	for i, v := range values {
+		deviations[i] = math.Abs(v - med)
+		sum += deviations[i]
+	}
+	if mad := median(deviations); mad > 0 {
+		return med, mad * madToStdDev
+	}
+	return med, sum / float64(len(values)) * meanADToStdDev
+}
+
+func anomalyScore(value, baseline, deviation float64) float64 {
+	distance := math.Abs(value - baseline)
+	switch {
+	case distance == 0:
+		return 0
+	case deviation == 0:
+		return MaxAnomalyScore
+	}
+	return math.Min(distance/deviation, MaxAnomalyScore)
+}
+
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
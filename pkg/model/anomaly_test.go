@@ -0,0 +1,60 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScoreAnomalies(t *testing.T) {
+	steady := model.Metric{"SrcK8S_Namespace": "ns1", "DstK8S_Namespace": "ns2"}
+	spiking := model.Metric{"SrcK8S_Namespace": "ns1", "DstK8S_Namespace": "ns3"}
+	appeared := model.Metric{"SrcK8S_Namespace": "ns2", "DstK8S_Namespace": "ns3"}
+	matrix := Matrix{
+		// baseline median 10, MAD 1: scores are distances in deviations (1.4826)
+		{Metric: steady, Values: samples(9, 10, 11, 10, 9, 11, 10)},
+		{Metric: spiking, Values: samples(10, 11, 9, 10, 11, 9, 40)},
+		// no baseline: missing points are zeros
+		{Metric: appeared, Values: []model.SamplePair{{Timestamp: 150000, Value: 5}}},
+	}
+
+	result := ScoreAnomalies(matrix, 150000, 5, 0)
+
+	require.Len(t, result, 3)
+	assert.Equal(t, spiking, result[0].Metric)
+	assert.Equal(t, MaxAnomalyScore, result[0].Score)
+	assert.Equal(t, 10.0, result[0].Baseline)
+	assert.InDelta(t, 1.4826, result[0].Deviation, 0.0001)
+	assert.Equal(t, samples(10, 11, 9, 10, 11, 9, 40)[5:], result[0].Values)
+	require.Len(t, result[0].Scores, 2)
+	assert.InDelta(t, 0.6745, float64(result[0].Scores[0].Value), 0.0001)
+
+	assert.Equal(t, appeared, result[1].Metric)
+	assert.Equal(t, MaxAnomalyScore, result[1].Score)
+	assert.Zero(t, result[1].Baseline)
+
+	assert.Equal(t, steady, result[2].Metric)
+	assert.InDelta(t, 0.6745, result[2].Score, 0.0001)
+
+	// ranked series are limited
+	result = ScoreAnomalies(matrix, 150000, 5, 1)
+	require.Len(t, result, 1)
+	assert.Equal(t, spiking, result[0].Metric)
+}
+
+func TestScoreAnomalies_ConstantBaseline(t *testing.T) {
+	matrix := Matrix{
+		{Metric: model.Metric{"SrcK8S_Namespace": "ns1"}, Values: samples(10, 10, 10, 10, 10)},
+		{Metric: model.Metric{"SrcK8S_Namespace": "ns2"}, Values: samples(10, 10, 10, 10, 12)},
+	}
+
+	result := ScoreAnomalies(matrix, 120000, 4, 0)
+
+	require.Len(t, result, 2)
+	assert.Equal(t, "ns2", string(result[0].Metric["SrcK8S_Namespace"]))
+	assert.Equal(t, MaxAnomalyScore, result[0].Score)
+	assert.Zero(t, result[0].Deviation)
+	assert.Zero(t, result[1].Score)
+}
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"math"
+
+	"github.com/prometheus/common/model"
+)
+
+// Series changes between the baseline and the current window
+const (
+	SeriesAppeared    = "appeared"
+	SeriesDisappeared = "disappeared"
+)
+
+// ComparisonQueryResponse represents the json response to a topology query compared with a past time window
+type ComparisonQueryResponse struct {
+	Result        []ComparedSeries `json:"result"`
+	CompareTo     string           `json:"compareTo"`
+	Stats         AggregatedStats  `json:"stats"`
+	BaselineStats AggregatedStats  `json:"baselineStats"`
+	UnixTimestamp int64            `json:"unixTimestamp"`
+}
+
+// ComparedSeries is a series of the current window with its baseline, from the past window. Current and Baseline
+// are the average values per step over each window; RelativeDelta is not set when there is no baseline.
+// Values and BaselineValues are the samples of each window, and Change flags series missing from either window
+type ComparedSeries struct {
+	Metric         model.Metric       `json:"metric"`
+	Values         []model.SamplePair `json:"values"`
+	BaselineValues []model.SamplePair `json:"baselineValues"`
+	Current        float64            `json:"current"`
+	Baseline       float64            `json:"baseline"`
+	Delta          float64            `json:"delta"`
+	RelativeDelta  *float64           `json:"relativeDelta,omitempty"`
+	Change         string             `json:"change,omitempty"`
+}
+
+// CompareMatrices matches the series of the current and baseline matrices by labels. Series of the current matrix
+// come first, followed by the ones only found in the baseline. Series missing from a window are only flagged as
+// appeared or disappeared when that window didn't reach the limit: otherwise, they may just be ranked below it.
+// Averages are over the steps of a window, where missing samples count as zero, as in ScoreAnomalies
+func CompareMatrices(current, baseline Matrix, steps int, currentLimitReached, baselineLimitReached bool) []ComparedSeries {
+	baselineIndex := make(map[string]int, len(baseline))
+	for i := range baseline {
+		baselineIndex[baseline[i].Metric.String()] = i
+	}
+	result := make([]ComparedSeries, 0, len(current))
+	matched := make(map[int]bool, len(baseline))
+	for i := range current {
+		cs := ComparedSeries{
+			Metric:         current[i].Metric,
+			Values:         current[i].Values,
+			BaselineValues: []model.SamplePair{},
+			Current:        averageValue(current[i].Values, steps),
+		}
+		if idx, ok := baselineIndex[current[i].Metric.String()]; ok {
+			matched[idx] = true
+			cs.BaselineValues = baseline[idx].Values
+			cs.Baseline = averageValue(baseline[idx].Values, steps)
+		} else if !baselineLimitReached {
+			cs.Change = SeriesAppeared
+		}
+		cs.setDeltas()
+		result = append(result, cs)
+	}
+	for i := range baseline {
+		if matched[i] {
+			continue
+		}
+		cs := ComparedSeries{
+			Metric:         baseline[i].Metric,
+			Values:         []model.SamplePair{},
+			BaselineValues: baseline[i].Values,
+			Baseline:       averageValue(baseline[i].Values, steps),
+		}
+		if !currentLimitReached {
+			cs.Change = SeriesDisappeared
+		}
+		cs.setDeltas()
+		result = append(result, cs)
+	}
+	return result
+}
+
+func (cs *ComparedSeries) setDeltas() {
+	cs.Delta = cs.Current - cs.Baseline
+	if cs.Baseline != 0 {
+		relative := cs.Delta / cs.Baseline
+		cs.RelativeDelta = &relative
+	}
+}
+
+// averageValue returns the average of the samples over the steps of the window: missing steps and NaN values count
+// as zero. More samples than steps are averaged among themselves
+func averageValue(values []model.SamplePair, steps int) float64 {
+	var sum float64
+	count := steps
+	if len(values) > count {
+		count = len(values)
+	}
+	for _, v := range values {
+		if !math.IsNaN(float64(v.Value)) {
+			sum += float64(v.Value)
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareMatrices(t *testing.T) {
+	stable := model.Metric{"SrcK8S_Namespace": "ns1", "DstK8S_Namespace": "ns2"}
+	appeared := model.Metric{"SrcK8S_Namespace": "ns1", "DstK8S_Namespace": "ns3"}
+	disappeared := model.Metric{"SrcK8S_Namespace": "ns2", "DstK8S_Namespace": "ns3"}
+	current := Matrix{
+		{Metric: stable, Values: samples(30, 50)},
+		{Metric: appeared, Values: samples(5)},
+	}
+	baseline := Matrix{
+		{Metric: disappeared, Values: samples(8, 12)},
+		{Metric: stable, Values: samples(10, 30)},
+	}
+
+	result := CompareMatrices(current, baseline, 2, false, false)
+
+	require.Len(t, result, 3)
+	assert.Equal(t, stable, result[0].Metric)
+	assert.Equal(t, samples(30, 50), result[0].Values)
+	assert.Equal(t, samples(10, 30), result[0].BaselineValues)
+	assert.Equal(t, 40.0, result[0].Current)
+	assert.Equal(t, 20.0, result[0].Baseline)
+	assert.Equal(t, 20.0, result[0].Delta)
+	require.NotNil(t, result[0].RelativeDelta)
+	assert.Equal(t, 1.0, *result[0].RelativeDelta)
+	assert.Empty(t, result[0].Change)
+
+	// the missing step counts as zero
+	assert.Equal(t, ComparedSeries{
+		Metric:         appeared,
+		Values:         samples(5),
+		BaselineValues: []model.SamplePair{},
+		Current:        2.5,
+		Delta:          2.5,
+		Change:         SeriesAppeared,
+	}, result[1])
+
+	relative := -1.0
+	assert.Equal(t, ComparedSeries{
+		Metric:         disappeared,
+		Values:         []model.SamplePair{},
+		BaselineValues: samples(8, 12),
+		Baseline:       10,
+		Delta:          -10,
+		RelativeDelta:  &relative,
+		Change:         SeriesDisappeared,
+	}, result[2])
+
+	// series missing from a window that reached the limit may be ranked below it
+	result = CompareMatrices(current, baseline, 2, true, true)
+	require.Len(t, result, 3)
+	assert.Empty(t, result[1].Change)
+	assert.Empty(t, result[2].Change)
+	result = CompareMatrices(current, baseline, 2, false, true)
+	assert.Empty(t, result[1].Change)
+	assert.Equal(t, SeriesDisappeared, result[2].Change)
+}
+
+func TestAverageValue(t *testing.T) {
+	assert.Equal(t, 10.0, averageValue(samples(20, 40), 6))
+	assert.Equal(t, 10.0, averageValue(samples(20, math.NaN()), 1))
+	assert.Equal(t, 0.0, averageValue(nil, 0))
+}
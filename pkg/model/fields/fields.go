@@ -53,6 +53,10 @@ const (
 	Duplicate              = "Duplicate"
 	TimeFlowRTT            = "TimeFlowRttNs"
 	TCPFlags               = "Flags"
+	// LabelSelector is not a flow field: filters on pod labels are expanded into namespace and owner filters
+	LabelSelector    = "K8S_LabelSelector"
+	SrcLabelSelector = Src + LabelSelector
+	DstLabelSelector = Dst + LabelSelector
 )
 
 func IsNumeric(v string) bool {
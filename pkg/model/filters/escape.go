@@ -0,0 +1,48 @@
+package filters
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// keyValidation follows the label names syntax, shared by LogQL and PromQL
+	keyValidation = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	// numberValidation accepts integers and decimals
+	numberValidation = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+	// jsonEscaper escapes characters the way they appear in JSON-encoded strings
+	jsonEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	// backticks cannot be escaped in backtick-quoted LogQL strings, so they are written as an hexadecimal regex escape
+	backtickEscaper = strings.NewReplacer("`", `\x60`)
+)
+
+// IsValidKey checks that the key can be safely used as a label or field name in queries
+func IsValidKey(key string) bool {
+	return keyValidation.MatchString(key)
+}
+
+// IsValidNumber checks that the value can be safely used as a numeric value in queries
+func IsValidNumber(value string) bool {
+	return numberValidation.MatchString(value)
+}
+
+// regexValue escapes a filter value so that it's matched literally by a regular expression,
+// except for stars that are converted to the regex wildcard ".*"
+func regexValue(value string) string {
+	parts := strings.Split(value, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return strings.Join(parts, ".*")
+}
+
+// backtickRegexValue escapes a filter value for a regular expression written between backticks
+func backtickRegexValue(value string) string {
+	return backtickEscaper.Replace(regexValue(value))
+}
+
+// jsonRegexValue escapes a filter value for a regular expression written between backticks,
+// that matches the value as it's encoded in a raw JSON line
+func jsonRegexValue(value string) string {
+	return backtickRegexValue(jsonEscaper.Replace(value))
+}
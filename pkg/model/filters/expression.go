@@ -0,0 +1,482 @@
+package filters
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MaxGroups limits the number of OR'ed groups filters can expand to,
+// since each group results in a separate query
+const MaxGroups = 20
+
+// Expression is a node of a parsed filter expression
+type Expression interface {
+	// Position returns the offset of the expression in the source text
+	Position() int
+}
+
+// AndExpression matches when all its operands match
+type AndExpression struct {
+	Operands []Expression
+	Pos      int
+}
+
+// OrExpression matches when any of its operands match
+type OrExpression struct {
+	Operands []Expression
+	Pos      int
+}
+
+// NotExpression matches when its operand doesn't match
+type NotExpression struct {
+	Operand Expression
+	Pos     int
+}
+
+// Comparison compares a field with one or several literal values
+type Comparison struct {
+	Key    string
+	Op     Operator
+	Values []Literal
+	Pos    int
+	OpPos  int
+}
+
+type LiteralKind int
+
+const (
+	LiteralString LiteralKind = iota
+	LiteralNumber
+	LiteralBool
+)
+
+// Literal is a typed value. For strings, Value is unquoted
+type Literal struct {
+	Kind  LiteralKind
+	Value string
+	Pos   int
+}
+
+func (e *AndExpression) Position() int { return e.Pos }
+func (e *OrExpression) Position() int  { return e.Pos }
+func (e *NotExpression) Position() int { return e.Pos }
+func (e *Comparison) Position() int    { return e.Pos }
+
+// ParseExpression parses a filter expression and lowers it into its disjunctive form. Example:
+//
+//	SrcK8S_Namespace = "netobserv" and (SrcPort in (80, 443) or not Proto = 17)
+//
+// Supported operators are = != < <= > >= =~ !~ in, "not in", between and "not between" (closed numeric range, e.g.
+// "Bytes between 100 and 200"), contains and "not contains"; AND, OR and NOT can be combined using parentheses.
+// Literals are double-quoted strings, raw strings between backticks, numbers or booleans. Operators =~ and !~ match
+// RE2 regular expressions against whole values. Operator contains matches a substring, where * stands for any sequence
+// of characters. Unlike Parse, the expression is not URL-decoded.
+func ParseExpression(expr string) (MultiQueries, error) {
+	ast, err := ParseExpressionTree(expr)
+	if err != nil {
+		return nil, err
+	}
+	if ast == nil {
+		return MultiQueries{nil}, nil
+	}
+	return Lower(ast)
+}
+
+// ParseExpressionTree parses a filter expression into its syntax tree. Nil is returned for blank expressions
+func ParseExpressionTree(expr string) (Expression, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, nil
+	}
+	ast, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, errorAt(tok.pos, "unexpected %s", describe(tok))
+	}
+	return ast, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, errorAt(tok.pos, "expected %s, found %s", kind, describe(tok))
+	}
+	return tok, nil
+}
+
+func describe(tok token) string {
+	switch tok.kind {
+	case tokenEOF:
+		return tok.kind.String()
+	case tokenString:
+		return tok.kind.String() + " " + strconv.Quote(tok.text)
+	default:
+		return tok.kind.String() + " '" + tok.text + "'"
+	}
+}
+
+// parseOr parses: and_expression { OR and_expression }
+func (p *parser) parseOr() (Expression, error) {
+	start := p.peek().pos
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	operands := []Expression{first}
+	for p.peek().kind == tokenOr {
+		p.next()
+		operand, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &OrExpression{Operands: operands, Pos: start}, nil
+}
+
+// parseAnd parses: unary { AND unary }
+func (p *parser) parseAnd() (Expression, error) {
+	start := p.peek().pos
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	operands := []Expression{first}
+	for p.peek().kind == tokenAnd {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &AndExpression{Operands: operands, Pos: start}, nil
+}
+
+// parseUnary parses: NOT unary | '(' or_expression ')' | comparison
+func (p *parser) parseUnary() (Expression, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokenNot:
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpression{Operand: operand, Pos: tok.pos}, nil
+	case tokenLParen:
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case tokenIdent:
+		return p.parseComparison()
+	default:
+		return nil, errorAt(tok.pos, "expected field name, NOT or '(', found %s", describe(tok))
+	}
+}
+
+// parseComparison parses:
+//
+//	field operator literal
+//	| field [NOT] CONTAINS literal
+//	| field [NOT] IN '(' literal { ',' literal } ')'
+//	| field [NOT] BETWEEN literal AND literal
+func (p *parser) parseComparison() (Expression, error) {
+	key := p.next()
+	tok := p.next()
+	cmp := Comparison{Key: key.text, Pos: key.pos, OpPos: tok.pos}
+	negated := false
+	if tok.kind == tokenNot {
+		negated = true
+		tok = p.next()
+		if tok.kind != tokenIn && tok.kind != tokenBetween && tok.kind != tokenContains {
+			return nil, errorAt(tok.pos, "expected IN, BETWEEN or CONTAINS, found %s", describe(tok))
+		}
+	}
+	switch tok.kind {
+	case tokenOperator, tokenContains:
+		cmp.Op = Operator(tok.text)
+		if tok.kind == tokenContains {
+			cmp.Op = OpContains
+			if negated {
+				cmp.Op = OpNotContains
+			}
+		}
+		lit, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		cmp.Values = []Literal{lit}
+		return &cmp, nil
+	case tokenBetween:
+		return p.parseBetween(&cmp, negated)
+	case tokenIn:
+		cmp.Op = OpIn
+		if negated {
+			cmp.Op = OpNotIn
+		}
+	default:
+		return nil, errorAt(tok.pos, "expected operator after field %s, found %s", key.text, describe(tok))
+	}
+	if _, err := p.expect(tokenLParen); err != nil {
+		return nil, err
+	}
+	for {
+		lit, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		cmp.Values = append(cmp.Values, lit)
+		sep := p.next()
+		if sep.kind == tokenRParen {
+			return &cmp, nil
+		}
+		if sep.kind != tokenComma {
+			return nil, errorAt(sep.pos, "expected ',' or ')', found %s", describe(sep))
+		}
+	}
+}
+
+func (p *parser) parseBetween(cmp *Comparison, negated bool) (Expression, error) {
+	cmp.Op = OpBetween
+	if negated {
+		cmp.Op = OpNotBetween
+	}
+	lower, err := p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokenAnd); err != nil {
+		return nil, err
+	}
+	upper, err := p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
+	cmp.Values = []Literal{lower, upper}
+	return cmp, nil
+}
+
+func (p *parser) parseLiteral() (Literal, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenString:
+		return Literal{Kind: LiteralString, Value: tok.text, Pos: tok.pos}, nil
+	case tokenNumber:
+		return Literal{Kind: LiteralNumber, Value: tok.text, Pos: tok.pos}, nil
+	case tokenBool:
+		return Literal{Kind: LiteralBool, Value: strings.ToLower(tok.text), Pos: tok.pos}, nil
+	default:
+		return Literal{}, errorAt(tok.pos, "expected string, number or boolean, found %s", describe(tok))
+	}
+}
+
+// Lower transforms an expression tree into its disjunctive form: an union of groups of matches.
+// Negations are pushed down to comparisons
+func Lower(expr Expression) (MultiQueries, error) {
+	return lower(expr, false)
+}
+
+func lower(expr Expression, negate bool) (MultiQueries, error) {
+	switch e := expr.(type) {
+	case *Comparison:
+		return lowerComparison(e, negate)
+	case *NotExpression:
+		return lower(e.Operand, !negate)
+	case *AndExpression:
+		if negate {
+			// De Morgan: not (a and b) = (not a) or (not b)
+			return lowerUnion(e.Operands, negate, e.Pos)
+		}
+		return lowerIntersection(e.Operands, negate, e.Pos)
+	case *OrExpression:
+		if negate {
+			// De Morgan: not (a or b) = (not a) and (not b)
+			return lowerIntersection(e.Operands, negate, e.Pos)
+		}
+		return lowerUnion(e.Operands, negate, e.Pos)
+	}
+	return nil, errorAt(expr.Position(), "unexpected expression")
+}
+
+func lowerUnion(operands []Expression, negate bool, pos int) (MultiQueries, error) {
+	var union MultiQueries
+	for _, operand := range operands {
+		groups, err := lower(operand, negate)
+		if err != nil {
+			return nil, err
+		}
+		union = append(union, groups...)
+		if len(union) > MaxGroups {
+			return nil, errorAt(pos, "expression is too complex: more than %d alternatives", MaxGroups)
+		}
+	}
+	return union, nil
+}
+
+func lowerIntersection(operands []Expression, negate bool, pos int) (MultiQueries, error) {
+	product := MultiQueries{nil}
+	for _, operand := range operands {
+		groups, err := lower(operand, negate)
+		if err != nil {
+			return nil, err
+		}
+		if len(product)*len(groups) > MaxGroups {
+			return nil, errorAt(pos, "expression is too complex: more than %d alternatives", MaxGroups)
+		}
+		var next MultiQueries
+		for _, left := range product {
+			for _, right := range groups {
+				group := make(SingleQuery, 0, len(left)+len(right))
+				group = append(group, left...)
+				group = append(group, right...)
+				next = append(next, group)
+			}
+		}
+		product = next
+	}
+	return product, nil
+}
+
+var negatedOperators = map[Operator]Operator{
+	OpEqual:          OpNotEqual,
+	OpNotEqual:       OpEqual,
+	OpMatch:          OpNotMatch,
+	OpNotMatch:       OpMatch,
+	OpIn:             OpNotIn,
+	OpNotIn:          OpIn,
+	OpLess:           OpGreaterOrEqual,
+	OpGreaterOrEqual: OpLess,
+	OpGreater:        OpLessOrEqual,
+	OpLessOrEqual:    OpGreater,
+	OpBetween:        OpNotBetween,
+	OpNotBetween:     OpBetween,
+	OpContains:       OpNotContains,
+	OpNotContains:    OpContains,
+}
+
+func lowerComparison(c *Comparison, negate bool) (MultiQueries, error) {
+	op := c.Op
+	if negate {
+		op = negatedOperators[op]
+	}
+	switch op {
+	case OpEqual, OpNotEqual, OpIn, OpNotIn:
+		values := make([]string, 0, len(c.Values))
+		for _, lit := range c.Values {
+			if lit.Kind == LiteralString {
+				values = append(values, `"`+EscapeValue(lit.Value)+`"`)
+			} else {
+				values = append(values, lit.Value)
+			}
+		}
+		return single(Match{Key: c.Key, Values: strings.Join(values, ","), Not: op == OpNotEqual || op == OpNotIn}), nil
+	case OpMatch, OpNotMatch:
+		lit := c.Values[0]
+		if lit.Kind != LiteralString {
+			return nil, errorAt(lit.Pos, "operator %s requires a string", op)
+		}
+		if _, err := regexp.Compile(lit.Value); err != nil {
+			return nil, errorAt(lit.Pos, "invalid regular expression: %s", strings.TrimPrefix(err.Error(), "error parsing regexp: "))
+		}
+		return single(Match{Key: c.Key, Values: EscapeValue(lit.Value), Not: op == OpNotMatch, Regex: true, CaseSensitive: true}), nil
+	case OpContains, OpNotContains:
+		lit := c.Values[0]
+		if lit.Kind != LiteralString {
+			return nil, errorAt(lit.Pos, "operator %s requires a string", op)
+		}
+		// unquoted values are substrings, where stars are wildcards
+		return single(Match{Key: c.Key, Values: EscapeValue(lit.Value), Not: op == OpNotContains}), nil
+	case OpGreaterOrEqual, OpGreater, OpLessOrEqual, OpLess:
+		m, err := lowerNumericComparison(c.Key, op, c.Values[0])
+		if err != nil {
+			return nil, err
+		}
+		return single(m), nil
+	case OpBetween:
+		// closed range: lower <= field <= upper
+		lower, err := lowerNumericComparison(c.Key, OpGreaterOrEqual, c.Values[0])
+		if err != nil {
+			return nil, err
+		}
+		upper, err := lowerNumericComparison(c.Key, OpLessOrEqual, c.Values[1])
+		if err != nil {
+			return nil, err
+		}
+		return MultiQueries{{lower, upper}}, nil
+	case OpNotBetween:
+		// outside of range: field < lower OR field > upper
+		lower, err := lowerNumericComparison(c.Key, OpLess, c.Values[0])
+		if err != nil {
+			return nil, err
+		}
+		upper, err := lowerNumericComparison(c.Key, OpGreater, c.Values[1])
+		if err != nil {
+			return nil, err
+		}
+		return MultiQueries{{lower}, {upper}}, nil
+	}
+	return nil, errorAt(c.OpPos, "unknown operator %s", op)
+}
+
+func single(m Match) MultiQueries {
+	return MultiQueries{{m}}
+}
+
+// lowerNumericComparison converts a comparison into an inclusive bound. Strict comparisons
+// require integers, which is the case of all numeric flow fields
+func lowerNumericComparison(key string, op Operator, lit Literal) (Match, error) {
+	if lit.Kind != LiteralNumber {
+		return Match{}, errorAt(lit.Pos, "operator %s requires a number", op)
+	}
+	switch op {
+	case OpGreaterOrEqual:
+		return NewMoreThanOrEqualMatch(key, lit.Value), nil
+	case OpLessOrEqual:
+		return NewLessThanOrEqualMatch(key, lit.Value), nil
+	}
+	n, err := strconv.ParseInt(lit.Value, 10, 64)
+	if err != nil {
+		return Match{}, errorAt(lit.Pos, "operator %s requires an integer", op)
+	}
+	if op == OpGreater {
+		return NewMoreThanOrEqualMatch(key, strconv.FormatInt(n+1, 10)), nil
+	}
+	return NewLessThanOrEqualMatch(key, strconv.FormatInt(n-1, 10)), nil
+}
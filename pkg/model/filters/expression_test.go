@@ -0,0 +1,188 @@
+package filters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpression_Simple(t *testing.T) {
+	groups, err := ParseExpression(`SrcK8S_Namespace = "default" and SrcPort = 8080`)
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{
+		{NewMatch("SrcK8S_Namespace", `"default"`), NewMatch("SrcPort", "8080")},
+	}, groups)
+}
+
+func TestParseExpression_Empty(t *testing.T) {
+	groups, err := ParseExpression("  ")
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{nil}, groups)
+}
+
+func TestParseExpression_Operators(t *testing.T) {
+	groups, err := ParseExpression(`a != "x" AND b contains "fo*o" and c not contains "bar" and d >= 10 and e > 10 and f in ("x", 2, true) and g not in (1)`)
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{{
+		NewNotMatch("a", `"x"`),
+		NewMatch("b", "fo*o"),
+		NewNotMatch("c", "bar"),
+		NewMoreThanOrEqualMatch("d", "10"),
+		NewMoreThanOrEqualMatch("e", "11"),
+		NewMatch("f", `"x",2,true`),
+		NewNotMatch("g", "1"),
+	}}, groups)
+}
+
+func TestParseExpression_Regexes(t *testing.T) {
+	groups, err := ParseExpression("SrcK8S_Name =~ `api-\\d+` and DstK8S_Name !~ \"db-[a-z]{1,3}\" and not DstK8S_Namespace =~ \"kube-.*\"")
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{{
+		NewRegexMatch("SrcK8S_Name", `api-\\d+`),
+		NewNotRegexMatch("DstK8S_Name", `db-[a-z]{1\,3}`),
+		NewNotRegexMatch("DstK8S_Namespace", "kube-.*"),
+	}}, groups)
+	assert.Equal(t, []string{`api-\d+`}, groups[0][0].SplitValues())
+	assert.Equal(t, []string{`db-[a-z]{1,3}`}, groups[0][1].SplitValues())
+}
+
+func TestParseExpression_Ranges(t *testing.T) {
+	groups, err := ParseExpression(`Bytes < 100 and Packets <= 10 and DnsLatencyMs between 5 and 20.5`)
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{{
+		NewLessThanOrEqualMatch("Bytes", "99"),
+		NewLessThanOrEqualMatch("Packets", "10"),
+		NewMoreThanOrEqualMatch("DnsLatencyMs", "5"),
+		NewLessThanOrEqualMatch("DnsLatencyMs", "20.5"),
+	}}, groups)
+
+	// not between => below or above the range
+	groups, err = ParseExpression(`SrcPort not between 1000 and 2000`)
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{
+		{NewLessThanOrEqualMatch("SrcPort", "999")},
+		{NewMoreThanOrEqualMatch("SrcPort", "2001")},
+	}, groups)
+
+	// negations are applied to ranges
+	groups, err = ParseExpression(`not (Bytes >= 100 or SrcPort between 1 and 2)`)
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{
+		{NewLessThanOrEqualMatch("Bytes", "99"), NewLessThanOrEqualMatch("SrcPort", "0")},
+		{NewLessThanOrEqualMatch("Bytes", "99"), NewMoreThanOrEqualMatch("SrcPort", "3")},
+	}, groups)
+}
+
+func TestParseExpression_Precedence(t *testing.T) {
+	// AND takes precedence over OR
+	groups, err := ParseExpression(`a = 1 or b = 2 and c = 3`)
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{
+		{NewMatch("a", "1")},
+		{NewMatch("b", "2"), NewMatch("c", "3")},
+	}, groups)
+}
+
+func TestParseExpression_Nesting(t *testing.T) {
+	groups, err := ParseExpression(`(a = 1 or b = 2) and (c = 3 or d = 4)`)
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{
+		{NewMatch("a", "1"), NewMatch("c", "3")},
+		{NewMatch("a", "1"), NewMatch("d", "4")},
+		{NewMatch("b", "2"), NewMatch("c", "3")},
+		{NewMatch("b", "2"), NewMatch("d", "4")},
+	}, groups)
+}
+
+func TestParseExpression_Not(t *testing.T) {
+	// not (a or (b and c)) => not a and (not b or not c)
+	groups, err := ParseExpression(`not (a = 1 or (b contains "x" and c in ("y", "z")))`)
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{
+		{NewNotMatch("a", "1"), NewNotMatch("b", "x")},
+		{NewNotMatch("a", "1"), NewNotMatch("c", `"y","z"`)},
+	}, groups)
+
+	// double negation
+	groups, err = ParseExpression(`NOT NOT a = "x"`)
+	require.NoError(t, err)
+
+	assert.Equal(t, MultiQueries{{NewMatch("a", `"x"`)}}, groups)
+}
+
+func TestParseExpression_SpecialCharacters(t *testing.T) {
+	// values can contain characters that are separators in the legacy syntax
+	groups, err := ParseExpression(`SrcK8S_Name = "a=b&c|d,e" and DstK8S_Name in ("x\"y", "1\\2")`)
+	require.NoError(t, err)
+
+	require.Len(t, groups, 1)
+	require.Len(t, groups[0], 2)
+	assert.Equal(t, []string{`"a=b&c|d,e"`}, groups[0][0].SplitValues())
+	assert.Equal(t, []string{`"x"y"`, `"1\2"`}, groups[0][1].SplitValues())
+}
+
+func TestParseExpression_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		pos  int
+		msg  string
+	}{
+		{expr: `a = `, pos: 4, msg: "expected string, number or boolean, found end of expression"},
+		{expr: `a "x"`, pos: 2, msg: `expected operator after field a, found string "x"`},
+		{expr: `a = "x" b = 1`, pos: 8, msg: "unexpected field name 'b'"},
+		{expr: `(a = 1 or b = 2`, pos: 15, msg: "expected ')', found end of expression"},
+		{expr: `a = "unterminated`, pos: 4, msg: "unterminated or invalid string"},
+		{expr: `a = 1 & b = 2`, pos: 6, msg: "unexpected character '&'"},
+		{expr: `a = 12abc`, pos: 4, msg: "invalid number"},
+		{expr: `a in (1 2)`, pos: 8, msg: "expected ',' or ')', found number '2'"},
+		{expr: `a not = 1`, pos: 6, msg: "expected IN, BETWEEN or CONTAINS, found operator '='"},
+		{expr: `and a = 1`, pos: 0, msg: "expected field name, NOT or '(', found AND 'and'"},
+		{expr: `a =~ 1`, pos: 5, msg: "operator =~ requires a string"},
+		{expr: `a =~ "(x"`, pos: 5, msg: "invalid regular expression: missing closing ): `(x`"},
+		{expr: `a !~ "x**"`, pos: 5, msg: "invalid regular expression: invalid nested repetition operator: `**`"},
+		{expr: `a contains 1`, pos: 11, msg: "operator contains requires a string"},
+		{expr: `a >= "x"`, pos: 5, msg: "operator >= requires a number"},
+		{expr: `a > 1.5`, pos: 4, msg: "operator > requires an integer"},
+		{expr: `a < 1.5`, pos: 4, msg: "operator < requires an integer"},
+		{expr: `a between 1 or 2`, pos: 12, msg: "expected AND, found OR 'or'"},
+		{expr: `a between "x" and 2`, pos: 10, msg: "operator >= requires a number"},
+		{expr: `a not between 1 and 2.5`, pos: 20, msg: "operator > requires an integer"},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			_, err := ParseExpression(tc.expr)
+			require.Error(t, err)
+			var parseErr *ParseError
+			require.ErrorAs(t, err, &parseErr)
+			assert.Equal(t, tc.pos, parseErr.Pos)
+			assert.Equal(t, tc.msg, parseErr.Msg)
+		})
+	}
+}
+
+func TestParseExpression_TooComplex(t *testing.T) {
+	_, err := ParseExpression(`(a=1 or a=2 or a=3) and (b=1 or b=2 or b=3) and (c=1 or c=2 or c=3)`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "too complex")
+}
+
+func TestSplitValues(t *testing.T) {
+	m := NewMatch("foo", "a,b")
+	assert.Equal(t, []string{"a", "b"}, m.SplitValues())
+
+	m = NewMatch("foo", `"`+EscapeValue(`a,b\c`)+`",d`)
+	assert.Equal(t, []string{`"a,b\c"`, "d"}, m.SplitValues())
+
+	m = NewMatch("foo", "")
+	assert.Equal(t, []string{""}, m.SplitValues())
+}
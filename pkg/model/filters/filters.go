@@ -1,7 +1,9 @@
 package filters
 
 import (
+	"fmt"
 	"net/url"
+	"regexp"
 	"strings"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
@@ -14,13 +16,24 @@ type MultiQueries = []SingleQuery
 // singleQuery is an intersect group of matches (AND'ed)
 type SingleQuery = []Match
 
+// Match is a filter on a field. Values are comma-separated, and matched when any of them matches;
+// when negated, the field must match none of them
 type Match struct {
 	Key             string
 	Values          string
 	Not             bool
 	MoreThanOrEqual bool
+	LessThanOrEqual bool
+	// Regex values are RE2 regular expressions, that must match whole field values
+	Regex bool
+	// CaseSensitive applies to patterns and regular expressions, the latter being case-sensitive by default.
+	// Exact matches are always case-sensitive
+	CaseSensitive bool
 }
 
+// valuesEscaper escapes the values separator, so that a single value can contain commas
+var valuesEscaper = strings.NewReplacer(`\`, `\\`, `,`, `\,`)
+
 func NewMatch(key, values string) Match { return Match{Key: key, Values: values} }
 func NewNotMatch(key, values string) Match {
 	return Match{Key: key, Values: values, Not: true, MoreThanOrEqual: false}
@@ -28,9 +41,25 @@ func NewNotMatch(key, values string) Match {
 func NewMoreThanOrEqualMatch(key, values string) Match {
 	return Match{Key: key, Values: values, Not: false, MoreThanOrEqual: true}
 }
+func NewLessThanOrEqualMatch(key, values string) Match {
+	return Match{Key: key, Values: values, Not: false, LessThanOrEqual: true}
+}
+func NewRegexMatch(key, values string) Match {
+	return Match{Key: key, Values: values, Regex: true, CaseSensitive: true}
+}
+func NewNotRegexMatch(key, values string) Match {
+	return Match{Key: key, Values: values, Not: true, Regex: true, CaseSensitive: true}
+}
+
+// IsComparison returns true for numeric comparisons (more or less than)
+func (m *Match) IsComparison() bool {
+	return m.MoreThanOrEqual || m.LessThanOrEqual
+}
 
 // Example of raw filters (url-encoded):
 // foo=a,b&bar=c|baz=d
+// Operators "!=", ">=" and "<=" are also supported, as well as "=~" and "!~" for regular expressions.
+// A regular expression is a single value: commas are not separators, but it cannot contain "&", "|" or "=".
 // Produces:
 // [ [ ["foo", "a,b"], ["bar", "c"]], [["baz", "d"]]]
 // ^ ^ ^
@@ -48,14 +77,26 @@ func Parse(raw string) (MultiQueries, error) {
 		var andFilters []Match
 		filters := strings.Split(group, "&")
 		for _, filter := range filters {
+			if key, regex, ok := cutRegexOperator(filter); ok {
+				m, err := parseRegexMatch(key, regex)
+				if err != nil {
+					return nil, err
+				}
+				andFilters = append(andFilters, m)
+				continue
+			}
 			pair := strings.Split(filter, "=")
 			if len(pair) == 2 {
+				// commas are separators in this syntax, only backslashes need to be escaped
+				values := strings.ReplaceAll(pair[1], `\`, `\\`)
 				if strings.HasSuffix(pair[0], "!") {
-					andFilters = append(andFilters, NewNotMatch(strings.TrimSuffix(pair[0], "!"), pair[1]))
+					andFilters = append(andFilters, NewNotMatch(strings.TrimSuffix(pair[0], "!"), values))
 				} else if strings.HasSuffix(pair[0], ">") {
-					andFilters = append(andFilters, NewMoreThanOrEqualMatch(strings.TrimSuffix(pair[0], ">"), pair[1]))
+					andFilters = append(andFilters, NewMoreThanOrEqualMatch(strings.TrimSuffix(pair[0], ">"), values))
+				} else if strings.HasSuffix(pair[0], "<") {
+					andFilters = append(andFilters, NewLessThanOrEqualMatch(strings.TrimSuffix(pair[0], "<"), values))
 				} else {
-					andFilters = append(andFilters, NewMatch(pair[0], pair[1]))
+					andFilters = append(andFilters, NewMatch(pair[0], values))
 				}
 			}
 		}
@@ -64,25 +105,92 @@ func Parse(raw string) (MultiQueries, error) {
 	return parsed, nil
 }
 
-func SplitForReportersMerge(q SingleQuery) (SingleQuery, SingleQuery) {
+// cutRegexOperator splits a filter using the =~ or !~ operator. The key is suffixed with "!" for the latter
+func cutRegexOperator(filter string) (string, string, bool) {
+	if strings.Count(filter, "=") == 1 {
+		if key, regex, ok := strings.Cut(filter, "=~"); ok {
+			return key, regex, true
+		}
+	} else if !strings.Contains(filter, "=") {
+		if key, regex, ok := strings.Cut(filter, "!~"); ok {
+			return key + "!", regex, true
+		}
+	}
+	return "", "", false
+}
+
+func parseRegexMatch(key, regex string) (Match, error) {
+	m := NewRegexMatch(key, EscapeValue(regex))
+	if k, ok := strings.CutSuffix(key, "!"); ok {
+		m = NewNotRegexMatch(k, EscapeValue(regex))
+	}
+	if err := m.ValidateRegexes(); err != nil {
+		return Match{}, err
+	}
+	return m, nil
+}
+
+// ValidateRegexes checks that the values of a regex match are valid RE2 regular expressions
+func (m *Match) ValidateRegexes() error {
+	if !m.Regex {
+		return nil
+	}
+	for _, value := range m.SplitValues() {
+		if _, err := regexp.Compile(value); err != nil {
+			return fmt.Errorf("invalid regular expression in %s filter: %w", m.Key, err)
+		}
+	}
+	return nil
+}
+
+// SplitForReportersMerge splits the query in two, so that flows reported by both the ingress and egress nodes are
+// counted once according to the strategy. Both queries are summed, except with the max strategy where the largest
+// of both is kept. The second query is nil when there is nothing to merge
+func SplitForReportersMerge(q SingleQuery, strategy constants.ReportersMerge) (SingleQuery, SingleQuery) {
 	// If FlowDirection is enforced, skip merging both reporters
 	for _, m := range q {
 		if m.Key == fields.FlowDirection {
 			return q, nil
 		}
 	}
-	// The rationale here is that most traffic is duplicated from ingress and egress PoV, except cluster-external traffic.
-	// Ingress traffic will also contains pktDrop and DNS responses.
-	// Merging is done by running a first query with FlowDirection=INGRESS and another with FlowDirection=EGRESS AND DstOwnerName is empty,
-	// which stands for cluster-external.
-	// (Note that we use DstOwnerName both as an optimization as it's a Loki index,
-	// and as convenience because looking for empty fields won't work if they aren't indexed)
-	q1 := SingleQuery{
-		NewMatch(fields.FlowDirection, `"`+string(constants.Ingress)+`","`+string(constants.Inner)+`"`),
-	}
-	q2 := SingleQuery{
-		NewMatch(fields.FlowDirection, `"`+string(constants.Egress)+`"`),
-		NewMatch(fields.DstOwnerName, `""`),
+	var q1, q2 SingleQuery
+	switch strategy {
+	case constants.ReportersMergeNone:
+		return q, nil
+	case constants.ReportersMergeEgress:
+		// Mirrors the ingress strategy: egress flows, and ingress flows with an empty SrcOwnerName
+		// which stands for cluster-external.
+		q1 = SingleQuery{
+			NewMatch(fields.FlowDirection, `"`+string(constants.Egress)+`","`+string(constants.Inner)+`"`),
+		}
+		q2 = SingleQuery{
+			NewMatch(fields.FlowDirection, `"`+string(constants.Ingress)+`"`),
+			NewMatch(fields.SrcOwnerName, `""`),
+		}
+	case constants.ReportersMergeMax:
+		// Both directions, including inner traffic which is reported once, are compared per series
+		q1 = SingleQuery{
+			NewMatch(fields.FlowDirection, `"`+string(constants.Ingress)+`","`+string(constants.Inner)+`"`),
+		}
+		q2 = SingleQuery{
+			NewMatch(fields.FlowDirection, `"`+string(constants.Egress)+`","`+string(constants.Inner)+`"`),
+		}
+	case constants.ReportersMergeIngress, "":
+		// The rationale here is that most traffic is duplicated from ingress and egress PoV, except cluster-external traffic.
+		// Ingress traffic will also contains pktDrop and DNS responses.
+		// Merging is done by running a first query with FlowDirection=INGRESS and another with FlowDirection=EGRESS AND DstOwnerName is empty,
+		// which stands for cluster-external.
+		// (Note that we use DstOwnerName both as an optimization as it's a Loki index,
+		// and as convenience because looking for empty fields won't work if they aren't indexed)
+		q1 = SingleQuery{
+			NewMatch(fields.FlowDirection, `"`+string(constants.Ingress)+`","`+string(constants.Inner)+`"`),
+		}
+		q2 = SingleQuery{
+			NewMatch(fields.FlowDirection, `"`+string(constants.Egress)+`"`),
+			NewMatch(fields.DstOwnerName, `""`),
+		}
+	default:
+		return q, nil
 	}
 	for _, m := range q {
 		q1 = append(q1, m)
@@ -91,17 +199,55 @@ func SplitForReportersMerge(q SingleQuery) (SingleQuery, SingleQuery) {
 	return q1, q2
 }
 
+// EscapeValue escapes a single value so that it can be used in Match.Values
+func EscapeValue(value string) string {
+	return valuesEscaper.Replace(value)
+}
+
+// SplitValues returns the comma-separated values of the match, unescaped
+func (m *Match) SplitValues() []string {
+	var values []string
+	current := strings.Builder{}
+	escaped := false
+	for _, r := range m.Values {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',':
+			values = append(values, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(values, current.String())
+}
+
 func (m *Match) ToLabelFilter() (LabelFilter, bool) {
-	values := strings.Split(m.Values, ",")
-	if len(values) == 1 && isExactMatch(values[0]) {
+	values := m.SplitValues()
+	if m.IsComparison() {
+		if len(values) != 1 {
+			return LabelFilter{}, false
+		}
+		if m.MoreThanOrEqual {
+			return MoreThanNumberLabelFilter(m.Key, trimExactMatch(values[0])), true
+		}
+		return LessThanNumberLabelFilter(m.Key, trimExactMatch(values[0])), true
+	}
+	if m.Regex {
+		return RegexLabelFilter(m.Key, values, m.Not, m.CaseSensitive), true
+	}
+	// quoted values containing a star are patterns, managed as regex below
+	if len(values) == 1 && isExactMatch(values[0]) && !strings.Contains(values[0], "*") {
 		if m.Not {
 			return NotStringLabelFilter(m.Key, trimExactMatch(values[0])), true
-		} else if m.MoreThanOrEqual {
-			return MoreThanNumberLabelFilter(m.Key, trimExactMatch(values[0])), true
 		}
 		return StringEqualLabelFilter(m.Key, trimExactMatch(values[0])), true
 	}
-	return MultiValuesRegexFilter(m.Key, values, m.Not)
+	return MultiValuesRegexFilter(m.Key, values, m.Not, m.CaseSensitive)
 }
 
 func isExactMatch(value string) bool {
@@ -2,6 +2,7 @@ package filters
 
 import (
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
@@ -63,6 +64,16 @@ func TestParseFilters(t *testing.T) {
 	}, groups[0])
 }
 
+func TestParseFilters_Backslash(t *testing.T) {
+	// commas are separators, but backslashes are kept as is
+	groups, err := Parse(url.QueryEscape(`foo=a\,b`))
+	require.NoError(t, err)
+
+	require.Len(t, groups, 1)
+	require.Len(t, groups[0], 1)
+	assert.Equal(t, []string{`a\`, "b"}, groups[0][0].SplitValues())
+}
+
 func TestParseCommon(t *testing.T) {
 	groups, err := Parse(url.QueryEscape("srcns=a|srcns!=a&dstns=a"))
 	require.NoError(t, err)
@@ -77,8 +88,59 @@ func TestParseCommon(t *testing.T) {
 	}, groups[1])
 }
 
+func TestParseComparisons(t *testing.T) {
+	groups, err := Parse(url.QueryEscape("Bytes>=100&Bytes<=200|Packets<=1"))
+	require.NoError(t, err)
+
+	assert.Len(t, groups, 2)
+	assert.Equal(t, SingleQuery{
+		NewMoreThanOrEqualMatch("Bytes", "100"),
+		NewLessThanOrEqualMatch("Bytes", "200"),
+	}, groups[0])
+	assert.Equal(t, SingleQuery{
+		NewLessThanOrEqualMatch("Packets", "1"),
+	}, groups[1])
+	assert.True(t, groups[1][0].IsComparison())
+}
+
+func TestParseRegexes(t *testing.T) {
+	groups, err := Parse(url.QueryEscape(`SrcK8S_Name=~api-[0-9]{1,3}&DstK8S_Name!~db\..*`))
+	require.NoError(t, err)
+
+	assert.Equal(t, SingleQuery{
+		NewRegexMatch("SrcK8S_Name", `api-[0-9]{1\,3}`),
+		NewNotRegexMatch("DstK8S_Name", `db\\..*`),
+	}, groups[0])
+	// commas and backslashes are part of the regex
+	assert.Equal(t, []string{"api-[0-9]{1,3}"}, groups[0][0].SplitValues())
+	assert.Equal(t, []string{`db\..*`}, groups[0][1].SplitValues())
+
+	_, err = Parse(url.QueryEscape(`SrcK8S_Name=~api-(`))
+	assert.EqualError(t, err, "invalid regular expression in SrcK8S_Name filter: error parsing regexp: missing closing ): `api-(`")
+}
+
+func TestToLabelFilter_Regexes(t *testing.T) {
+	for _, tc := range []struct {
+		match    Match
+		expected string
+	}{
+		{match: NewRegexMatch("SrcK8S_Name", "api-[0-9]+"), expected: `SrcK8S_Name=~"api-[0-9]+"`},
+		{match: NewNotRegexMatch("SrcK8S_Name", "a|b,c"), expected: `SrcK8S_Name!~"(?:a|b)|(?:c)"`},
+		{match: Match{Key: "SrcK8S_Name", Values: EscapeValue(`a\.b`), Regex: true}, expected: `SrcK8S_Name=~"(?i)a\\.b"`},
+		// case sensitivity also applies to patterns
+		{match: Match{Key: "SrcK8S_Name", Values: `api,"db*"`, CaseSensitive: true}, expected: `SrcK8S_Name=~".*api.*|^db.*"`},
+		{match: NewMatch("SrcK8S_Name", "api"), expected: `SrcK8S_Name=~"(?i).*api.*"`},
+	} {
+		lf, ok := tc.match.ToLabelFilter()
+		require.True(t, ok)
+		sb := strings.Builder{}
+		lf.WriteInto(&sb)
+		assert.Equal(t, tc.expected, sb.String())
+	}
+}
+
 func TestSplitForReportersMerge_NoSplit(t *testing.T) {
-	q1, q2 := SplitForReportersMerge(SingleQuery{NewMatch("srcns", "a"), NewMatch("FlowDirection", string(constants.Ingress))})
+	q1, q2 := SplitForReportersMerge(SingleQuery{NewMatch("srcns", "a"), NewMatch("FlowDirection", string(constants.Ingress))}, constants.ReportersMergeIngress)
 	assert.Nil(t, q2)
 	assert.Len(t, q1, 2)
 	assert.Equal(t, SingleQuery{
@@ -88,7 +150,7 @@ func TestSplitForReportersMerge_NoSplit(t *testing.T) {
 }
 
 func TestSplitForReportersMerge(t *testing.T) {
-	q1, q2 := SplitForReportersMerge(SingleQuery{NewMatch("srcns", "a"), NewMatch("dstns", "b")})
+	q1, q2 := SplitForReportersMerge(SingleQuery{NewMatch("srcns", "a"), NewMatch("dstns", "b")}, constants.ReportersMergeIngress)
 
 	assert.Len(t, q1, 3)
 	assert.Equal(t, SingleQuery{
@@ -104,3 +166,33 @@ func TestSplitForReportersMerge(t *testing.T) {
 		NewMatch("dstns", "b"),
 	}, q2)
 }
+
+func TestSplitForReportersMerge_Strategies(t *testing.T) {
+	q := SingleQuery{NewMatch("srcns", "a")}
+
+	q1, q2 := SplitForReportersMerge(q, constants.ReportersMergeEgress)
+	assert.Equal(t, SingleQuery{
+		NewMatch("FlowDirection", `"`+string(constants.Egress)+`","`+string(constants.Inner)+`"`),
+		NewMatch("srcns", "a"),
+	}, q1)
+	assert.Equal(t, SingleQuery{
+		NewMatch("FlowDirection", `"`+string(constants.Ingress)+`"`),
+		NewMatch("SrcK8S_OwnerName", `""`),
+		NewMatch("srcns", "a"),
+	}, q2)
+
+	// with max, inner flows are in both queries, which are compared instead of summed
+	q1, q2 = SplitForReportersMerge(q, constants.ReportersMergeMax)
+	assert.Equal(t, SingleQuery{
+		NewMatch("FlowDirection", `"`+string(constants.Ingress)+`","`+string(constants.Inner)+`"`),
+		NewMatch("srcns", "a"),
+	}, q1)
+	assert.Equal(t, SingleQuery{
+		NewMatch("FlowDirection", `"`+string(constants.Egress)+`","`+string(constants.Inner)+`"`),
+		NewMatch("srcns", "a"),
+	}, q2)
+
+	q1, q2 = SplitForReportersMerge(q, constants.ReportersMergeNone)
+	assert.Equal(t, q, q1)
+	assert.Nil(t, q2)
+}
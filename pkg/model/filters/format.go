@@ -0,0 +1,139 @@
+package filters
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Operator returns the operator of the match, as written in the syntax read by Parse
+func (m *Match) Operator() string {
+	switch {
+	case m.MoreThanOrEqual:
+		return ">="
+	case m.LessThanOrEqual:
+		return "<="
+	case m.Regex && m.Not:
+		return "!~"
+	case m.Regex:
+		return "=~"
+	case m.Not:
+		return "!="
+	}
+	return "="
+}
+
+// Format writes filters in the syntax read by Parse, before URL encoding. The second value is false
+// when a value cannot be written in this syntax, such as values containing separators
+func Format(mq MultiQueries) (string, bool) {
+	groups := make([]string, 0, len(mq))
+	for _, group := range mq {
+		matches := make([]string, 0, len(group))
+		for i := range group {
+			values := group[i].SplitValues()
+			for _, value := range values {
+				// commas are allowed in a regular expression, but it can't be split; other values can't start with
+				// the regular expression marker
+				if strings.ContainsAny(value, "&|=") || (!group[i].Regex && (strings.Contains(value, ",") || strings.HasPrefix(value, "~"))) {
+					return "", false
+				}
+			}
+			if group[i].Regex && len(values) > 1 {
+				return "", false
+			}
+			matches = append(matches, group[i].Key+group[i].Operator()+strings.Join(values, ","))
+		}
+		groups = append(groups, strings.Join(matches, "&"))
+	}
+	return strings.Join(groups, "|"), true
+}
+
+// FormatExpression writes filters as a filter expression, see ParseExpression. Groups are OR'ed, and their matches AND'ed
+func FormatExpression(mq MultiQueries) string {
+	groups := make([]string, 0, len(mq))
+	for _, group := range mq {
+		matches := make([]string, 0, len(group))
+		for i := range group {
+			matches = append(matches, formatExpressionMatch(&group[i]))
+		}
+		if len(mq) > 1 && len(matches) > 1 {
+			groups = append(groups, "("+strings.Join(matches, " AND ")+")")
+		} else {
+			groups = append(groups, strings.Join(matches, " AND "))
+		}
+	}
+	return strings.Join(groups, " OR ")
+}
+
+func formatExpressionMatch(m *Match) string {
+	values := m.SplitValues()
+	if m.IsComparison() {
+		return m.Key + " " + m.Operator() + " " + trimExactMatch(values[0])
+	}
+	if m.Regex {
+		return formatExpressionRegexes(m, values)
+	}
+	// exact matches and typed literals are written as = or IN, others as substring matches
+	var literals, patterns []string
+	for _, value := range values {
+		switch {
+		case isExactMatch(value) && len(value) > 1:
+			literals = append(literals, strconv.Quote(trimExactMatch(value)))
+		case value == "true" || value == "false" || IsValidNumber(value):
+			literals = append(literals, value)
+		default:
+			patterns = append(patterns, strconv.Quote(value))
+		}
+	}
+	if m.Not {
+		// a negated match excludes all its values
+		switch {
+		case len(patterns) == 0 && len(literals) == 1:
+			return m.Key + " != " + literals[0]
+		case len(patterns) == 0:
+			return m.Key + " NOT IN (" + strings.Join(literals, ", ") + ")"
+		case len(literals) == 0 && len(patterns) == 1:
+			return m.Key + " NOT CONTAINS " + patterns[0]
+		}
+		return "NOT " + formatExpressionAlternatives(m.Key, literals, patterns, true)
+	}
+	return formatExpressionAlternatives(m.Key, literals, patterns, false)
+}
+
+// formatExpressionAlternatives writes the literals and patterns as OR'ed comparisons
+func formatExpressionAlternatives(key string, literals, patterns []string, forceParens bool) string {
+	var parts []string
+	switch len(literals) {
+	case 0:
+	case 1:
+		parts = append(parts, key+" = "+literals[0])
+	default:
+		parts = append(parts, key+" IN ("+strings.Join(literals, ", ")+")")
+	}
+	for _, pattern := range patterns {
+		parts = append(parts, key+" CONTAINS "+pattern)
+	}
+	return joinAlternatives(parts, forceParens)
+}
+
+// formatExpressionRegexes writes regular expressions as =~ comparisons. A negated match excludes all of them
+func formatExpressionRegexes(m *Match, regexes []string) string {
+	op := " =~ "
+	if m.Not && len(regexes) == 1 {
+		op = " !~ "
+	}
+	parts := make([]string, 0, len(regexes))
+	for _, regex := range regexes {
+		parts = append(parts, m.Key+op+strconv.Quote(regex))
+	}
+	if m.Not && len(regexes) > 1 {
+		return "NOT " + joinAlternatives(parts, true)
+	}
+	return joinAlternatives(parts, false)
+}
+
+func joinAlternatives(parts []string, forceParens bool) string {
+	if len(parts) == 1 && !forceParens {
+		return parts[0]
+	}
+	return "(" + strings.Join(parts, " OR ") + ")"
+}
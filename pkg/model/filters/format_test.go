@@ -0,0 +1,95 @@
+package filters
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	raw := `SrcK8S_Namespace="ns"&SrcPort!=80,443|Bytes>=100&DstK8S_Name=test`
+	groups, err := Parse(url.QueryEscape(raw))
+	require.NoError(t, err)
+
+	formatted, ok := Format(groups)
+	require.True(t, ok)
+	assert.Equal(t, raw, formatted)
+
+	// values containing separators cannot be written
+	_, ok = Format(MultiQueries{{NewMatch("SrcK8S_Name", EscapeValue("a,b"))}})
+	assert.False(t, ok)
+	_, ok = Format(MultiQueries{{NewMatch("SrcK8S_Name", "~a")}})
+	assert.False(t, ok)
+
+	// regular expressions are single values, that can contain commas
+	raw = `SrcK8S_Name=~api-[0-9]{1,3}&DstK8S_Name!~db.*`
+	groups, err = Parse(url.QueryEscape(raw))
+	require.NoError(t, err)
+	formatted, ok = Format(groups)
+	require.True(t, ok)
+	assert.Equal(t, raw, formatted)
+}
+
+func TestFormatExpression(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		filters  MultiQueries
+		expected string
+	}{
+		{
+			name:     "exact and numbers",
+			filters:  MultiQueries{{NewMatch("SrcK8S_Namespace", `"ns"`), NewMatch("SrcPort", "80,443")}},
+			expected: `SrcK8S_Namespace = "ns" AND SrcPort IN (80, 443)`,
+		},
+		{
+			name:     "patterns",
+			filters:  MultiQueries{{NewMatch("SrcK8S_Name", `"api",web`), NewNotMatch("DstK8S_Name", "db")}},
+			expected: `(SrcK8S_Name = "api" OR SrcK8S_Name CONTAINS "web") AND DstK8S_Name NOT CONTAINS "db"`,
+		},
+		{
+			name:     "negations",
+			filters:  MultiQueries{{NewNotMatch("SrcPort", "80,443")}, {NewNotMatch("SrcK8S_Name", `"a",b`)}},
+			expected: `SrcPort NOT IN (80, 443) OR NOT (SrcK8S_Name = "a" OR SrcK8S_Name CONTAINS "b")`,
+		},
+		{
+			name:     "regexes",
+			filters:  MultiQueries{{NewRegexMatch("SrcK8S_Name", `api-\\d+`), NewNotRegexMatch("DstK8S_Name", "a,b")}},
+			expected: `SrcK8S_Name =~ "api-\\d+" AND NOT (DstK8S_Name =~ "a" OR DstK8S_Name =~ "b")`,
+		},
+		{
+			name:     "comparisons",
+			filters:  MultiQueries{{NewMoreThanOrEqualMatch("Bytes", "100"), NewLessThanOrEqualMatch("Bytes", "200")}, {NewMatch("Proto", "6")}},
+			expected: `(Bytes >= 100 AND Bytes <= 200) OR Proto = 6`,
+		},
+		{
+			name:     "escaped values",
+			filters:  MultiQueries{{NewMatch("SrcK8S_Name", `"`+EscapeValue(`a,"b"`)+`"`)}},
+			expected: `SrcK8S_Name = "a,\"b\""`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			formatted := FormatExpression(tc.filters)
+			assert.Equal(t, tc.expected, formatted)
+		})
+	}
+}
+
+func TestFormatExpression_RoundTrip(t *testing.T) {
+	for _, expr := range []string{
+		`SrcK8S_Namespace = "ns" AND SrcPort IN (80, 443)`,
+		`SrcK8S_Name NOT IN ("a,b", "c") OR Bytes >= 100`,
+		`SrcK8S_Name CONTAINS "api*" AND Sampled = true`,
+		`SrcK8S_Name =~ "api-[0-9]{1,3}" OR DstK8S_Name !~ "(?i)DB"`,
+		`(Bytes >= 100 AND Bytes <= 200) OR DstK8S_Name = ""`,
+	} {
+		groups, err := ParseExpression(expr)
+		require.NoError(t, err, expr)
+		formatted := FormatExpression(groups)
+		assert.Equal(t, expr, formatted)
+		reparsed, err := ParseExpression(formatted)
+		require.NoError(t, err, formatted)
+		assert.Equal(t, groups, reparsed)
+	}
+}
@@ -0,0 +1,230 @@
+package filters
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// anyOctet matches any IPv4 octet. Label values are always valid IPs, so the number of digits is enough
+	anyOctet = "[0-9]{1,3}"
+	// anyHextet matches any IPv6 hextet, written in lowercase without leading zeros
+	anyHextet   = "[0-9a-f]{1,4}"
+	ipv6Hextets = 8
+)
+
+// IPRegex converts a single IP or a CIDR into an anchored regular expression matching exactly the IPs
+// it covers, as written in labels. This allows IP filters in queries that don't support the LogQL ip() function,
+// such as PromQL. IPv6 addresses are expected in their canonical form (RFC 5952), as written by the agents.
+// The second value is false when the value is neither an IP nor a CIDR.
+func IPRegex(value string) (string, bool) {
+	value = trimExactMatch(value)
+	if ip := net.ParseIP(value); ip != nil {
+		return "^" + regexp.QuoteMeta(ip.String()) + "$", true
+	}
+	ip, ipNet, err := net.ParseCIDR(value)
+	if err != nil {
+		return "", false
+	}
+	ones, bits := ipNet.Mask.Size()
+	if ones == bits {
+		return "^" + regexp.QuoteMeta(ip.String()) + "$", true
+	}
+	if bits == 8*net.IPv4len {
+		return "^" + ipv4Regex(ipNet.IP.To4(), ones) + "$", true
+	}
+	return "^(?:" + ipv6Regex(ipNet.IP, ones) + ")$", true
+}
+
+func ipv4Regex(ip4 net.IP, ones int) string {
+	octets := make([]string, 0, len(ip4))
+	for i, octet := range ip4 {
+		prefixBits := min(max(ones-i*8, 0), 8)
+		switch prefixBits {
+		case 8:
+			octets = append(octets, strconv.Itoa(int(octet)))
+		case 0:
+			octets = append(octets, anyOctet)
+		default:
+			hostRange := 1<<(8-prefixBits) - 1
+			octets = append(octets, rangeRegex(int(octet), int(octet)+hostRange))
+		}
+	}
+	return strings.Join(octets, `\.`)
+}
+
+// ipv6Regex returns the alternatives matching the IPs of an IPv6 prefix in their canonical form, where the hextets
+// have no leading zeros and a run of zero hextets is replaced by "::". The first alternative writes the hextets of
+// the prefix in full, any run being after them. The others replace a run starting within the prefix, which is only
+// possible from its zero hextets
+func ipv6Regex(ip net.IP, ones int) string {
+	var prefix []string
+	var zeros []bool
+	for i := 0; i*16 < ones; i++ {
+		hextet := int(ip[2*i])<<8 | int(ip[2*i+1])
+		prefixBits := min(ones-i*16, 16)
+		if prefixBits == 16 {
+			prefix = append(prefix, strconv.FormatInt(int64(hextet), 16))
+		} else {
+			hostRange := 1<<(16-prefixBits) - 1
+			prefix = append(prefix, baseRangeRegex(hextet, hextet+hostRange, 16))
+		}
+		zeros = append(zeros, hextet == 0)
+	}
+	if len(prefix) == 0 {
+		return ".*:.*"
+	}
+	alternatives := []string{strings.Join(prefix, ":") + ":[0-9a-f:]*"}
+	for start := range prefix {
+		for end := start + 1; end <= ipv6Hextets && (end > len(prefix) || zeros[end-1]); end++ {
+			if end-start < 2 {
+				// a single zero hextet is not replaced
+				continue
+			}
+			after := make([]string, 0, ipv6Hextets-end)
+			for i := end; i < ipv6Hextets; i++ {
+				if i < len(prefix) {
+					after = append(after, prefix[i])
+				} else {
+					after = append(after, anyHextet)
+				}
+			}
+			alternatives = append(alternatives, strings.Join(prefix[:start], ":")+"::"+strings.Join(after, ":"))
+		}
+	}
+	return strings.Join(alternatives, "|")
+}
+
+// ToIPLabelFilter converts an IP match (single IPs or CIDRs) into a regex label filter, see IPRegex.
+// The second value is false if any of the values cannot be converted.
+func (m *Match) ToIPLabelFilter() (LabelFilter, bool) {
+	values := m.SplitValues()
+	regexes := make([]string, 0, len(values))
+	for _, value := range values {
+		if value == `""` {
+			regexes = append(regexes, "^$")
+			continue
+		}
+		regex, ok := IPRegex(value)
+		if !ok {
+			return LabelFilter{}, false
+		}
+		regexes = append(regexes, regex)
+	}
+	if m.Not {
+		return StringNotMatchLabelFilter(m.Key, strings.Join(regexes, "|")), true
+	}
+	return StringMatchLabelFilter(m.Key, strings.Join(regexes, "|")), true
+}
+
+// rangeRegex returns a regular expression matching the decimal numbers between lower and upper (inclusive), without leading zeros
+func rangeRegex(lower, upper int) string {
+	return baseRangeRegex(lower, upper, 10)
+}
+
+// baseRangeRegex returns a regular expression matching the numbers between lower and upper (inclusive), written in
+// the given base (up to 16, in lowercase) without leading zeros
+func baseRangeRegex(lower, upper, base int) string {
+	var parts []string
+	// split in ranges having the same number of digits
+	for digits, start := 1, lower; start <= upper; digits++ {
+		maxForDigits := pow(base, digits) - 1
+		if start > maxForDigits {
+			continue
+		}
+		end := min(upper, maxForDigits)
+		parts = append(parts, sameLengthRangeRegex(formatInt(start, base), formatInt(end, base), base)...)
+		start = end + 1
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return "(?:" + strings.Join(parts, "|") + ")"
+}
+
+// sameLengthRangeRegex returns the alternatives matching numbers between lower and upper, written with the same number of digits
+func sameLengthRangeRegex(lower, upper string, base int) []string {
+	if len(lower) == 1 {
+		return []string{digitClass(digitValue(lower[0]), digitValue(upper[0]))}
+	}
+	rest := len(lower) - 1
+	if lower[0] == upper[0] {
+		var parts []string
+		for _, sub := range sameLengthRangeRegex(lower[1:], upper[1:], base) {
+			parts = append(parts, lower[:1]+sub)
+		}
+		return parts
+	}
+	allZeros, allMax := strings.Repeat("0", rest), strings.Repeat(formatInt(base-1, base), rest)
+	firstFull, lastFull := digitValue(lower[0]), digitValue(upper[0])
+	if lower[1:] == allZeros && upper[1:] == allMax {
+		return []string{digitClass(firstFull, lastFull) + anyDigits(rest, base)}
+	}
+	var parts []string
+	if lower[1:] != allZeros {
+		for _, sub := range sameLengthRangeRegex(lower[1:], allMax, base) {
+			parts = append(parts, lower[:1]+sub)
+		}
+		firstFull++
+	}
+	var lastParts []string
+	if upper[1:] != allMax {
+		for _, sub := range sameLengthRangeRegex(allZeros, upper[1:], base) {
+			lastParts = append(lastParts, upper[:1]+sub)
+		}
+		lastFull--
+	}
+	if firstFull <= lastFull {
+		parts = append(parts, digitClass(firstFull, lastFull)+anyDigits(rest, base))
+	}
+	return append(parts, lastParts...)
+}
+
+// digitClass returns the character class of the digits between lower and upper, decimal digits and letters being
+// written as separate ranges
+func digitClass(lower, upper int) string {
+	if lower == upper {
+		return formatInt(lower, 16)
+	}
+	if lower > 9 || upper < 10 {
+		return "[" + formatInt(lower, 16) + "-" + formatInt(upper, 16) + "]"
+	}
+	return "[" + digitRange(lower, 9) + digitRange(10, upper) + "]"
+}
+
+func digitRange(lower, upper int) string {
+	if lower == upper {
+		return formatInt(lower, 16)
+	}
+	return formatInt(lower, 16) + "-" + formatInt(upper, 16)
+}
+
+func anyDigits(count, base int) string {
+	class := "[0-9]"
+	if base > 10 {
+		class = "[0-9" + digitRange(10, base-1) + "]"
+	}
+	if count == 1 {
+		return class
+	}
+	return class + "{" + strconv.Itoa(count) + "}"
+}
+
+func digitValue(digit byte) int {
+	value, _ := strconv.ParseInt(string(digit), 16, 0)
+	return int(value)
+}
+
+func formatInt(n, base int) string {
+	return strconv.FormatInt(int64(n), base)
+}
+
+func pow(base, n int) int {
+	p := 1
+	for i := 0; i < n; i++ {
+		p *= base
+	}
+	return p
+}
@@ -0,0 +1,166 @@
+package filters
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeRegex(t *testing.T) {
+	for _, r := range [][2]int{{0, 255}, {128, 131}, {8, 15}, {96, 127}, {0, 63}, {192, 223}, {5, 5}} {
+		regex := regexp.MustCompile("^" + rangeRegex(r[0], r[1]) + "$")
+		for i := 0; i < 1000; i++ {
+			assert.Equal(t, i >= r[0] && i <= r[1], regex.MatchString(strconv.Itoa(i)), "range %v, value %d, regex %s", r, i, regex)
+		}
+	}
+}
+
+func TestIPRegex(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		regex    string
+		matching []string
+		other    []string
+	}{
+		{
+			value:    "10.0.0.1",
+			regex:    `^10\.0\.0\.1$`,
+			matching: []string{"10.0.0.1"},
+			other:    []string{"10.0.0.10", "110.0.0.1"},
+		},
+		{
+			value:    `"10.128.0.0/14"`,
+			regex:    `^10\.(?:12[8-9]|13[0-1])\.[0-9]{1,3}\.[0-9]{1,3}$`,
+			matching: []string{"10.128.0.1", "10.131.255.255"},
+			other:    []string{"10.127.255.255", "10.132.0.0", "110.128.0.1"},
+		},
+		{
+			value:    "192.168.1.0/24",
+			regex:    `^192\.168\.1\.[0-9]{1,3}$`,
+			matching: []string{"192.168.1.0", "192.168.1.255"},
+			other:    []string{"192.168.10.1"},
+		},
+		{
+			value:    "172.16.0.0/12",
+			matching: []string{"172.16.0.1", "172.31.255.255"},
+			other:    []string{"172.15.0.1", "172.32.0.1"},
+		},
+		{
+			value:    "0.0.0.0/0",
+			matching: []string{"1.2.3.4"},
+		},
+		{
+			value:    "fe80::1",
+			regex:    `^fe80::1$`,
+			matching: []string{"fe80::1"},
+		},
+		{
+			value:    "2001:db8::1/128",
+			matching: []string{"2001:db8::1"},
+		},
+		{
+			value:    "2001:db8::/32",
+			regex:    `^(?:2001:db8:[0-9a-f:]*)$`,
+			matching: []string{"2001:db8::", "2001:db8::1", "2001:db8:1:2:3:4:5:6", "2001:db8:0:1::"},
+			other:    []string{"2001:db80::1", "2001:db9::1", "::2001:db8:0:1", "fe80::2001:db8"},
+		},
+		{
+			value:    "fd00::/8",
+			matching: []string{"fd00::1", "fdff:1::"},
+			other:    []string{"fc00::1", "fe00::1", "fd::1", "::fd00"},
+		},
+		{
+			value:    "2001:db8:0:100::/56",
+			matching: []string{"2001:db8:0:100::1", "2001:db8:0:1ff:1:2:3:4", "2001:db8:0:180::"},
+			other:    []string{"2001:db8::1", "2001:db8:0:200::1", "2001:db8:0:ff::1", "2001:db8:1:100::1"},
+		},
+		{
+			value:    "::/0",
+			matching: []string{"::1", "fe80::1"},
+		},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			regex, ok := IPRegex(tc.value)
+			require.True(t, ok)
+			if tc.regex != "" {
+				assert.Equal(t, tc.regex, regex)
+			}
+			compiled := regexp.MustCompile(regex)
+			for _, ip := range tc.matching {
+				assert.True(t, compiled.MatchString(ip), ip)
+			}
+			for _, ip := range tc.other {
+				assert.False(t, compiled.MatchString(ip), ip)
+			}
+		})
+	}
+
+	for _, value := range []string{"10.0.0.1-10.0.0.5", "abc"} {
+		_, ok := IPRegex(value)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestToIPLabelFilter(t *testing.T) {
+	m := NewNotMatch("SrcAddr", `10.0.0.0/8,"",fe80::1`)
+	lf, ok := m.ToIPLabelFilter()
+	require.True(t, ok)
+	assert.Equal(t, fmt.Sprintf("SrcAddr!~%q", `^10\.[0-9]{1,3}\.[0-9]{1,3}\.[0-9]{1,3}$|^$|^fe80::1$`), writeLabelFilter(&lf))
+
+	m = NewMatch("SrcAddr", "2001:db8::/32,10.0.0.1-10.0.0.5")
+	_, ok = m.ToIPLabelFilter()
+	assert.False(t, ok)
+}
+
+func TestIPRegex_IPv6Prefixes(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, cidr := range []string{"2001:db8::/32", "fd00::/8", "2001:db8:0:100::/56", "::/64", "fe80::/10", "2001:0:0:0:1::/80", "0:0:0:ff00::/56", "::/127"} {
+		regex, ok := IPRegex(cidr)
+		require.True(t, ok, cidr)
+		compiled := regexp.MustCompile(regex)
+		_, ipNet, err := net.ParseCIDR(cidr)
+		require.NoError(t, err)
+		for i := 0; i < 5000; i++ {
+			ip := make(net.IP, net.IPv6len)
+			for j := range ip {
+				// zero bytes make runs of zero hextets likely
+				if rnd.Intn(3) > 0 {
+					ip[j] = byte(rnd.Intn(256))
+				}
+			}
+			if i%2 == 0 {
+				// half of the IPs are in the prefix
+				for j := range ip {
+					ip[j] = ipNet.IP[j] | (ip[j] &^ ipNet.Mask[j])
+				}
+			}
+			if ip.To4() != nil {
+				// IPv4-mapped addresses are written as IPv4
+				continue
+			}
+			assert.Equal(t, ipNet.Contains(ip), compiled.MatchString(ip.String()), "cidr %s, ip %s, regex %s", cidr, ip, regex)
+		}
+	}
+}
+
+func TestBaseRangeRegex(t *testing.T) {
+	for _, r := range [][2]int{{0, 0xffff}, {0xfd00, 0xfdff}, {0xfe80, 0xfebf}, {0x100, 0x1ff}, {0x8, 0x17}, {0x9a, 0xa9}} {
+		regex := regexp.MustCompile("^" + baseRangeRegex(r[0], r[1], 16) + "$")
+		for i := 0; i <= 0xffff; i++ {
+			assert.Equal(t, i >= r[0] && i <= r[1], regex.MatchString(strconv.FormatInt(int64(i), 16)), "range %v, value %x, regex %s", r, i, regex)
+		}
+	}
+}
+
+func writeLabelFilter(lf *LabelFilter) string {
+	sb := strings.Builder{}
+	lf.WriteInto(&sb)
+	return sb.String()
+}
@@ -0,0 +1,237 @@
+package filters
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenBool
+	tokenOperator
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenIn
+	tokenBetween
+	tokenContains
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "end of expression"
+	case tokenIdent:
+		return "field name"
+	case tokenString:
+		return "string"
+	case tokenNumber:
+		return "number"
+	case tokenBool:
+		return "boolean"
+	case tokenOperator:
+		return "operator"
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	case tokenIn:
+		return "IN"
+	case tokenBetween:
+		return "BETWEEN"
+	case tokenContains:
+		return "CONTAINS"
+	case tokenLParen:
+		return "'('"
+	case tokenRParen:
+		return "')'"
+	case tokenComma:
+		return "','"
+	}
+	return "unknown token"
+}
+
+// Operator is a comparison operator of the filter expression language
+type Operator string
+
+const (
+	OpEqual          = Operator("=")
+	OpNotEqual       = Operator("!=")
+	OpLess           = Operator("<")
+	OpLessOrEqual    = Operator("<=")
+	OpGreater        = Operator(">")
+	OpGreaterOrEqual = Operator(">=")
+	OpMatch          = Operator("=~")
+	OpNotMatch       = Operator("!~")
+	OpIn             = Operator("in")
+	OpNotIn          = Operator("not in")
+	OpBetween        = Operator("between")
+	OpNotBetween     = Operator("not between")
+	OpContains       = Operator("contains")
+	OpNotContains    = Operator("not contains")
+)
+
+// operators are ordered so that two-characters operators are matched first
+var operators = []Operator{OpMatch, OpNotMatch, OpNotEqual, OpLessOrEqual, OpGreaterOrEqual, OpEqual, OpLess, OpGreater}
+
+var keywords = map[string]tokenKind{
+	"and":      tokenAnd,
+	"or":       tokenOr,
+	"not":      tokenNot,
+	"in":       tokenIn,
+	"between":  tokenBetween,
+	"contains": tokenContains,
+	"true":     tokenBool,
+	"false":    tokenBool,
+}
+
+type token struct {
+	kind tokenKind
+	// text is the token as written in the expression, except for strings where it's the unquoted value
+	text string
+	pos  int
+}
+
+// ParseError is returned when a filter expression cannot be parsed. Pos is the 0-based byte offset
+// of the error in the expression
+type ParseError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid filter expression at position %d: %s", e.Pos, e.Msg)
+}
+
+func errorAt(pos int, format string, args ...any) *ParseError {
+	return &ParseError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// tokenize splits a filter expression into tokens, ending with tokenEOF
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	pos := 0
+	for pos < len(expr) {
+		c := expr[pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: pos})
+			pos++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: pos})
+			pos++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: pos})
+			pos++
+		case c == '"' || c == '`':
+			// backtick-quoted strings are raw strings, convenient for regular expressions
+			quoted, err := strconv.QuotedPrefix(expr[pos:])
+			if err != nil {
+				return nil, errorAt(pos, "unterminated or invalid string")
+			}
+			value, err := strconv.Unquote(quoted)
+			if err != nil {
+				return nil, errorAt(pos, "invalid string: %s", err.Error())
+			}
+			tokens = append(tokens, token{kind: tokenString, text: value, pos: pos})
+			pos += len(quoted)
+		case c == '-' || isDigit(c):
+			end := scanNumber(expr, pos)
+			if end < 0 {
+				return nil, errorAt(pos, "invalid number")
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: expr[pos:end], pos: pos})
+			pos = end
+		case isIdentStart(c):
+			end := pos + 1
+			for end < len(expr) && isIdentPart(expr[end]) {
+				end++
+			}
+			word := expr[pos:end]
+			kind := tokenIdent
+			if kw, ok := keywords[strings.ToLower(word)]; ok {
+				kind = kw
+			}
+			tokens = append(tokens, token{kind: kind, text: word, pos: pos})
+			pos = end
+		default:
+			op, ok := scanOperator(expr[pos:])
+			if !ok {
+				r := []rune(expr[pos:])[0]
+				if unicode.IsPrint(r) {
+					return nil, errorAt(pos, "unexpected character '%c'", r)
+				}
+				return nil, errorAt(pos, "unexpected character %q", r)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: string(op), pos: pos})
+			pos += len(op)
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(expr)})
+	return tokens, nil
+}
+
+func scanOperator(s string) (Operator, bool) {
+	for _, op := range operators {
+		if strings.HasPrefix(s, string(op)) {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// scanNumber returns the end offset of a number starting at pos, or -1 if it's not a valid number
+func scanNumber(expr string, pos int) int {
+	end := pos
+	if expr[end] == '-' {
+		end++
+	}
+	digitsStart := end
+	for end < len(expr) && isDigit(expr[end]) {
+		end++
+	}
+	if end == digitsStart {
+		return -1
+	}
+	if end < len(expr) && expr[end] == '.' {
+		end++
+		decimalsStart := end
+		for end < len(expr) && isDigit(expr[end]) {
+			end++
+		}
+		if end == decimalsStart {
+			return -1
+		}
+	}
+	// a number must not be directly followed by a name, e.g. 80abc
+	if end < len(expr) && isIdentPart(expr[end]) {
+		return -1
+	}
+	return end
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
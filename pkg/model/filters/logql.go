@@ -2,14 +2,10 @@ package filters
 
 import (
 	"fmt"
-	"math"
 	"strconv"
 	"strings"
 )
 
-// remove quotes and replace * by regex any
-var valueReplacer = strings.NewReplacer(`*`, `.*`, `"`, "")
-
 type labelMatcher string
 
 const (
@@ -17,6 +13,7 @@ const (
 	labelMatches         = labelMatcher("=~")
 	labelNotEqual        = labelMatcher("!=")
 	labelMoreThanOrEqual = labelMatcher(">=")
+	labelLessThanOrEqual = labelMatcher("<=")
 	labelNoMatches       = labelMatcher("!~")
 )
 
@@ -35,20 +32,21 @@ const (
 // LabelFilter represents a condition based on a label name, value and matching operator. It
 // applies to LogQL stream selectors and attribute filtering
 type LabelFilter struct {
-	key       string
-	matcher   labelMatcher
-	value     string
-	valueType valueType
+	key           string
+	matcher       labelMatcher
+	value         string
+	valueType     valueType
+	caseSensitive bool
 }
 
 // LineFilter represents a condition based on a JSON raw text match.
 type LineFilter struct {
-	key        string
-	strictKey  bool
-	values     []lineMatch
-	not        bool
-	allowEmpty bool
-	moreThan   bool
+	key           string
+	strictKey     bool
+	values        []lineMatch
+	not           bool
+	allowEmpty    bool
+	caseSensitive bool
 }
 
 type lineMatch struct {
@@ -96,6 +94,15 @@ func MoreThanNumberLabelFilter(labelKey string, value string) LabelFilter {
 	}
 }
 
+func LessThanNumberLabelFilter(labelKey string, value string) LabelFilter {
+	return LabelFilter{
+		key:       labelKey,
+		matcher:   labelLessThanOrEqual,
+		value:     value,
+		valueType: typeNumber,
+	}
+}
+
 func StringNotMatchLabelFilter(labelKey string, value string) LabelFilter {
 	return LabelFilter{
 		key:       labelKey,
@@ -123,21 +130,48 @@ func NotIPLabelFilter(labelKey, cidr string) LabelFilter {
 	}
 }
 
-func MultiValuesRegexFilter(labelKey string, values []string, not bool) (LabelFilter, bool) {
+// RegexLabelFilter matches any of the regular expressions, which must match whole values as label matchers are anchored
+func RegexLabelFilter(labelKey string, regexes []string, not, caseSensitive bool) LabelFilter {
+	regexStr := strings.Builder{}
+	if !caseSensitive {
+		regexStr.WriteString("(?i)")
+	}
+	if len(regexes) == 1 {
+		regexStr.WriteString(regexes[0])
+	} else {
+		for i, regex := range regexes {
+			if i > 0 {
+				regexStr.WriteByte('|')
+			}
+			regexStr.WriteString("(?:")
+			regexStr.WriteString(regex)
+			regexStr.WriteByte(')')
+		}
+	}
+	if not {
+		return StringNotMatchLabelFilter(labelKey, regexStr.String())
+	}
+	return StringMatchLabelFilter(labelKey, regexStr.String())
+}
+
+func MultiValuesRegexFilter(labelKey string, values []string, not, caseSensitive bool) (LabelFilter, bool) {
 	regexStr := strings.Builder{}
 	for i, value := range values {
 		if i > 0 {
 			regexStr.WriteByte('|')
 		}
 		// match the begining of string if quoted without a star
-		// and case insensitive if no quotes
+		// and case insensitive if no quotes, unless requested otherwise
 		if !strings.HasPrefix(value, `"`) {
-			regexStr.WriteString("(?i).*")
+			if !caseSensitive {
+				regexStr.WriteString("(?i)")
+			}
+			regexStr.WriteString(".*")
 		} else if !strings.HasPrefix(value, `"*`) {
 			regexStr.WriteString("^")
 		}
-		// inject value with regex
-		regexStr.WriteString(valueReplacer.Replace(value))
+		// inject escaped value with regex
+		regexStr.WriteString(regexValue(trimExactMatch(value)))
 		// match the end of string if quoted without a star
 		if !strings.HasSuffix(value, `"`) {
 			regexStr.WriteString(".*")
@@ -162,37 +196,47 @@ func (f *LabelFilter) WriteInto(sb *strings.Builder) {
 	case typeNumber, typeBool, typeRegex:
 		sb.WriteString(f.value)
 	case typeString:
-		sb.WriteByte('"')
-		sb.WriteString(f.value)
-		sb.WriteByte('"')
+		// LogQL and PromQL strings follow Go escaping rules
+		sb.WriteString(strconv.Quote(f.value))
 	case typeIP:
-		sb.WriteString(`ip("`)
-		sb.WriteString(f.value)
-		sb.WriteString(`")`)
+		sb.WriteString(`ip(`)
+		sb.WriteString(strconv.Quote(f.value))
+		sb.WriteString(`)`)
 	case typeRegexContains:
-		// match any case
-		sb.WriteString("`(?i).*")
-		sb.WriteString(f.value)
+		// match any case, unless requested otherwise
+		sb.WriteByte('`')
+		writeCaseInsensitive(sb, f.caseSensitive)
+		sb.WriteString(".*")
+		sb.WriteString(backtickRegexValue(f.value))
 		sb.WriteString(".*`")
 	case typeRegexArrayContains:
 		// match any case and ensure we stay inside the array
-		sb.WriteString("`(?i)[^]]*")
-		sb.WriteString(f.value)
+		sb.WriteByte('`')
+		writeCaseInsensitive(sb, f.caseSensitive)
+		sb.WriteString("[^]]*")
+		sb.WriteString(backtickRegexValue(f.value))
 		sb.WriteString("[^]]*`")
 	default:
 		panic(fmt.Sprint("wrong filter value type", int(f.valueType)))
 	}
 }
 
+func writeCaseInsensitive(sb *strings.Builder, caseSensitive bool) {
+	if !caseSensitive {
+		sb.WriteString("(?i)")
+	}
+}
+
 // AsLabelFilters transforms a LineFilter (raw text match) into a group of
 // labelFilters (attributes match)
 func (f *LineFilter) AsLabelFilters() []LabelFilter {
 	lfs := make([]LabelFilter, 0, len(f.values))
 	for _, v := range f.values {
 		lf := LabelFilter{
-			key:       f.key,
-			valueType: v.valueType,
-			value:     v.value,
+			key:           f.key,
+			valueType:     v.valueType,
+			value:         v.value,
+			caseSensitive: f.caseSensitive,
 		}
 		if isRegex(v.valueType) {
 			lf.matcher = labelMatches
@@ -203,8 +247,6 @@ func (f *LineFilter) AsLabelFilters() []LabelFilter {
 			lf.matcher = labelEqual
 			if f.not {
 				lf.matcher = labelNotEqual
-			} else if f.moreThan {
-				lf.matcher = labelMoreThanOrEqual
 			}
 		}
 		lfs = append(lfs, lf)
@@ -212,11 +254,10 @@ func (f *LineFilter) AsLabelFilters() []LabelFilter {
 	return lfs
 }
 
-func NewEmptyLineFilter(key string, not, moreThan, allowEmpty bool) LineFilter {
+func NewEmptyLineFilter(key string, not, allowEmpty bool) LineFilter {
 	return LineFilter{
 		key:        key,
 		not:        not,
-		moreThan:   moreThan,
 		allowEmpty: allowEmpty,
 	}
 }
@@ -237,31 +278,31 @@ func NotContainsKeyLineFilter(key string) LineFilter {
 		key:       key,
 		strictKey: true,
 		not:       true,
-		moreThan:  false,
 	}
 }
 
-func NumericLineFilter(key string, values []string, not, moreThan bool) (LineFilter, bool) {
-	return checkExact(
-		LineFilter{
-			key:      key,
-			not:      not,
-			moreThan: moreThan,
-		},
-		values,
-		typeNumber)
+func NumericLineFilter(key string, values []string, not bool) (LineFilter, bool) {
+	return checkExact(LineFilter{key: key, not: not}, values, typeNumber)
 }
 
-func ArrayLineFilter(key string, values []string, not bool) LineFilter {
-	lf := LineFilter{key: key, not: not}
+func BoolLineFilter(key string, values []string, not bool) LineFilter {
+	lf := LineFilter{key: key, strictKey: true, not: not}
+	for _, value := range values {
+		lf.values = append(lf.values, lineMatch{valueType: typeBool, value: trimExactMatch(value)})
+	}
+	return lf
+}
+
+func ArrayLineFilter(key string, values []string, not, caseSensitive bool) LineFilter {
+	lf := LineFilter{key: key, not: not, caseSensitive: caseSensitive}
 	for _, value := range values {
 		lf.values = append(lf.values, lineMatch{valueType: typeRegexArrayContains, value: value})
 	}
 	return lf
 }
 
-func StringLineFilterCheckExact(key string, values []string, not bool) (LineFilter, bool) {
-	return checkExact(LineFilter{key: key, not: not}, values, typeRegexContains)
+func StringLineFilterCheckExact(key string, values []string, not, caseSensitive bool) (LineFilter, bool) {
+	return checkExact(LineFilter{key: key, not: not, caseSensitive: caseSensitive}, values, typeRegexContains)
 }
 
 func checkExact(lf LineFilter, values []string, defaultMatchType valueType) (LineFilter, bool) {
@@ -290,65 +331,6 @@ func (f LineFilter) MatchFalse() LineFilter {
 	return f
 }
 
-func moreThanRegex(sb *strings.Builder, value string) {
-	// match each number greater than specified value using regex
-	// example for 123:
-	// ( 12[3-9] | 1[3-9][0-9]+ | [2-9][0-9]+ | [1-9][0-9]{3,} )
-	//       |            |              |             |
-	//       |            |              |              ↪ match number more than 1000
-	//       |            |              |
-	//       |            |               ↪ match number from 200 to 999
-	//       |            |
-	//       |             ↪ match numbers from 130 to 199
-	//       |
-	//        ↪ match any number from 123 to 129
-
-	sb.WriteString("(")
-	for i, r := range value {
-		if i < len(value)-1 {
-			sb.WriteRune(r)
-		} else {
-			sb.WriteRune('[')
-			sb.WriteRune(r)
-			sb.WriteString("-9]")
-		}
-	}
-
-	intVal, _ := strconv.Atoi(value)
-	for i := 1; i < len(value); i++ {
-		nextMin := int((intVal / int(math.Pow10(i))) + 1)
-		nextMinStr := strconv.Itoa(nextMin)
-
-		sb.WriteRune('|')
-		if nextMin >= 10 {
-			sb.WriteString(nextMinStr[0 : len(nextMinStr)-1])
-		} else if i < len(value)-1 {
-			sb.WriteString(value[0 : len(value)-1-i])
-		}
-
-		nextMinRune := nextMinStr[len(nextMinStr)-1:]
-		if nextMin%9 != 0 {
-			sb.WriteRune('[')
-			sb.WriteString(nextMinRune)
-			sb.WriteString("-9]")
-		} else {
-			sb.WriteString(nextMinRune)
-		}
-
-		sb.WriteString("[0-9]")
-
-		if i > 1 {
-			sb.WriteString("{")
-			sb.WriteString(fmt.Sprintf("%d", i))
-			sb.WriteString(",}")
-		}
-	}
-
-	sb.WriteString("|[1-9][0-9]{")
-	sb.WriteString(fmt.Sprintf("%d", len(value)))
-	sb.WriteString(",})")
-}
-
 // WriteInto transforms a LineFilter to its corresponding part of a LogQL query
 // under construction (contained in the provided strings.Builder)
 func (f *LineFilter) WriteInto(sb *strings.Builder) {
@@ -398,11 +380,7 @@ func (f *LineFilter) WriteInto(sb *strings.Builder) {
 			sb.WriteString(`":`)
 			switch v.valueType {
 			case typeNumber, typeRegex:
-				if f.moreThan {
-					moreThanRegex(sb, v.value)
-				} else {
-					sb.WriteString(v.value)
-				}
+				sb.WriteString(v.value)
 				// a number or regex can be followed by } if it's the last property of a JSON document
 				sb.WriteString("[,}]")
 			case typeBool:
@@ -410,17 +388,21 @@ func (f *LineFilter) WriteInto(sb *strings.Builder) {
 			case typeString, typeIP:
 				// exact matches are specified as just strings
 				sb.WriteByte('"')
-				sb.WriteString(valueReplacer.Replace(v.value))
+				sb.WriteString(jsonRegexValue(v.value))
 				sb.WriteByte('"')
 			// contains-match are specified as regular expressions
 			case typeRegexContains:
-				sb.WriteString(`"(?i)[^"]*`)
-				sb.WriteString(valueReplacer.Replace(v.value))
+				sb.WriteByte('"')
+				writeCaseInsensitive(sb, f.caseSensitive)
+				sb.WriteString(`[^"]*`)
+				sb.WriteString(jsonRegexValue(v.value))
 				sb.WriteString(`.*"`)
 			// for array, we ensure it starts by [ and ends by ]
 			case typeRegexArrayContains:
-				sb.WriteString(`\[(?i)[^]]*`)
-				sb.WriteString(valueReplacer.Replace(v.value))
+				sb.WriteString(`\[`)
+				writeCaseInsensitive(sb, f.caseSensitive)
+				sb.WriteString(`[^]]*`)
+				sb.WriteString(jsonRegexValue(v.value))
 				sb.WriteString(`[^]]*]`)
 			}
 		}
@@ -1,76 +1,88 @@
 package filters
 
 import (
-	"fmt"
+	"encoding/json"
 	"regexp"
 	"strconv"
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestWriteInto_1(t *testing.T) {
-	sb := strings.Builder{}
-	moreThanRegex(&sb, "1")
-	result := sb.String()
-	assert.Equal(t, result, "([1-9]|[1-9][0-9]{1,})")
-	reg := regexp.MustCompile(result)
-	fmt.Printf("\nRegex: %s", result)
-	for _, i := range []int{0, 1, 2, 3, 10, 100} {
-		val := strconv.Itoa(i)
-		assert.Equal(t, reg.MatchString(val), i >= 1, fmt.Sprintf("Value: %d", i))
+func FuzzStringLabelFilter_WriteInto(f *testing.F) {
+	for _, seed := range []string{"bar", `my"pod`, `a\b`, "back`quote", "ns 1/pod+1", "ünïcode", `"} or vector(1)`} {
+		f.Add(seed)
 	}
-}
+	f.Fuzz(func(t *testing.T, value string) {
+		lf := StringEqualLabelFilter("key", value)
+		sb := strings.Builder{}
+		lf.WriteInto(&sb)
+		result := sb.String()
 
-func TestWriteInto_7(t *testing.T) {
-	sb := strings.Builder{}
-	moreThanRegex(&sb, "7")
-	result := sb.String()
-	assert.Equal(t, result, "([7-9]|[1-9][0-9]{1,})")
-	reg := regexp.MustCompile(result)
-	fmt.Printf("\nRegex: %s", result)
-	for _, i := range []int{0, 1, 3, 7, 10, 11, 100} {
-		val := strconv.Itoa(i)
-		assert.Equal(t, reg.MatchString(val), i >= 7, fmt.Sprintf("Value: %d", i))
-	}
+		// the value must remain a single string literal, that unquotes back to the original value
+		assert.True(t, strings.HasPrefix(result, "key="), result)
+		unquoted, err := strconv.Unquote(strings.TrimPrefix(result, "key="))
+		assert.NoError(t, err, result)
+		assert.Equal(t, value, unquoted)
+	})
 }
 
-func TestWriteInto_15(t *testing.T) {
-	sb := strings.Builder{}
-	moreThanRegex(&sb, "15")
-	result := sb.String()
-	assert.Equal(t, result, "(1[5-9]|[2-9][0-9]|[1-9][0-9]{2,})")
-	reg := regexp.MustCompile(result)
-	fmt.Printf("\nRegex: %s", result)
-	for _, i := range []int{0, 1, 5, 14, 15, 16, 150, 1050} {
-		val := strconv.Itoa(i)
-		assert.Equal(t, reg.MatchString(val), i >= 15, fmt.Sprintf("Value: %d", i))
+func FuzzMultiValuesRegexFilter_WriteInto(f *testing.F) {
+	for _, seed := range []string{"bar", `"bar"`, `"bar*"`, "a.b+c", `(?i)`, "back`quote", `"} or vector(1)`, `\`} {
+		f.Add(seed)
 	}
-}
+	f.Fuzz(func(t *testing.T, value string) {
+		if !utf8.ValidString(value) {
+			t.Skip()
+		}
+		lf, ok := MultiValuesRegexFilter("key", []string{value}, false, false)
+		if !ok {
+			return
+		}
+		sb := strings.Builder{}
+		lf.WriteInto(&sb)
+		result := sb.String()
 
-func TestWriteInto_123(t *testing.T) {
-	sb := strings.Builder{}
-	moreThanRegex(&sb, "123")
-	result := sb.String()
-	assert.Equal(t, result, "(12[3-9]|1[3-9][0-9]|[2-9][0-9]{2,}|[1-9][0-9]{3,})")
-	reg := regexp.MustCompile(result)
-	fmt.Printf("\nRegex: %s", result)
-	for _, i := range []int{0, 1, 10, 100, 115, 123, 124, 150, 200, 1230} {
-		val := strconv.Itoa(i)
-		assert.Equal(t, reg.MatchString(val), i >= 123, fmt.Sprintf("Value: %d", i))
-	}
+		// the regex must remain a single string literal, and a valid regex
+		assert.True(t, strings.HasPrefix(result, "key=~"), result)
+		regex, err := strconv.Unquote(strings.TrimPrefix(result, "key=~"))
+		require.NoError(t, err, result)
+		reg, err := regexp.Compile(regex)
+		require.NoError(t, err, regex)
+		// without special markers, the value is matched literally
+		if !strings.ContainsAny(value, `"*`) {
+			assert.True(t, reg.MatchString(value), regex)
+		}
+	})
 }
 
-func TestWriteInto_7654(t *testing.T) {
-	sb := strings.Builder{}
-	moreThanRegex(&sb, "7654")
-	result := sb.String()
-	assert.Equal(t, result, "(765[4-9]|76[6-9][0-9]|7[7-9][0-9]{2,}|[8-9][0-9]{3,}|[1-9][0-9]{4,})")
-	reg := regexp.MustCompile(result)
-	fmt.Printf("\nRegex: %s", result)
-	for _, i := range []int{0, 1, 1000, 7654, 7655, 10000} {
-		val := strconv.Itoa(i)
-		assert.Equal(t, reg.MatchString(val), i >= 7654, fmt.Sprintf("Value: %d", i))
+func FuzzStringLineFilter_WriteInto(f *testing.F) {
+	for _, seed := range []string{"bar", `"bar"`, `"bar*"`, "a.b+c", `my"pod`, "back`quote", "a`|json", `\`} {
+		f.Add(seed)
 	}
+	f.Fuzz(func(t *testing.T, value string) {
+		if !utf8.ValidString(value) || strings.Contains(value, ",") {
+			t.Skip()
+		}
+		lf, _ := StringLineFilterCheckExact("key", []string{value}, false, false)
+		sb := strings.Builder{}
+		lf.WriteInto(&sb)
+		result := sb.String()
+
+		// the regex must remain in a single backtick-quoted string, and be valid
+		require.True(t, strings.HasPrefix(result, "|~`") && strings.HasSuffix(result, "`"), result)
+		regex := strings.TrimSuffix(strings.TrimPrefix(result, "|~`"), "`")
+		assert.NotContains(t, regex, "`")
+		reg, err := regexp.Compile(regex)
+		require.NoError(t, err, regex)
+		// without special markers and characters escaped differently in JSON, the value is matched literally in the JSON line
+		if !strings.ContainsAny(value, "\"*<>&\u2028\u2029") && !strings.ContainsFunc(value, func(r rune) bool { return r < 0x20 }) {
+			encoded, err := json.Marshal(map[string]string{"key": value})
+			require.NoError(t, err)
+			assert.True(t, reg.Match(encoded), "%s should match %s", regex, encoded)
+		}
+	})
 }
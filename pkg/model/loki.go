@@ -26,12 +26,15 @@ type AggregatedQueryResponse struct {
 
 // AggregatedStats represents the stats to one or more logQL queries
 type AggregatedStats struct {
-	NumQueries   int                    `json:"numQueries"`
-	TotalEntries int                    `json:"totalEntries"`
-	Duplicates   int                    `json:"duplicates"`
-	LimitReached bool                   `json:"limitReached"`
-	QueriesStats []interface{}          `json:"queriesStats"`
-	DataSources  []constants.DataSource `json:"dataSources"`
+	NumQueries     int                      `json:"numQueries"`
+	TotalEntries   int                      `json:"totalEntries"`
+	Duplicates     int                      `json:"duplicates"`
+	LimitReached   bool                     `json:"limitReached"`
+	QueriesStats   []interface{}            `json:"queriesStats"`
+	DataSources    []constants.DataSource   `json:"dataSources"`
+	Step           string                   `json:"step,omitempty"`
+	RateInterval   string                   `json:"rateInterval,omitempty"`
+	ReportersMerge constants.ReportersMerge `json:"reportersMerge,omitempty"`
 }
 
 // ResultType holds the type of the result
@@ -0,0 +1,112 @@
+package naming
+
+// ianaServices are common service names from the IANA service name and port number registry
+// https://www.iana.org/assignments/service-names-port-numbers/service-names-port-numbers.xhtml
+var ianaServices = []struct {
+	name string
+	port int
+}{
+	{"ftp-data", 20},
+	{"ftp", 21},
+	{"ssh", 22},
+	{"telnet", 23},
+	{"smtp", 25},
+	{"domain", 53},
+	{"bootps", 67},
+	{"bootpc", 68},
+	{"tftp", 69},
+	{"http", 80},
+	{"kerberos", 88},
+	{"pop3", 110},
+	{"sunrpc", 111},
+	{"ntp", 123},
+	{"netbios-ns", 137},
+	{"netbios-dgm", 138},
+	{"netbios-ssn", 139},
+	{"imap", 143},
+	{"snmp", 161},
+	{"snmptrap", 162},
+	{"bgp", 179},
+	{"ldap", 389},
+	{"https", 443},
+	{"microsoft-ds", 445},
+	{"kpasswd", 464},
+	{"submissions", 465},
+	{"isakmp", 500},
+	{"syslog", 514},
+	{"dhcpv6-client", 546},
+	{"dhcpv6-server", 547},
+	{"rtsp", 554},
+	{"submission", 587},
+	{"ipp", 631},
+	{"ldaps", 636},
+	{"domain-s", 853},
+	{"rsync", 873},
+	{"ftps", 990},
+	{"imaps", 993},
+	{"pop3s", 995},
+	{"openvpn", 1194},
+	{"ms-sql-s", 1433},
+	{"l2tp", 1701},
+	{"pptp", 1723},
+	{"radius", 1812},
+	{"radius-acct", 1813},
+	{"mqtt", 1883},
+	{"nfs", 2049},
+	{"etcd-client", 2379},
+	{"etcd-server", 2380},
+	{"mysql", 3306},
+	{"ms-wbt-server", 3389},
+	{"vxlan", 4789},
+	{"sip", 5060},
+	{"sips", 5061},
+	{"xmpp-client", 5222},
+	{"mdns", 5353},
+	{"llmnr", 5355},
+	{"postgresql", 5432},
+	{"amqps", 5671},
+	{"amqp", 5672},
+	{"rfb", 5900},
+	{"geneve", 6081},
+	{"redis", 6379},
+	{"http-alt", 8080},
+	{"secure-mqtt", 8883},
+	{"memcache", 11211},
+	{"mongodb", 27017},
+}
+
+// ianaProtocols are the keywords from the IANA protocol numbers registry, for protocols seen in flows
+// https://www.iana.org/assignments/protocol-numbers/protocol-numbers.xhtml
+var ianaProtocols = []struct {
+	name   string
+	number int
+}{
+	{"HOPOPT", 0},
+	{"ICMP", 1},
+	{"IGMP", 2},
+	{"IPv4", 4},
+	{"TCP", 6},
+	{"EGP", 8},
+	{"IGP", 9},
+	{"UDP", 17},
+	{"IPv6", 41},
+	{"IPv6-Route", 43},
+	{"IPv6-Frag", 44},
+	{"RSVP", 46},
+	{"GRE", 47},
+	{"ESP", 50},
+	{"AH", 51},
+	{"IPv6-ICMP", 58},
+	{"IPv6-NoNxt", 59},
+	{"IPv6-Opts", 60},
+	{"EIGRP", 88},
+	{"OSPFIGP", 89},
+	{"IPIP", 94},
+	{"PIM", 103},
+	{"VRRP", 112},
+	{"L2TP", 115},
+	{"SCTP", 132},
+	{"UDPLite", 136},
+	{"MPLS-in-IP", 137},
+	{"ethernet", 143},
+}
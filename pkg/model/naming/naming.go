@@ -0,0 +1,201 @@
+// Package naming translates port and protocol numbers from and to their symbolic names, such as "https" or "TCP"
+package naming
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/config"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+)
+
+// maxWellKnownPort is the last port displayed with its IANA name, as others are often used as ephemeral ports.
+// Configured names are displayed regardless of the port.
+const maxWellKnownPort = 1023
+
+var (
+	servicePorts, serviceNames     = indexServices()
+	protocolNumbers, protocolNames = indexProtocols()
+)
+
+type fieldKind int
+
+const (
+	otherField fieldKind = iota
+	portField
+	protocolField
+)
+
+// Names translates ports and protocols according to the frontend configuration: when port naming is disabled,
+// ports are neither translated nor displayed with their names, like in the console
+type Names struct {
+	portNaming  bool
+	ports       map[string]int
+	portNames   map[int]string
+	fieldsKinds map[string]fieldKind
+}
+
+func New(cfg *config.Frontend) *Names {
+	n := Names{
+		portNaming: cfg.PortNaming.Enable,
+		ports:      map[string]int{},
+		portNames:  map[int]string{},
+		fieldsKinds: map[string]fieldKind{
+			fields.SrcPort: portField,
+			fields.DstPort: portField,
+			fields.Proto:   protocolField,
+		},
+	}
+	for portStr, name := range cfg.PortNaming.PortNames {
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			continue
+		}
+		n.ports[strings.ToLower(name)] = port
+		n.portNames[port] = name
+	}
+	// filter names are resolved to field names later on, see loki.FlowQueryBuilder
+	for i := range cfg.Fields {
+		if kind := n.fieldsKinds[cfg.Fields[i].Name]; kind != otherField && cfg.Fields[i].Filter != "" {
+			n.fieldsKinds[cfg.Fields[i].Filter] = kind
+		}
+	}
+	for i := range cfg.Columns {
+		if kind := n.fieldsKinds[cfg.Columns[i].Field]; kind != otherField && cfg.Columns[i].Filter != "" {
+			n.fieldsKinds[cfg.Columns[i].Filter] = kind
+		}
+	}
+	return &n
+}
+
+// Port returns the port of a service name, either configured or from IANA. Names are case-insensitive
+func (n *Names) Port(name string) (int, bool) {
+	if !n.portNaming {
+		return 0, false
+	}
+	name = strings.ToLower(name)
+	if port, ok := n.ports[name]; ok {
+		return port, true
+	}
+	port, ok := servicePorts[name]
+	return port, ok
+}
+
+// PortName returns the name displayed for a port: the configured name, or the IANA name of well-known ports
+func (n *Names) PortName(port int) (string, bool) {
+	if !n.portNaming {
+		return "", false
+	}
+	if name, ok := n.portNames[port]; ok {
+		return name, true
+	}
+	if port > maxWellKnownPort {
+		return "", false
+	}
+	name, ok := serviceNames[port]
+	return name, ok
+}
+
+// Protocol returns the number of an IANA protocol keyword. Names are case-insensitive
+func Protocol(name string) (int, bool) {
+	number, ok := protocolNumbers[strings.ToLower(name)]
+	return number, ok
+}
+
+// ProtocolName returns the IANA keyword of a protocol number
+func ProtocolName(number int) (string, bool) {
+	name, ok := protocolNames[number]
+	return name, ok
+}
+
+// TranslateFilters replaces service and protocol names by their numbers, in filters on port and protocol fields.
+// Unknown names are kept as is, so that they are reported as invalid numbers when building queries
+func (n *Names) TranslateFilters(mq filters.MultiQueries) {
+	for g := range mq {
+		for i := range mq[g] {
+			m := &mq[g][i]
+			kind := n.fieldsKinds[m.Key]
+			if kind == otherField || m.Regex {
+				continue
+			}
+			values := m.SplitValues()
+			translated := false
+			for j, value := range values {
+				name := strings.Trim(value, `"`)
+				if name == "" || filters.IsValidNumber(name) {
+					continue
+				}
+				if number, ok := n.number(kind, name); ok {
+					values[j] = strconv.Itoa(number)
+					translated = true
+				}
+			}
+			if translated {
+				for j := range values {
+					values[j] = filters.EscapeValue(values[j])
+				}
+				m.Values = strings.Join(values, ",")
+			}
+		}
+	}
+}
+
+func (n *Names) number(kind fieldKind, name string) (int, bool) {
+	if kind == protocolField {
+		return Protocol(name)
+	}
+	return n.Port(name)
+}
+
+// FormatValue formats the value of a port or protocol field as displayed in the console, e.g. "https (443)" or "TCP".
+// The second value is false for other fields and numbers without name
+func (n *Names) FormatValue(field string, value interface{}) (string, bool) {
+	// exported records use field names only
+	if field != fields.SrcPort && field != fields.DstPort && field != fields.Proto {
+		return "", false
+	}
+	number, err := strconv.Atoi(formatNumber(value))
+	if err != nil {
+		return "", false
+	}
+	if field == fields.Proto {
+		return ProtocolName(number)
+	}
+	name, ok := n.PortName(number)
+	if !ok {
+		return "", false
+	}
+	return name + " (" + strconv.Itoa(number) + ")", true
+}
+
+// formatNumber writes JSON numbers, decoded as float64, without decimals
+func formatNumber(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	}
+	return ""
+}
+
+func indexServices() (map[string]int, map[int]string) {
+	ports := make(map[string]int, len(ianaServices))
+	names := make(map[int]string, len(ianaServices))
+	for _, s := range ianaServices {
+		ports[s.name] = s.port
+		names[s.port] = s.name
+	}
+	return ports, names
+}
+
+func indexProtocols() (map[string]int, map[int]string) {
+	numbers := make(map[string]int, len(ianaProtocols))
+	names := make(map[int]string, len(ianaProtocols))
+	for _, p := range ianaProtocols {
+		numbers[strings.ToLower(p.name)] = p.number
+		names[p.number] = p.name
+	}
+	return numbers, names
+}
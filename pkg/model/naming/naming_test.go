@@ -0,0 +1,87 @@
+package naming
+
+import (
+	"testing"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/config"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
+	"github.com/stretchr/testify/assert"
+)
+
+var frontendConfig = config.Frontend{
+	PortNaming: config.PortNaming{Enable: true, PortNames: map[string]string{"3100": "loki", "8080": "api"}},
+	Columns: []config.Column{
+		{ID: "SrcPort", Field: "SrcPort", Filter: "src_port"},
+		{ID: "DstPort", Field: "DstPort", Filter: "dst_port"},
+		{ID: "Proto", Field: "Proto", Filter: "protocol"},
+	},
+}
+
+func TestTranslateFilters(t *testing.T) {
+	names := New(&frontendConfig)
+	mq := filters.MultiQueries{
+		{
+			filters.NewMatch("dst_port", "postgresql,HTTPS,8443"),
+			filters.NewNotMatch("SrcPort", `"loki"`),
+			filters.NewMatch("protocol", "udp"),
+		},
+		{
+			filters.NewMatch("Proto", `"TCP",""`),
+			filters.NewMoreThanOrEqualMatch("DstPort", "http"),
+			filters.NewMatch("SrcK8S_Name", "http"),
+			filters.NewMatch("DstPort", "unknown"),
+		},
+	}
+	names.TranslateFilters(mq)
+	assert.Equal(t, filters.MultiQueries{
+		{
+			filters.NewMatch("dst_port", "5432,443,8443"),
+			filters.NewNotMatch("SrcPort", "3100"),
+			filters.NewMatch("protocol", "17"),
+		},
+		{
+			filters.NewMatch("Proto", `6,""`),
+			filters.NewMoreThanOrEqualMatch("DstPort", "80"),
+			filters.NewMatch("SrcK8S_Name", "http"),
+			filters.NewMatch("DstPort", "unknown"),
+		},
+	}, mq)
+}
+
+func TestTranslateFilters_PortNamingDisabled(t *testing.T) {
+	cfg := frontendConfig
+	cfg.PortNaming.Enable = false
+	names := New(&cfg)
+	mq := filters.MultiQueries{{filters.NewMatch("DstPort", "https"), filters.NewMatch("Proto", "tcp")}}
+	names.TranslateFilters(mq)
+	// protocols are still translated
+	assert.Equal(t, filters.MultiQueries{{filters.NewMatch("DstPort", "https"), filters.NewMatch("Proto", "6")}}, mq)
+}
+
+func TestFormatValue(t *testing.T) {
+	names := New(&frontendConfig)
+	for _, tc := range []struct {
+		field    string
+		value    interface{}
+		expected string
+	}{
+		{field: "DstPort", value: float64(443), expected: "https (443)"},
+		{field: "SrcPort", value: "3100", expected: "loki (3100)"},
+		{field: "DstPort", value: float64(8080), expected: "api (8080)"},
+		{field: "Proto", value: float64(58), expected: "IPv6-ICMP"},
+	} {
+		formatted, ok := names.FormatValue(tc.field, tc.value)
+		assert.True(t, ok, tc.field, tc.value)
+		assert.Equal(t, tc.expected, formatted)
+	}
+
+	// IANA names are only displayed for well-known ports
+	_, ok := names.FormatValue("DstPort", float64(5432))
+	assert.False(t, ok)
+	_, ok = names.FormatValue("DstPort", float64(50000))
+	assert.False(t, ok)
+	_, ok = names.FormatValue("Bytes", float64(443))
+	assert.False(t, ok)
+	_, ok = names.FormatValue("DstPort", nil)
+	assert.False(t, ok)
+}
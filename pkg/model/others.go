@@ -0,0 +1,30 @@
+package model
+
+import (
+	"github.com/prometheus/common/model"
+)
+
+// OthersLabel flags the synthetic series of the traffic not in the top series
+const OthersLabel = "Others"
+
+// OthersSeries returns the remainder of the total once the top series are subtracted, per timestamp of the total.
+// Remainders are never negative: small differences between the queries could otherwise make them so
+func OthersSeries(top, total Matrix) model.SampleStream {
+	topSums := map[model.Time]model.SampleValue{}
+	for i := range top {
+		for _, v := range top[i].Values {
+			topSums[v.Timestamp] += v.Value
+		}
+	}
+	others := model.SampleStream{Metric: model.Metric{OthersLabel: "true"}, Values: []model.SamplePair{}}
+	for i := range total {
+		for _, v := range total[i].Values {
+			remainder := v.Value - topSums[v.Timestamp]
+			if remainder < 0 {
+				remainder = 0
+			}
+			others.Values = append(others.Values, model.SamplePair{Timestamp: v.Timestamp, Value: remainder})
+		}
+	}
+	return others
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOthersSeries(t *testing.T) {
+	top := Matrix{
+		{Metric: model.Metric{"SrcK8S_Namespace": "ns1"}, Values: samples(30, 20, 10)},
+		{Metric: model.Metric{"SrcK8S_Namespace": "ns2"}, Values: samples(10, 0)},
+	}
+	total := Matrix{{Metric: model.Metric{}, Values: samples(45, 20, 9, 5)}}
+
+	others := OthersSeries(top, total)
+
+	assert.Equal(t, model.Metric{OthersLabel: "true"}, others.Metric)
+	// remainders are not negative
+	assert.Equal(t, samples(5, 0, 0, 5), others.Values)
+
+	// without total, there is no remainder
+	assert.Empty(t, OthersSeries(top, nil).Values)
+}
@@ -0,0 +1,403 @@
+package model
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/config"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/prometheus/common/model"
+)
+
+// Topology node and group kinds, when not a Kubernetes resource or owner kind
+const (
+	TopologyKindNamespace = "Namespace"
+	TopologyKindNode      = "Node"
+	TopologyKindZone      = "Zone"
+	TopologyKindCluster   = "Cluster"
+)
+
+// TopologyGraphResponse represents the json response to a topology query in graph format
+type TopologyGraphResponse struct {
+	Graph         TopologyGraph   `json:"graph"`
+	Stats         AggregatedStats `json:"stats"`
+	UnixTimestamp int64           `json:"unixTimestamp"`
+}
+
+// TopologyGraph is the topology built from a metrics matrix: deduplicated nodes, directed edges between them,
+// and the groups nodes belong to
+type TopologyGraph struct {
+	Nodes  []TopologyNode  `json:"nodes"`
+	Edges  []TopologyEdge  `json:"edges"`
+	Groups []TopologyGroup `json:"groups"`
+}
+
+// TopologyPeer holds the attributes identifying a peer, as found in metric labels. Labels holds the values of scope
+// labels that are not among these attributes, such as subnet labels, keyed by their name without the Src / Dst prefix
+type TopologyPeer struct {
+	Addr        string            `json:"addr,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Type        string            `json:"type,omitempty"`
+	Namespace   string            `json:"namespace,omitempty"`
+	OwnerName   string            `json:"ownerName,omitempty"`
+	OwnerType   string            `json:"ownerType,omitempty"`
+	HostName    string            `json:"hostName,omitempty"`
+	Zone        string            `json:"zone,omitempty"`
+	ClusterName string            `json:"clusterName,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// TopologyNode is a peer of the topology. Kind is empty for peers only known by their address, and is the scope
+// name for peers only known by other scope labels.
+// Group is the id of the innermost group containing the node, if any
+type TopologyNode struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind,omitempty"`
+	TopologyPeer
+	Group string `json:"group,omitempty"`
+}
+
+// TopologyEdge is the traffic from a source node to a target node, which are the same for traffic within a node.
+// Values are computed over the whole range: Total is the sum of the values, or their integral over time for rates;
+// Avg and Max are computed per step
+type TopologyEdge struct {
+	ID     string  `json:"id"`
+	Source string  `json:"source"`
+	Target string  `json:"target"`
+	Total  float64 `json:"total"`
+	Avg    float64 `json:"avg"`
+	Max    float64 `json:"max"`
+	Latest float64 `json:"latest"`
+}
+
+// TopologyGroup is a set of nodes sharing the labels of a scope, such as a host, a namespace or an owner. Its kind is
+// the owner kind for owners, or the scope name for scopes other than the built-in ones. Parent is the id of the
+// enclosing group, if any
+type TopologyGroup struct {
+	ID     string   `json:"id"`
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	Parent string   `json:"parent,omitempty"`
+	Nodes  []string `json:"nodes"`
+}
+
+// groupLevel is a scope nodes can be grouped by, with the labels identifying its groups: its own, then its parents'
+type groupLevel struct {
+	scope  *config.Scope
+	labels []config.ScopeLabel
+}
+
+// groupLevels returns the scopes grouped by the groups parameter, such as "hosts+namespaces", from outermost to
+// innermost, as ordered in the scopes
+func groupLevels(scopes []config.Scope, groups string) []groupLevel {
+	var levels []groupLevel
+	names := strings.Split(groups, "+")
+	for i := range scopes {
+		s := &scopes[i]
+		if s.Group == "" || !slices.Contains(names, s.Group) {
+			continue
+		}
+		level := groupLevel{scope: s}
+		for _, scope := range s.WithParents(scopes) {
+			level.labels = append(level.labels, scope.Labels...)
+		}
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// scopesLabels returns the labels of all the scopes, once each, which identify the peers, and the first scope of
+// each label by its name without the Src / Dst prefix
+func scopesLabels(scopes []config.Scope) ([]config.ScopeLabel, map[string]string) {
+	var labels []config.ScopeLabel
+	labelScopes := map[string]string{}
+	for i := range scopes {
+		for _, l := range scopes[i].Labels {
+			name := strings.TrimPrefix(l.Src, fields.Src)
+			if _, ok := labelScopes[name]; !ok {
+				labelScopes[name] = scopes[i].Name
+				labels = append(labels, l)
+			}
+		}
+	}
+	return labels, labelScopes
+}
+
+// ID returns an unique id for the peer, built from its attributes
+func (p *TopologyPeer) ID() string {
+	var parts []string
+	if p.ClusterName != "" {
+		parts = append(parts, "c="+p.ClusterName)
+	}
+	if p.Zone != "" {
+		parts = append(parts, "z="+p.Zone)
+	}
+	if p.HostName != "" {
+		parts = append(parts, "h="+p.HostName)
+	}
+	if p.Namespace != "" {
+		parts = append(parts, "n="+p.Namespace)
+	}
+	if p.OwnerName != "" {
+		parts = append(parts, "o="+p.OwnerType+"."+p.OwnerName)
+	}
+	if p.Name != "" {
+		parts = append(parts, "r="+p.Type+"."+p.Name)
+	}
+	keys := make([]string, 0, len(p.Labels))
+	for k := range p.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, k+"="+p.Labels[k])
+	}
+	if p.Addr != "" {
+		parts = append(parts, "a="+p.Addr)
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, ",")
+}
+
+// KindAndName returns the kind of the peer, and its name within that kind
+func (p *TopologyPeer) KindAndName() (string, string) {
+	switch {
+	case p.Name != "":
+		return p.Type, p.Name
+	case p.OwnerName != "":
+		return p.OwnerType, p.OwnerName
+	case p.Namespace != "":
+		return TopologyKindNamespace, p.Namespace
+	case p.HostName != "":
+		return TopologyKindNode, p.HostName
+	case p.Zone != "":
+		return TopologyKindZone, p.Zone
+	case p.ClusterName != "":
+		return TopologyKindCluster, p.ClusterName
+	}
+	return "", p.Addr
+}
+
+// peerFromMetric returns the source or destination peer of the metric, from the given scope labels
+func peerFromMetric(metric model.Metric, src bool, labels []config.ScopeLabel) TopologyPeer {
+	var p TopologyPeer
+	for _, l := range labels {
+		label := l.Src
+		if !src && l.Dst != "" {
+			label = l.Dst
+		}
+		if value := string(metric[model.LabelName(label)]); value != "" {
+			p.set(strings.TrimPrefix(l.Src, fields.Src), value)
+		}
+	}
+	return p
+}
+
+// get returns the attribute of the peer matching the label name, without its Src / Dst prefix
+func (p *TopologyPeer) get(name string) string {
+	switch name {
+	case fields.Addr:
+		return p.Addr
+	case fields.Name:
+		return p.Name
+	case fields.Type:
+		return p.Type
+	case fields.Namespace:
+		return p.Namespace
+	case fields.OwnerName:
+		return p.OwnerName
+	case fields.OwnerType:
+		return p.OwnerType
+	case fields.HostName:
+		return p.HostName
+	case fields.Zone:
+		return p.Zone
+	case fields.Cluster:
+		return p.ClusterName
+	}
+	return p.Labels[name]
+}
+
+// set sets the attribute of the peer matching the label name, without its Src / Dst prefix
+func (p *TopologyPeer) set(name, value string) {
+	switch name {
+	case fields.Addr:
+		p.Addr = value
+	case fields.Name:
+		p.Name = value
+	case fields.Type:
+		p.Type = value
+	case fields.Namespace:
+		p.Namespace = value
+	case fields.OwnerName:
+		p.OwnerName = value
+	case fields.OwnerType:
+		p.OwnerType = value
+	case fields.HostName:
+		p.HostName = value
+	case fields.Zone:
+		p.Zone = value
+	case fields.Cluster:
+		p.ClusterName = value
+	default:
+		if p.Labels == nil {
+			p.Labels = map[string]string{}
+		}
+		p.Labels[name] = value
+	}
+}
+
+// topologyGraphBuilder deduplicates nodes, edges and groups while reading the matrix
+type topologyGraphBuilder struct {
+	graph       TopologyGraph
+	nodes       map[string]bool
+	groups      map[string]int
+	edges       map[string]int
+	edgeValues  []map[model.Time]float64
+	labels      []config.ScopeLabel
+	labelScopes map[string]string
+	levels      []groupLevel
+}
+
+// BuildTopologyGraph builds a topology graph from a matrix of metrics labelled as in the scopes. Groups lists the
+// groups nodes are assigned to, such as "hosts+namespaces", as in the groups parameter. Rate tells that values are
+// rates, for which totals are integrated over time, each value standing for the step that ends with it
+func BuildTopologyGraph(matrix Matrix, scopes []config.Scope, groups string, rate bool, step time.Duration) TopologyGraph {
+	b := topologyGraphBuilder{
+		graph:  TopologyGraph{Nodes: []TopologyNode{}, Edges: []TopologyEdge{}, Groups: []TopologyGroup{}},
+		nodes:  map[string]bool{},
+		groups: map[string]int{},
+		edges:  map[string]int{},
+		levels: groupLevels(scopes, groups),
+	}
+	b.labels, b.labelScopes = scopesLabels(scopes)
+	for i := range matrix {
+		src := peerFromMetric(matrix[i].Metric, true, b.labels)
+		dst := peerFromMetric(matrix[i].Metric, false, b.labels)
+		srcID := b.addNode(&src)
+		dstID := b.addNode(&dst)
+		b.addEdgeValues(srcID, dstID, matrix[i].Values)
+	}
+	for i := range b.graph.Edges {
+		b.graph.Edges[i].setStats(b.edgeValues[i], rate, step)
+	}
+	return b.graph
+}
+
+func (b *topologyGraphBuilder) addNode(p *TopologyPeer) string {
+	id := p.ID()
+	if b.nodes[id] {
+		return id
+	}
+	kind, _ := p.KindAndName()
+	if kind == "" && p.Addr == "" {
+		kind = b.labelsKind(p)
+	}
+	b.nodes[id] = true
+	b.graph.Nodes = append(b.graph.Nodes, TopologyNode{ID: id, Kind: kind, TopologyPeer: *p, Group: b.addGroups(p, id)})
+	return id
+}
+
+// labelsKind returns the scope of the first label of the peer, for peers only known by labels other than the
+// built-in attributes
+func (b *topologyGraphBuilder) labelsKind(p *TopologyPeer) string {
+	var first string
+	for name := range p.Labels {
+		if first == "" || name < first {
+			first = name
+		}
+	}
+	return b.labelScopes[first]
+}
+
+// addGroups adds the node to the groups it belongs to, nesting them from outermost to innermost, and returns
+// the innermost one
+func (b *topologyGraphBuilder) addGroups(p *TopologyPeer, nodeID string) string {
+	var parent string
+	for _, level := range b.levels {
+		gp, name := level.peer(p)
+		gid := gp.ID()
+		if name == "" || gid == nodeID {
+			// peer is not part of this group, or is the group itself
+			continue
+		}
+		if _, ok := b.groups[gid]; !ok {
+			kind, _ := gp.KindAndName()
+			if kind == "" {
+				kind = level.scope.Name
+			}
+			b.groups[gid] = len(b.graph.Groups)
+			b.graph.Groups = append(b.graph.Groups, TopologyGroup{ID: gid, Kind: kind, Name: name, Parent: parent, Nodes: []string{}})
+		}
+		parent = gid
+	}
+	if parent != "" {
+		g := &b.graph.Groups[b.groups[parent]]
+		g.Nodes = append(g.Nodes, nodeID)
+	}
+	return parent
+}
+
+// peer returns the group of the level the peer belongs to, and its name: the value of the first label of the scope
+// set on the peer, if any
+func (level *groupLevel) peer(p *TopologyPeer) (TopologyPeer, string) {
+	var gp TopologyPeer
+	for _, l := range level.labels {
+		name := strings.TrimPrefix(l.Src, fields.Src)
+		if value := p.get(name); value != "" {
+			gp.set(name, value)
+		}
+	}
+	for _, l := range level.scope.Labels {
+		if value := p.get(strings.TrimPrefix(l.Src, fields.Src)); value != "" {
+			return gp, value
+		}
+	}
+	return gp, ""
+}
+
+// addEdgeValues adds values to the edge from source to target; values of streams leading to the same edge are summed
+func (b *topologyGraphBuilder) addEdgeValues(source, target string, values []model.SamplePair) {
+	id := source + "." + target
+	idx, ok := b.edges[id]
+	if !ok {
+		idx = len(b.graph.Edges)
+		b.edges[id] = idx
+		b.graph.Edges = append(b.graph.Edges, TopologyEdge{ID: id, Source: source, Target: target})
+		b.edgeValues = append(b.edgeValues, map[model.Time]float64{})
+	}
+	for _, v := range values {
+		if !math.IsNaN(float64(v.Value)) {
+			b.edgeValues[idx][v.Timestamp] += float64(v.Value)
+		}
+	}
+}
+
+func (e *TopologyEdge) setStats(values map[model.Time]float64, rate bool, step time.Duration) {
+	if len(values) == 0 {
+		return
+	}
+	timestamps := make([]model.Time, 0, len(values))
+	for ts := range values {
+		timestamps = append(timestamps, ts)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i].Before(timestamps[j]) })
+	var sum float64
+	for _, ts := range timestamps {
+		sum += values[ts]
+		e.Max = math.Max(e.Max, values[ts])
+	}
+	e.Avg = sum / float64(len(timestamps))
+	e.Latest = values[timestamps[len(timestamps)-1]]
+	if rate {
+		// rates are per second, each over a step: missing values stand for steps without traffic
+		e.Total = sum * step.Seconds()
+	} else {
+		e.Total = sum
+	}
+}
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/config"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func samples(values ...float64) []model.SamplePair {
+	var pairs []model.SamplePair
+	for i, v := range values {
+		pairs = append(pairs, model.SamplePair{Timestamp: model.Time(int64(i) * 30000), Value: model.SampleValue(v)})
+	}
+	return pairs
+}
+
+func ownerMetric(srcNs, srcOwner, dstNs, dstOwner, host string) model.Metric {
+	return model.Metric{
+		"SrcK8S_Namespace": model.LabelValue(srcNs), "SrcK8S_OwnerName": model.LabelValue(srcOwner), "SrcK8S_OwnerType": "Deployment",
+		"DstK8S_Namespace": model.LabelValue(dstNs), "DstK8S_OwnerName": model.LabelValue(dstOwner), "DstK8S_OwnerType": "Deployment",
+		"SrcK8S_HostName": model.LabelValue(host), "DstK8S_HostName": model.LabelValue(host),
+	}
+}
+
+func TestBuildTopologyGraph(t *testing.T) {
+	matrix := Matrix{
+		{Metric: ownerMetric("ns1", "api", "ns2", "db", "node1"), Values: samples(10, 20, 30)},
+		{Metric: ownerMetric("ns2", "db", "ns1", "api", "node1"), Values: samples(1, 1, 1)},
+		// streams leading to the same edge are merged
+		{Metric: ownerMetric("ns1", "api", "ns2", "db", "node1"), Values: samples(5, 5, 5)},
+		{Metric: model.Metric{"SrcAddr": "1.2.3.4", "DstK8S_Namespace": "ns1", "DstK8S_OwnerName": "api", "DstK8S_OwnerType": "Deployment", "DstK8S_HostName": "node1"}, Values: samples(2, 4)},
+	}
+
+	graph := BuildTopologyGraph(matrix, config.DefaultScopes, "hosts+namespaces", false, 30*time.Second)
+
+	api := "h=node1,n=ns1,o=Deployment.api"
+	db := "h=node1,n=ns2,o=Deployment.db"
+	require.Len(t, graph.Nodes, 3)
+	assert.Equal(t, TopologyNode{
+		ID:           api,
+		Kind:         "Deployment",
+		TopologyPeer: TopologyPeer{Namespace: "ns1", OwnerName: "api", OwnerType: "Deployment", HostName: "node1"},
+		Group:        "n=ns1",
+	}, graph.Nodes[0])
+	assert.Equal(t, db, graph.Nodes[1].ID)
+	assert.Equal(t, "n=ns2", graph.Nodes[1].Group)
+	assert.Equal(t, TopologyNode{ID: "a=1.2.3.4", TopologyPeer: TopologyPeer{Addr: "1.2.3.4"}}, graph.Nodes[2])
+
+	assert.Equal(t, []TopologyGroup{
+		{ID: "h=node1", Kind: "Node", Name: "node1", Nodes: []string{}},
+		{ID: "n=ns1", Kind: "Namespace", Name: "ns1", Parent: "h=node1", Nodes: []string{api}},
+		{ID: "n=ns2", Kind: "Namespace", Name: "ns2", Parent: "h=node1", Nodes: []string{db}},
+	}, graph.Groups)
+
+	assert.Equal(t, []TopologyEdge{
+		{ID: api + "." + db, Source: api, Target: db, Total: 75, Avg: 25, Max: 35, Latest: 35},
+		{ID: db + "." + api, Source: db, Target: api, Total: 3, Avg: 1, Max: 1, Latest: 1},
+		{ID: "a=1.2.3.4." + api, Source: "a=1.2.3.4", Target: api, Total: 6, Avg: 3, Max: 4, Latest: 4},
+	}, graph.Edges)
+}
+
+func TestBuildTopologyGraph_Rates(t *testing.T) {
+	matrix := Matrix{
+		{Metric: model.Metric{"SrcK8S_Namespace": "ns1", "DstK8S_Namespace": "ns2"}, Values: samples(10, 20, 30)},
+		// traffic within a node is a loop edge
+		{Metric: model.Metric{"SrcK8S_Namespace": "ns1", "DstK8S_Namespace": "ns1"}, Values: samples(10)},
+	}
+
+	graph := BuildTopologyGraph(matrix, config.DefaultScopes, "", true, 30*time.Second)
+
+	assert.Equal(t, []TopologyNode{
+		{ID: "n=ns1", Kind: "Namespace", TopologyPeer: TopologyPeer{Namespace: "ns1"}},
+		{ID: "n=ns2", Kind: "Namespace", TopologyPeer: TopologyPeer{Namespace: "ns2"}},
+	}, graph.Nodes)
+	assert.Empty(t, graph.Groups)
+	// 20 per second over three steps of 30s; a single value covers one step
+	assert.Equal(t, []TopologyEdge{
+		{ID: "n=ns1.n=ns2", Source: "n=ns1", Target: "n=ns2", Total: 1800, Avg: 20, Max: 30, Latest: 30},
+		{ID: "n=ns1.n=ns1", Source: "n=ns1", Target: "n=ns1", Total: 300, Avg: 10, Max: 10, Latest: 10},
+	}, graph.Edges)
+}
+
+func TestBuildTopologyGraph_ConfiguredScopes(t *testing.T) {
+	scopes := append(
+		slices.Clone(config.DefaultScopes),
+		config.Scope{Name: "subnet", Labels: []config.ScopeLabel{{Src: "SrcSubnetLabel", Dst: "DstSubnetLabel"}}, Group: "subnets"},
+	)
+	matrix := Matrix{
+		{Metric: model.Metric{"SrcK8S_Namespace": "ns1", "SrcSubnetLabel": "pods", "DstK8S_Namespace": "ns2", "DstSubnetLabel": "pods"}, Values: samples(10)},
+		{Metric: model.Metric{"SrcK8S_Namespace": "ns1", "SrcSubnetLabel": "pods", "DstSubnetLabel": "external"}, Values: samples(5)},
+	}
+
+	// nodes are grouped by the configured scope
+	graph := BuildTopologyGraph(matrix, scopes, "subnets", false, 30*time.Second)
+
+	assert.Equal(t, []TopologyGroup{
+		{ID: "SubnetLabel=pods", Kind: "subnet", Name: "pods", Nodes: []string{"n=ns1,SubnetLabel=pods", "n=ns2,SubnetLabel=pods"}},
+	}, graph.Groups)
+	require.Len(t, graph.Nodes, 3)
+	assert.Equal(t, TopologyNode{
+		ID:           "n=ns1,SubnetLabel=pods",
+		Kind:         TopologyKindNamespace,
+		TopologyPeer: TopologyPeer{Namespace: "ns1", Labels: map[string]string{"SubnetLabel": "pods"}},
+		Group:        "SubnetLabel=pods",
+	}, graph.Nodes[0])
+	// peers only known by the configured scope are not grouped by themselves
+	assert.Equal(t, TopologyNode{
+		ID:           "SubnetLabel=external",
+		Kind:         "subnet",
+		TopologyPeer: TopologyPeer{Labels: map[string]string{"SubnetLabel": "external"}},
+	}, graph.Nodes[2])
+}
@@ -0,0 +1,69 @@
+package newpeers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// DefaultCacheEntries is the default maximum number of baselines kept in cache
+const DefaultCacheEntries = 100
+
+// Cache keeps the baselines learned per query, so that they are extended rather than learned again on each call.
+// The least recently used baselines are evicted beyond the maximum number of entries
+type Cache struct {
+	mu         sync.Mutex
+	entries    map[string]*cacheEntry
+	maxEntries int
+}
+
+type cacheEntry struct {
+	baseline *Baseline
+	used     time.Time
+}
+
+func NewCache(maxEntries int) *Cache {
+	return &Cache{entries: map[string]*cacheEntry{}, maxEntries: maxEntries}
+}
+
+// Key returns a cache key identifying the query parts, such as filters or the user token
+func Key(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) Get(key string) *Baseline {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	e.used = time.Now()
+	return e.baseline
+}
+
+func (c *Cache) Put(key string, b *Baseline) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evictOldest()
+	}
+	c.entries[key] = &cacheEntry{baseline: b, used: time.Now()}
+}
+
+func (c *Cache) evictOldest() {
+	var oldestKey string
+	var oldest time.Time
+	for k, e := range c.entries {
+		if oldestKey == "" || e.used.Before(oldest) {
+			oldestKey, oldest = k, e.used
+		}
+	}
+	delete(c.entries, oldestKey)
+}
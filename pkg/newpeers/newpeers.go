@@ -0,0 +1,170 @@
+// Package newpeers detects connections between workloads, or between workloads and external IPs, that were not
+// seen in a baseline time window
+package newpeers
+
+import (
+	"sort"
+	"time"
+
+	pmodel "github.com/prometheus/common/model"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+)
+
+// Edge directions: between workloads, from a workload to an external IP, or from an external IP to a workload
+const (
+	DirectionInternal = "internal"
+	DirectionEgress   = "egress"
+	DirectionIngress  = "ingress"
+)
+
+// Peer is a workload, identified by its owner, or an external IP address
+type Peer struct {
+	Namespace string `json:"namespace,omitempty"`
+	OwnerName string `json:"ownerName,omitempty"`
+	OwnerType string `json:"ownerType,omitempty"`
+	Addr      string `json:"addr,omitempty"`
+}
+
+// Edge is a connection from a source peer to a destination peer
+type Edge struct {
+	Source      Peer   `json:"source"`
+	Destination Peer   `json:"destination"`
+	Direction   string `json:"direction"`
+}
+
+// NewPeer is an edge of the recent window that is not in the baseline, with the time it was first seen
+// (unix seconds) and its bytes over the recent window
+type NewPeer struct {
+	Edge
+	FirstSeen int64   `json:"firstSeen"`
+	Bytes     float64 `json:"bytes"`
+}
+
+// Response represents the json response to a new peers query
+type Response struct {
+	Peers         []NewPeer `json:"peers"`
+	BaselineStart int64     `json:"baselineStart"`
+	BaselineEnd   int64     `json:"baselineEnd"`
+	BaselineEdges int       `json:"baselineEdges"`
+	UnixTimestamp int64     `json:"unixTimestamp"`
+}
+
+func (p *Peer) key() string {
+	if p.OwnerName != "" {
+		return p.Namespace + "/" + p.OwnerType + "/" + p.OwnerName
+	}
+	return p.Addr
+}
+
+func (e *Edge) key() string {
+	return e.Source.key() + ">" + e.Destination.key()
+}
+
+func peerFromMetric(metric pmodel.Metric, prefix string) (Peer, bool) {
+	get := func(field string) string { return string(metric[pmodel.LabelName(prefix+field)]) }
+	if owner := get(fields.OwnerName); owner != "" {
+		return Peer{Namespace: get(fields.Namespace), OwnerName: owner, OwnerType: get(fields.OwnerType)}, true
+	}
+	if addr := get(fields.Addr); addr != "" {
+		return Peer{Addr: addr}, true
+	}
+	return Peer{}, false
+}
+
+// edgeFromMetric reads the edge of owner labelled metrics. Peers without an owner are identified by their address,
+// when it's in the labels; edges between two addresses, or with unknown peers, are ignored
+func edgeFromMetric(metric pmodel.Metric) (Edge, bool) {
+	src, srcOK := peerFromMetric(metric, fields.Src)
+	dst, dstOK := peerFromMetric(metric, fields.Dst)
+	if !srcOK || !dstOK {
+		return Edge{}, false
+	}
+	e := Edge{Source: src, Destination: dst}
+	switch {
+	case src.OwnerName != "" && dst.OwnerName != "":
+		e.Direction = DirectionInternal
+	case src.OwnerName != "":
+		e.Direction = DirectionEgress
+	case dst.OwnerName != "":
+		e.Direction = DirectionIngress
+	default:
+		return Edge{}, false
+	}
+	return e, true
+}
+
+// Observe returns the edges with traffic in the matrices, with the time they were first seen and their bytes
+func Observe(matrices ...model.Matrix) map[string]*NewPeer {
+	observed := map[string]*NewPeer{}
+	for _, matrix := range matrices {
+		for i := range matrix {
+			e, ok := edgeFromMetric(matrix[i].Metric)
+			if !ok {
+				continue
+			}
+			for _, v := range matrix[i].Values {
+				if v.Value <= 0 {
+					continue
+				}
+				o, found := observed[e.key()]
+				if !found {
+					o = &NewPeer{Edge: e, FirstSeen: v.Timestamp.Unix()}
+					observed[e.key()] = o
+				}
+				o.Bytes += float64(v.Value)
+				if v.Timestamp.Unix() < o.FirstSeen {
+					o.FirstSeen = v.Timestamp.Unix()
+				}
+			}
+		}
+	}
+	return observed
+}
+
+// Baseline is the set of edges learned over a time window. It's never modified once built, so that it can be
+// shared between requests
+type Baseline struct {
+	Start time.Time
+	End   time.Time
+	edges map[string]bool
+}
+
+func NewBaseline(start time.Time) *Baseline {
+	return &Baseline{Start: start, End: start, edges: map[string]bool{}}
+}
+
+// Extend returns a new baseline, also covering the time up to end with the edges of the matrices
+func (b *Baseline) Extend(end time.Time, matrices ...model.Matrix) *Baseline {
+	extended := &Baseline{Start: b.Start, End: end, edges: make(map[string]bool, len(b.edges))}
+	for k := range b.edges {
+		extended.edges[k] = true
+	}
+	for k := range Observe(matrices...) {
+		extended.edges[k] = true
+	}
+	return extended
+}
+
+// Len returns the number of edges in the baseline
+func (b *Baseline) Len() int {
+	return len(b.edges)
+}
+
+// NewPeers returns the edges of the matrices that are not in the baseline, sorted by first seen time
+func (b *Baseline) NewPeers(matrices ...model.Matrix) []NewPeer {
+	peers := []NewPeer{}
+	for k, o := range Observe(matrices...) {
+		if !b.edges[k] {
+			peers = append(peers, *o)
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		if peers[i].FirstSeen != peers[j].FirstSeen {
+			return peers[i].FirstSeen < peers[j].FirstSeen
+		}
+		return peers[i].key() < peers[j].key()
+	})
+	return peers
+}
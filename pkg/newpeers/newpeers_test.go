@@ -0,0 +1,83 @@
+package newpeers
+
+import (
+	"testing"
+	"time"
+
+	pmodel "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/model"
+)
+
+func ownerEdge(src, dst string) pmodel.Metric {
+	return pmodel.Metric{
+		"SrcK8S_Namespace": "ns", "SrcK8S_OwnerName": pmodel.LabelValue(src), "SrcK8S_OwnerType": "Deployment",
+		"DstK8S_Namespace": "ns", "DstK8S_OwnerName": pmodel.LabelValue(dst), "DstK8S_OwnerType": "Deployment",
+	}
+}
+
+func stream(metric pmodel.Metric, values ...float64) pmodel.SampleStream {
+	s := pmodel.SampleStream{Metric: metric}
+	for i, v := range values {
+		s.Values = append(s.Values, pmodel.SamplePair{Timestamp: pmodel.TimeFromUnix(int64(1000 + i*30)), Value: pmodel.SampleValue(v)})
+	}
+	return s
+}
+
+func TestNewPeers(t *testing.T) {
+	egress := pmodel.Metric{"SrcK8S_Namespace": "ns", "SrcK8S_OwnerName": "api", "SrcK8S_OwnerType": "Deployment", "DstAddr": "1.2.3.4"}
+	ingress := pmodel.Metric{"SrcAddr": "5.6.7.8", "DstK8S_Namespace": "ns", "DstK8S_OwnerName": "api", "DstK8S_OwnerType": "Deployment"}
+	baseline := NewBaseline(time.Unix(0, 0)).Extend(time.Unix(1000, 0),
+		model.Matrix{stream(ownerEdge("api", "db"), 100)},
+		// edges without traffic are not learned
+		model.Matrix{stream(ownerEdge("api", "cache"), 0)},
+	)
+	require.Equal(t, 1, baseline.Len())
+
+	peers := baseline.NewPeers(
+		model.Matrix{stream(ownerEdge("api", "db"), 100), stream(ownerEdge("api", "cache"), 0, 10, 20)},
+		model.Matrix{stream(egress, 0, 0, 5)},
+		model.Matrix{stream(ingress, 1, 2)},
+		// edges between unknown peers are ignored
+		model.Matrix{stream(pmodel.Metric{"SrcAddr": "5.6.7.8", "DstAddr": "1.2.3.4"}, 100)},
+	)
+
+	api := Peer{Namespace: "ns", OwnerName: "api", OwnerType: "Deployment"}
+	assert.Equal(t, []NewPeer{
+		{Edge: Edge{Source: Peer{Addr: "5.6.7.8"}, Destination: api, Direction: DirectionIngress}, FirstSeen: 1000, Bytes: 3},
+		{Edge: Edge{Source: api, Destination: Peer{Namespace: "ns", OwnerName: "cache", OwnerType: "Deployment"}, Direction: DirectionInternal}, FirstSeen: 1030, Bytes: 30},
+		{Edge: Edge{Source: api, Destination: Peer{Addr: "1.2.3.4"}, Direction: DirectionEgress}, FirstSeen: 1060, Bytes: 5},
+	}, peers)
+}
+
+func TestBaseline_Extend(t *testing.T) {
+	initial := NewBaseline(time.Unix(0, 0)).Extend(time.Unix(1000, 0), model.Matrix{stream(ownerEdge("api", "db"), 1)})
+	extended := initial.Extend(time.Unix(2000, 0), model.Matrix{stream(ownerEdge("api", "cache"), 1)})
+
+	// baselines are not modified once built
+	assert.Equal(t, 1, initial.Len())
+	assert.Equal(t, time.Unix(1000, 0), initial.End)
+	assert.Equal(t, 2, extended.Len())
+	assert.Equal(t, time.Unix(0, 0), extended.Start)
+	assert.Equal(t, time.Unix(2000, 0), extended.End)
+	assert.Empty(t, extended.NewPeers(model.Matrix{stream(ownerEdge("api", "db"), 1), stream(ownerEdge("api", "cache"), 1)}))
+}
+
+func TestCache(t *testing.T) {
+	c := NewCache(2)
+	b1, b2, b3 := NewBaseline(time.Unix(1, 0)), NewBaseline(time.Unix(2, 0)), NewBaseline(time.Unix(3, 0))
+	c.Put(Key("a"), b1)
+	c.Put(Key("b"), b2)
+	assert.Same(t, b1, c.Get(Key("a")))
+
+	// least recently used is evicted
+	c.Put(Key("c"), b3)
+	assert.Nil(t, c.Get(Key("b")))
+	assert.Same(t, b1, c.Get(Key("a")))
+	assert.Same(t, b3, c.Get(Key("c")))
+
+	// key parts are separated
+	assert.NotEqual(t, Key("ab", "c"), Key("a", "bc"))
+}
@@ -105,10 +105,18 @@ func QueryMatrix(ctx context.Context, cl api.Client, q *Query) (model.QueryRespo
 	return qr, code, nil
 }
 
-func GetLabelValues(ctx context.Context, cl api.Client, label string, match []string) ([]string, int, error) {
+// GetLabelValues returns the values of a label for series matching any of the provided selectors, within the time range.
+// When unset, start defaults to 3 hours ago and end to now.
+func GetLabelValues(ctx context.Context, cl api.Client, label string, match []string, start, end time.Time) ([]string, int, error) {
 	log.Debugf("GetLabelValues: %s", label)
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if start.IsZero() {
+		start = end.Add(-3 * time.Hour)
+	}
 	v1api := v1.NewAPI(cl)
-	result, warnings, err := v1api.LabelValues(ctx, label, match, time.Now().Add(-3*time.Hour), time.Now())
+	result, warnings, err := v1api.LabelValues(ctx, label, match, start, end)
 	if err != nil {
 		return nil, http.StatusServiceUnavailable, err
 	}
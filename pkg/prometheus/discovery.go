@@ -0,0 +1,188 @@
+package prometheus
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/config"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+const (
+	// DefaultDiscoveryInterval is the interval between two discoveries, when not configured
+	DefaultDiscoveryInterval = 5 * time.Minute
+	metricsPrefix            = "netobserv_"
+	// discoveryLookback is the time range in which series are read to find the labels of metrics
+	discoveryLookback = time.Hour
+)
+
+// StartDiscovery discovers the netobserv metrics known by Prometheus now and then periodically, until the context
+// is done. Discovered metrics are merged with the configured ones. Errors are logged, keeping the known metrics
+func (i *Inventory) StartDiscovery(ctx context.Context, cl api.Client, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultDiscoveryInterval
+	}
+	go func() {
+		for {
+			i.refresh(ctx, cl)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+func (i *Inventory) refresh(ctx context.Context, cl api.Client) {
+	discovered, err := DiscoverMetrics(ctx, cl)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.WithError(err).Warn("Prometheus metrics discovery failed, keeping known metrics")
+		}
+		return
+	}
+	log.Debugf("Discovered %d Prometheus metrics", len(discovered))
+	i.setMetrics(withDNSCounters(mergeMetrics(i.configured, discovered)))
+}
+
+// DiscoverMetrics lists the netobserv metrics known by Prometheus, inferring their value field and direction from
+// their names, and their labels from their series. Metrics without any series or without flow labels, such as the
+// operator or agent own metrics, are ignored
+func DiscoverMetrics(ctx context.Context, cl api.Client) ([]config.MetricInfo, error) {
+	v1api := v1.NewAPI(cl)
+	metadata, err := v1api.Metadata(ctx, "", "")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(metadata))
+	for name := range metadata {
+		if strings.HasPrefix(name, metricsPrefix) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	end := time.Now()
+	start := end.Add(-discoveryLookback)
+	var discovered []config.MetricInfo
+	for _, name := range names {
+		if len(metadata[name]) == 0 {
+			continue
+		}
+		m, ok := inferMetric(name, metadata[name][0].Type)
+		if !ok {
+			continue
+		}
+		// histograms series are named after their buckets, sum and count; the latter doesn't have the "le" label
+		match := name
+		if m.Type == string(v1.MetricTypeHistogram) {
+			match += "_count"
+		}
+		labels, warnings, err := v1api.LabelNames(ctx, []string{match}, start, end)
+		if err != nil {
+			return nil, err
+		}
+		if len(warnings) > 0 {
+			log.Infof("DiscoverMetrics warnings: %v", warnings)
+		}
+		m.Labels = flowLabels(labels)
+		if len(m.Labels) > 0 {
+			discovered = append(discovered, m)
+		}
+	}
+	return discovered, nil
+}
+
+// inferMetric infers the value field and direction of a metric from its name, such as
+// netobserv_workload_ingress_bytes_total or netobserv_namespace_rtt_seconds. Returns false when the name is not known
+func inferMetric(name string, metricType v1.MetricType) (config.MetricInfo, bool) {
+	m := config.MetricInfo{Enabled: true, Name: name, Type: string(metricType), Direction: config.AnyDirection}
+	parts := strings.Split(strings.TrimPrefix(name, metricsPrefix), "_")
+	switch {
+	case slices.Contains(parts, "ingress"):
+		m.Direction = config.Ingress
+	case slices.Contains(parts, "egress"):
+		m.Direction = config.Egress
+	}
+	isDrop := slices.Contains(parts, "drop")
+
+	switch metricType {
+	case v1.MetricTypeCounter:
+		switch {
+		case strings.HasSuffix(name, "_flows_total"):
+			m.ValueField = constants.MetricTypeFlows
+		case strings.HasSuffix(name, "_bytes_total") && isDrop:
+			m.ValueField = fields.PktDropBytes
+		case strings.HasSuffix(name, "_bytes_total"):
+			m.ValueField = fields.Bytes
+		case strings.HasSuffix(name, "_packets_total") && isDrop:
+			m.ValueField = fields.PktDropPackets
+		case strings.HasSuffix(name, "_packets_total"):
+			m.ValueField = fields.Packets
+		default:
+			return m, false
+		}
+	case v1.MetricTypeHistogram:
+		switch {
+		case strings.HasSuffix(name, "_dns_latency_seconds"):
+			m.ValueField = fields.DNSLatency
+		case strings.HasSuffix(name, "_rtt_seconds"):
+			m.ValueField = fields.TimeFlowRTT
+		default:
+			return m, false
+		}
+	case v1.MetricTypeGauge, v1.MetricTypeGaugeHistogram, v1.MetricTypeSummary, v1.MetricTypeInfo,
+		v1.MetricTypeStateset, v1.MetricTypeUnknown:
+		return m, false
+	default:
+		return m, false
+	}
+	return m, true
+}
+
+// flowLabels returns the labels of series, without the metric name nor the histogram buckets.
+// Returns nil when no label is about the source or destination of flows
+func flowLabels(labels []string) []string {
+	var result []string
+	hasFlowLabel := false
+	for _, l := range labels {
+		if l == "__name__" || l == "le" {
+			continue
+		}
+		if strings.HasPrefix(l, fields.Src) || strings.HasPrefix(l, fields.Dst) {
+			hasFlowLabel = true
+		}
+		result = append(result, l)
+	}
+	if !hasFlowLabel {
+		return nil
+	}
+	return result
+}
+
+// mergeMetrics merges the discovered metrics with the configured ones. The configured metrics take precedence, such
+// as to keep disabled metrics disabled, but their labels are completed by the discovered ones, read from their series
+func mergeMetrics(configured, discovered []config.MetricInfo) []config.MetricInfo {
+	merged := slices.Clone(configured)
+	for _, d := range discovered {
+		if i := slices.IndexFunc(merged, func(m config.MetricInfo) bool { return m.Name == d.Name }); i >= 0 {
+			// series may not have all the configured labels yet, e.g. when no flow had them
+			labels := slices.Clone(merged[i].Labels)
+			for _, l := range d.Labels {
+				if !slices.Contains(labels, l) {
+					labels = append(labels, l)
+				}
+			}
+			merged[i].Labels = labels
+		} else {
+			merged = append(merged, d)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,125 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/config"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferMetric(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		metricType v1.MetricType
+		valueField string
+		direction  config.FlowDirection
+	}{
+		{name: "netobserv_workload_ingress_bytes_total", metricType: v1.MetricTypeCounter, valueField: fields.Bytes, direction: config.Ingress},
+		{name: "netobserv_node_egress_packets_total", metricType: v1.MetricTypeCounter, valueField: fields.Packets, direction: config.Egress},
+		{name: "netobserv_namespace_flows_total", metricType: v1.MetricTypeCounter, valueField: constants.MetricTypeFlows, direction: config.AnyDirection},
+		{name: "netobserv_namespace_drop_bytes_total", metricType: v1.MetricTypeCounter, valueField: fields.PktDropBytes, direction: config.AnyDirection},
+		{name: "netobserv_workload_drop_packets_total", metricType: v1.MetricTypeCounter, valueField: fields.PktDropPackets, direction: config.AnyDirection},
+		{name: "netobserv_node_rtt_seconds", metricType: v1.MetricTypeHistogram, valueField: fields.TimeFlowRTT, direction: config.AnyDirection},
+		{name: "netobserv_namespace_dns_latency_seconds", metricType: v1.MetricTypeHistogram, valueField: fields.DNSLatency, direction: config.AnyDirection},
+	} {
+		m, ok := inferMetric(tc.name, tc.metricType)
+		require.True(t, ok, tc.name)
+		assert.Equal(t, tc.valueField, m.ValueField, tc.name)
+		assert.Equal(t, tc.direction, m.Direction, tc.name)
+		assert.True(t, m.Enabled, tc.name)
+	}
+
+	// unknown names or types
+	_, ok := inferMetric("netobserv_agent_evictions_total", v1.MetricTypeCounter)
+	assert.False(t, ok)
+	_, ok = inferMetric("netobserv_workload_ingress_bytes_total", v1.MetricTypeGauge)
+	assert.False(t, ok)
+}
+
+func TestMergeMetrics(t *testing.T) {
+	configured := []config.MetricInfo{
+		{Enabled: false, Name: "netobserv_node_egress_bytes_total", ValueField: "Bytes", Direction: config.Egress, Labels: []string{"SrcK8S_HostName"}},
+		{Enabled: true, Name: "netobserv_node_ingress_bytes_total", ValueField: "Bytes", Direction: config.Ingress, Labels: []string{"SrcK8S_HostName"}},
+		{Enabled: true, Name: "netobserv_namespace_ingress_bytes_total", ValueField: "Bytes", Direction: config.Ingress, Labels: []string{"SrcK8S_Namespace", "DstK8S_Namespace"}},
+	}
+	discovered := []config.MetricInfo{
+		{Enabled: true, Name: "netobserv_node_egress_bytes_total", ValueField: "Bytes", Direction: config.Egress, Labels: []string{"DstK8S_HostName", "SrcK8S_HostName"}},
+		{Enabled: true, Name: "netobserv_namespace_ingress_bytes_total", ValueField: "Bytes", Direction: config.Ingress, Labels: []string{"DstK8S_Namespace", "K8S_ClusterName"}},
+		{Enabled: true, Name: "netobserv_namespace_flows_total", ValueField: "Flows", Direction: config.AnyDirection, Labels: []string{"SrcK8S_Namespace", "DstK8S_Namespace"}},
+	}
+	assert.Equal(t, []config.MetricInfo{
+		// configured metrics stay disabled, but get the labels of their series, after the configured ones
+		{Enabled: false, Name: "netobserv_node_egress_bytes_total", ValueField: "Bytes", Direction: config.Egress, Labels: []string{"SrcK8S_HostName", "DstK8S_HostName"}},
+		{Enabled: true, Name: "netobserv_node_ingress_bytes_total", ValueField: "Bytes", Direction: config.Ingress, Labels: []string{"SrcK8S_HostName"}},
+		// configured labels missing from the series are kept
+		{Enabled: true, Name: "netobserv_namespace_ingress_bytes_total", ValueField: "Bytes", Direction: config.Ingress, Labels: []string{"SrcK8S_Namespace", "DstK8S_Namespace", "K8S_ClusterName"}},
+		{Enabled: true, Name: "netobserv_namespace_flows_total", ValueField: "Flows", Direction: config.AnyDirection, Labels: []string{"SrcK8S_Namespace", "DstK8S_Namespace"}},
+	}, mergeMetrics(configured, discovered))
+	assert.Equal(t, []string{"SrcK8S_HostName"}, configured[0].Labels, "configured metrics must not be modified")
+}
+
+func TestInventory_Refresh(t *testing.T) {
+	promSvc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		var body string
+		switch r.URL.Path {
+		case "/api/v1/metadata":
+			body = `{
+				"netobserv_workload_ingress_bytes_total": [{"type": "counter", "help": "", "unit": ""}],
+				"netobserv_namespace_dns_latency_seconds": [{"type": "histogram", "help": "", "unit": ""}],
+				"netobserv_agent_evictions_total": [{"type": "counter", "help": "", "unit": ""}],
+				"netobserv_ingest_flows_total": [{"type": "counter", "help": "", "unit": ""}],
+				"up": [{"type": "gauge", "help": "", "unit": ""}]
+			}`
+		case "/api/v1/labels":
+			switch r.Form.Get("match[]") {
+			case "netobserv_workload_ingress_bytes_total":
+				body = `["DstK8S_Namespace","DstK8S_OwnerName","SrcK8S_Namespace","SrcK8S_OwnerName","__name__","job"]`
+			case "netobserv_namespace_dns_latency_seconds_count":
+				body = `["DstK8S_Namespace","SrcK8S_Namespace","__name__"]`
+			default:
+				body = `["__name__","job"]`
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":` + body + `}`))
+	}))
+	defer promSvc.Close()
+	cl, err := api.NewClient(api.Config{Address: promSvc.URL})
+	require.NoError(t, err)
+
+	inv := NewInventory(&config.Prometheus{Metrics: []config.MetricInfo{
+		{Enabled: true, Name: "netobserv_node_egress_bytes_total", ValueField: "Bytes", Direction: config.Egress, Labels: []string{"SrcK8S_HostName", "DstK8S_HostName"}},
+	}})
+	search := inv.Search([]string{"SrcK8S_OwnerName", "DstK8S_OwnerName"}, "Bytes")
+	assert.Empty(t, search.Found)
+
+	inv.refresh(context.Background(), cl)
+
+	search = inv.Search([]string{"SrcK8S_OwnerName", "DstK8S_OwnerName"}, "Bytes")
+	assert.Equal(t, []string{"netobserv_workload_ingress_bytes_total"}, search.Found)
+	search = inv.Search([]string{"SrcK8S_Namespace"}, "DnsFlows")
+	assert.Equal(t, []string{"netobserv_namespace_dns_latency_seconds_count"}, search.Found)
+	// the configured metric is kept, as it's not found in Prometheus
+	search = inv.Search([]string{"SrcK8S_HostName"}, "Bytes")
+	assert.Equal(t, []string{"netobserv_node_egress_bytes_total"}, search.Found)
+	assert.Equal(t, []string{
+		"DstK8S_HostName",
+		"DstK8S_Namespace",
+		"DstK8S_OwnerName",
+		"SrcK8S_HostName",
+		"SrcK8S_Namespace",
+		"SrcK8S_OwnerName",
+		"job",
+	}, inv.EnabledLabels())
+}
@@ -3,6 +3,7 @@ package prometheus
 import (
 	"slices"
 	"strings"
+	"sync"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/config"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
@@ -16,25 +17,47 @@ var (
 )
 
 type Inventory struct {
-	metrics []config.MetricInfo
+	// configured holds the metrics of the configuration, merged with the discovered ones on refresh
+	configured []config.MetricInfo
+	mu         sync.RWMutex
+	metrics    []config.MetricInfo
 }
 
 func NewInventory(cfg *config.Prometheus) *Inventory {
-	var toAppend []config.MetricInfo
 	for i := range cfg.Metrics {
 		// Set default direction to Any if unset
 		if cfg.Metrics[i].Direction == "" {
 			cfg.Metrics[i].Direction = config.AnyDirection
 		}
-		// Add DNS counter(s)
-		if strings.Contains(cfg.Metrics[i].Name, "_dns_latency_seconds") {
-			cpy := cfg.Metrics[i]
+	}
+	return &Inventory{configured: cfg.Metrics, metrics: withDNSCounters(cfg.Metrics)}
+}
+
+// withDNSCounters returns the metrics with the counters of DNS latency histograms, counting DNS flows
+func withDNSCounters(metrics []config.MetricInfo) []config.MetricInfo {
+	var toAppend []config.MetricInfo
+	for i := range metrics {
+		if strings.Contains(metrics[i].Name, "_dns_latency_seconds") {
+			cpy := metrics[i]
 			cpy.Name += "_count"
 			cpy.ValueField = constants.MetricTypeDNSFlows
 			toAppend = append(toAppend, cpy)
 		}
 	}
-	return &Inventory{metrics: append(cfg.Metrics, toAppend...)}
+	return append(slices.Clip(metrics), toAppend...)
+}
+
+// getMetrics returns the current metrics. The slice is replaced, never modified, on refresh
+func (i *Inventory) getMetrics() []config.MetricInfo {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.metrics
+}
+
+func (i *Inventory) setMetrics(metrics []config.MetricInfo) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.metrics = metrics
 }
 
 type SearchResult struct {
@@ -86,7 +109,7 @@ func (i *Inventory) searchWithDir(neededLabels []string, valueField string, dir
 			valueField = fields.PktDropPackets
 		}
 	}
-	for _, m := range i.metrics {
+	for _, m := range i.getMetrics() {
 		match, missingLabels := checkMatch(&m, neededLabels, valueField, dir)
 		if match {
 			if m.Enabled {
@@ -127,7 +150,7 @@ func checkMatch(metric *config.MetricInfo, neededLabels []string, valueField str
 }
 
 func (i *Inventory) LabelExists(label string) bool {
-	for _, m := range i.metrics {
+	for _, m := range i.getMetrics() {
 		if slices.Contains(m.Labels, label) {
 			return true
 		}
@@ -135,21 +158,115 @@ func (i *Inventory) LabelExists(label string) bool {
 	return false
 }
 
-// FiltersToLabels converts filters to labels (extracting keys) and direction, checking for any unsupported filters. If unsupported, returns a reason as the second value
-func FiltersToLabels(filts filters.SingleQuery) ([]string, string) {
+// valueFilterFields are the value fields that can be compared after aggregation, the filter being applied to the
+// resulting latency in milliseconds rather than to individual flows
+var valueFilterFields = []string{
+	fields.DNSLatency,
+}
+
+// counterFields are the value fields of counters: queries return per-second rates, that can't be compared with the
+// values of individual flows. Comparisons on these fields are left to Loki
+var counterFields = []string{
+	fields.Bytes,
+	fields.Packets,
+	fields.PktDropBytes,
+	fields.PktDropPackets,
+}
+
+// SplitValueFilters separates the numeric comparisons on the queried value field, which are applied to the aggregated
+// value in promQL, from the other filters, which are applied on labels
+func SplitValueFilters(filts filters.SingleQuery, valueField string) (filters.SingleQuery, filters.SingleQuery) {
+	if !slices.Contains(valueFilterFields, valueField) {
+		return filts, nil
+	}
+	var labelFilters, valueFilters filters.SingleQuery
+	for _, m := range filts {
+		if m.Key == valueField && m.IsComparison() && filters.IsValidNumber(m.Values) {
+			valueFilters = append(valueFilters, m)
+		} else {
+			labelFilters = append(labelFilters, m)
+		}
+	}
+	return labelFilters, valueFilters
+}
+
+// FiltersToLabels converts filters to labels (extracting keys) and direction, checking for any unsupported filters. If unsupported, returns a reason as the second value.
+// The Loki configuration gives the types of the filtered fields.
+func FiltersToLabels(cfg *config.Loki, filts filters.SingleQuery) ([]string, string) {
 	var labelsNeeded []string
 	for _, m := range filts {
-		if m.MoreThanOrEqual {
-			// Not relevant/supported in promQL
-			return nil, "MoreThan not supported in promQL"
+		if m.IsComparison() {
+			if slices.Contains(counterFields, m.Key) {
+				return nil, "Numeric comparison on " + m.Key + " is not supported in promQL, as metrics values are per-second rates rather than per-flow values"
+			}
+			// Only value filters on the queried metric are supported, see SplitValueFilters
+			return nil, "Numeric comparison on " + m.Key + " is only supported on the queried metric value in promQL"
 		}
 		if m.Key == fields.FlowDirection {
 			// Ignore direction. Shouldn't be available in frontend filters anyway.
 			continue
 		}
+		if !filters.IsValidKey(m.Key) {
+			return nil, "Invalid label name in promQL: " + m.Key
+		}
+		if cfg.IsIPField(m.Key) && !m.Regex {
+			if _, ok := m.ToIPLabelFilter(); !ok {
+				return nil, "IP filter on " + m.Key + " is not supported in promQL, only IP addresses and CIDRs are"
+			}
+		}
 		if !slices.Contains(labelsNeeded, m.Key) {
 			labelsNeeded = append(labelsNeeded, m.Key)
 		}
 	}
 	return labelsNeeded, ""
 }
+
+// CheckFilter tells whether a single filter can be served by any of the enabled metrics. If not, returns a reason.
+// Whether a whole query can be served also depends on the other filters and the aggregations, see Search
+func (i *Inventory) CheckFilter(cfg *config.Loki, m filters.Match) string {
+	if _, valueFilters := SplitValueFilters(filters.SingleQuery{m}, m.Key); len(valueFilters) > 0 {
+		metrics := i.getMetrics()
+		for j := range metrics {
+			if metrics[j].Enabled && metrics[j].ValueField == m.Key {
+				return ""
+			}
+		}
+		return "No enabled metric has " + m.Key + " as value"
+	}
+	labels, unsupportedReason := FiltersToLabels(cfg, filters.SingleQuery{m})
+	if unsupportedReason != "" {
+		return unsupportedReason
+	}
+	for _, label := range labels {
+		if !i.enabledLabelExists(label) {
+			return "No enabled metric has the label " + label
+		}
+	}
+	return ""
+}
+
+func (i *Inventory) enabledLabelExists(label string) bool {
+	metrics := i.getMetrics()
+	for j := range metrics {
+		if metrics[j].Enabled && slices.Contains(metrics[j].Labels, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnabledLabels returns the labels of the enabled metrics, sorted
+func (i *Inventory) EnabledLabels() []string {
+	labels := []string{}
+	for _, m := range i.getMetrics() {
+		if m.Enabled {
+			for _, l := range m.Labels {
+				if !slices.Contains(labels, l) {
+					labels = append(labels, l)
+				}
+			}
+		}
+	}
+	slices.Sort(labels)
+	return labels
+}
@@ -1,9 +1,12 @@
 package prometheus
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/netobserv/network-observability-console-plugin/pkg/config"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -61,6 +64,22 @@ var configuredMetrics = []config.MetricInfo{
 	},
 }
 
+func TestInventory_SearchScopes(t *testing.T) {
+	inv := NewInventory(&config.Prometheus{Metrics: configuredMetrics})
+	scopes := append(
+		slices.Clone(config.DefaultScopes),
+		config.Scope{Name: "subnet", Labels: []config.ScopeLabel{{Src: "SrcSubnetLabel", Dst: "DstSubnetLabel"}}},
+	)
+	labels, _ := GetLabelsAndFilter(scopes, "subnet", "namespaces")
+	search := inv.Search(labels, "TimeFlowRttNs")
+	assert.Empty(t, search.Found)
+	assert.Equal(t, []string{"netobserv_workload_rtt_seconds"}, search.Candidates)
+
+	search = inv.Search(labels, "Bytes")
+	assert.Empty(t, search.Found)
+	assert.Equal(t, []string{"SrcSubnetLabel", "DstSubnetLabel"}, search.MissingLabels)
+}
+
 func TestInventory_Search(t *testing.T) {
 	inv := NewInventory(&config.Prometheus{Metrics: configuredMetrics})
 	// Search bytes metrics
@@ -104,3 +123,61 @@ func TestInventory_Search_MissingLabels(t *testing.T) {
 	search := inv.Search([]string{"SrcK8S_Namespace", "DstK8S_Namespace", "SrcK8S_HostName"}, "Bytes")
 	assert.Equal(t, []string{"SrcK8S_HostName"}, search.MissingLabels)
 }
+
+func TestSplitValueFilters(t *testing.T) {
+	f := filters.SingleQuery{
+		filters.NewMatch(fields.SrcNamespace, `"a"`),
+		filters.NewMoreThanOrEqualMatch(fields.DNSLatency, "100"),
+		filters.NewLessThanOrEqualMatch(fields.SrcPort, "1024"),
+	}
+	labelFilters, valueFilters := SplitValueFilters(f, fields.DNSLatency)
+	assert.Equal(t, filters.SingleQuery{f[0], f[2]}, labelFilters)
+	assert.Equal(t, filters.SingleQuery{f[1]}, valueFilters)
+
+	// comparisons on other fields than the queried value field are not supported
+	_, reason := FiltersToLabels(&config.Loki{}, labelFilters)
+	assert.Equal(t, "Numeric comparison on SrcPort is only supported on the queried metric value in promQL", reason)
+
+	// RTT is converted to milliseconds in promQL, so it can't be compared
+	labelFilters, valueFilters = SplitValueFilters(filters.SingleQuery{filters.NewMoreThanOrEqualMatch(fields.TimeFlowRTT, "100")}, fields.TimeFlowRTT)
+	assert.Len(t, labelFilters, 1)
+	assert.Empty(t, valueFilters)
+
+	// counters are queried as per-second rates, that can't be compared with the bytes of flows
+	labelFilters, valueFilters = SplitValueFilters(filters.SingleQuery{filters.NewMoreThanOrEqualMatch(fields.Bytes, "100")}, fields.Bytes)
+	assert.Empty(t, valueFilters)
+	_, reason = FiltersToLabels(&config.Loki{}, labelFilters)
+	assert.Equal(t, "Numeric comparison on Bytes is not supported in promQL, as metrics values are per-second rates rather than per-flow values", reason)
+}
+
+func TestFiltersToLabels_IP(t *testing.T) {
+	labels, reason := FiltersToLabels(&config.Loki{}, filters.SingleQuery{filters.NewMatch(fields.SrcAddr, "10.0.0.0/8,fe80::1")})
+	assert.Empty(t, reason)
+	assert.Equal(t, []string{fields.SrcAddr}, labels)
+
+	_, reason = FiltersToLabels(&config.Loki{}, filters.SingleQuery{filters.NewMatch(fields.DstAddr, "2001:db8::/32")})
+	assert.Empty(t, reason)
+
+	_, reason = FiltersToLabels(&config.Loki{}, filters.SingleQuery{filters.NewMatch(fields.DstAddr, "10.0.0.1-10.0.0.5")})
+	assert.Equal(t, "IP filter on DstAddr is not supported in promQL, only IP addresses and CIDRs are", reason)
+
+	// configured field types take precedence over the built-in ones
+	cfg := config.Loki{Fields: []config.FieldConfig{
+		{Name: "XlatSrcAddr", Type: config.FieldTypeIP},
+		{Name: fields.DstAddr, Type: config.FieldTypeString},
+	}}
+	_, reason = FiltersToLabels(&cfg, filters.SingleQuery{filters.NewMatch("XlatSrcAddr", "10.0.0.1-10.0.0.5")})
+	assert.Equal(t, "IP filter on XlatSrcAddr is not supported in promQL, only IP addresses and CIDRs are", reason)
+	_, reason = FiltersToLabels(&cfg, filters.SingleQuery{filters.NewMatch(fields.DstAddr, "10.0.0.1-10.0.0.5")})
+	assert.Empty(t, reason)
+}
+
+func TestCheckFilter(t *testing.T) {
+	inv := NewInventory(&config.Prometheus{Metrics: configuredMetrics})
+
+	assert.Empty(t, inv.CheckFilter(&config.Loki{}, filters.NewMatch(fields.SrcNamespace, `"ns"`)))
+	assert.Equal(t, "Numeric comparison on Bytes is not supported in promQL, as metrics values are per-second rates rather than per-flow values", inv.CheckFilter(&config.Loki{}, filters.NewMoreThanOrEqualMatch(fields.Bytes, "100")))
+	assert.Equal(t, "No enabled metric has DnsLatencyMs as value", inv.CheckFilter(&config.Loki{}, filters.NewMoreThanOrEqualMatch(fields.DNSLatency, "100")))
+	assert.Equal(t, "No enabled metric has the label SrcAddr", inv.CheckFilter(&config.Loki{}, filters.NewMatch(fields.SrcAddr, "10.0.0.1")))
+	assert.Equal(t, "Numeric comparison on SrcPort is only supported on the queried metric value in promQL", inv.CheckFilter(&config.Loki{}, filters.NewMoreThanOrEqualMatch(fields.SrcPort, "100")))
+}
@@ -3,6 +3,7 @@ package prometheus
 import (
 	"strings"
 
+	"github.com/netobserv/network-observability-console-plugin/pkg/config"
 	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
 	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
@@ -10,6 +11,7 @@ import (
 )
 
 type QueryBuilder struct {
+	config    *config.Loki
 	in        *loki.TopologyInput
 	filters   filters.SingleQuery
 	orMetrics []string
@@ -21,8 +23,10 @@ type Query struct {
 	PromQL string
 }
 
-func NewQuery(in *loki.TopologyInput, qr *v1.Range, filters filters.SingleQuery, orMetrics []string) *QueryBuilder {
+// NewQuery creates a promQL query builder. The Loki configuration gives the types of the filtered fields
+func NewQuery(cfg *config.Loki, in *loki.TopologyInput, qr *v1.Range, filters filters.SingleQuery, orMetrics []string) *QueryBuilder {
 	return &QueryBuilder{
+		config:    cfg,
 		in:        in,
 		filters:   filters,
 		orMetrics: orMetrics,
@@ -31,9 +35,10 @@ func NewQuery(in *loki.TopologyInput, qr *v1.Range, filters filters.SingleQuery,
 }
 
 func (q *QueryBuilder) Build() Query {
-	labels, extraFilter := GetLabelsAndFilter(q.in.Aggregate, q.in.Groups)
-	if extraFilter != "" {
-		q.filters = append(q.filters, filters.NewNotMatch(extraFilter, `""`))
+	labelFilters, valueFilters := SplitValueFilters(q.filters, q.in.DataField)
+	labels, extraFilters := GetLabelsAndFilter(q.in.Scopes, q.in.Aggregate, q.in.Groups)
+	for _, extraFilter := range extraFilters {
+		labelFilters = append(labelFilters, filters.NewNotMatch(extraFilter, `""`))
 	}
 	groupBy := strings.Join(labels, ",")
 
@@ -45,11 +50,7 @@ func (q *QueryBuilder) Build() Query {
 		isHisto = true
 	}
 	if isHisto {
-		if q.in.MetricFunction == constants.MetricFunctionP90 {
-			quantile = "0.9"
-		} else if q.in.MetricFunction == constants.MetricFunctionP99 {
-			quantile = "0.99"
-		}
+		quantile, _ = loki.Quantile(q.in.MetricFunction)
 	}
 
 	// Build metrics query like:
@@ -59,12 +60,16 @@ func (q *QueryBuilder) Build() Query {
 	//				<function>(
 	//					<metric>{<filters>}[<interval>]
 	//				) <factor>
-	//			)
+	//			) <value filters>
 	//		)
 	//		&<query params>&step=<step>
+	//
+	// Or, for the total, replacing topk | bottomk(<k>, by sum(
 	sb := strings.Builder{}
 
-	if q.in.Top != "" {
+	if q.in.Total {
+		sb.WriteString("sum(")
+	} else if q.in.Top != "" {
 		if q.in.MetricFunction == constants.MetricFunctionMin {
 			sb.WriteString("bottomk")
 		} else {
@@ -75,16 +80,15 @@ func (q *QueryBuilder) Build() Query {
 		sb.WriteRune(',')
 	}
 
-	for orIdx, metric := range q.orMetrics {
-		if orIdx > 0 {
-			sb.WriteString(" or ")
-		}
+	exprs := make([]string, 0, len(q.orMetrics))
+	for _, metric := range q.orMetrics {
+		msb := strings.Builder{}
 
 		if isHisto && quantile != "" {
 			// use histogram_quantile
-			sb.WriteString("histogram_quantile(")
-			sb.WriteString(quantile)
-			sb.WriteRune(',')
+			msb.WriteString("histogram_quantile(")
+			msb.WriteString(quantile)
+			msb.WriteRune(',')
 			if groupBy == "" {
 				groupBy = "le"
 			} else {
@@ -92,38 +96,47 @@ func (q *QueryBuilder) Build() Query {
 			}
 		}
 
-		sb.WriteString("sum")
+		msb.WriteString("sum")
 		if groupBy != "" {
-			sb.WriteString(" by(")
-			sb.WriteString(groupBy)
-			sb.WriteRune(')')
+			msb.WriteString(" by(")
+			msb.WriteString(groupBy)
+			msb.WriteRune(')')
 		}
 
-		sb.WriteRune('(')
+		msb.WriteRune('(')
 		if isHisto {
 			if quantile == "" {
 				// histogram average: sum / count
-				appendRate(&sb, metric+"_sum", q.filters, q.in.RateInterval)
-				sb.WriteRune('/')
-				appendRate(&sb, metric+"_count", q.filters, q.in.RateInterval)
+				appendRate(q.config, &msb, metric+"_sum", labelFilters, q.in.RateInterval)
+				msb.WriteRune('/')
+				appendRate(q.config, &msb, metric+"_count", labelFilters, q.in.RateInterval)
 			} else {
-				appendRate(&sb, metric+"_bucket", q.filters, q.in.RateInterval)
+				appendRate(q.config, &msb, metric+"_bucket", labelFilters, q.in.RateInterval)
 			}
 		} else {
-			appendRate(&sb, metric, q.filters, q.in.RateInterval)
+			appendRate(q.config, &msb, metric, labelFilters, q.in.RateInterval)
 		}
-		sb.WriteRune(')') // closes sum(...
+		msb.WriteRune(')') // closes sum(...
 		if isHisto && quantile != "" {
-			sb.WriteRune(')') // closes histogram_quantile(...
+			msb.WriteRune(')') // closes histogram_quantile(...
 		}
 
 		if len(factor) > 0 {
-			sb.WriteString(factor)
+			msb.WriteString(factor)
 		}
+		appendValueFilters(&msb, valueFilters)
+		exprs = append(exprs, msb.String())
 	}
 
-	if q.in.Top != "" {
-		sb.WriteRune(')') // closes topk(...
+	if q.in.DataField == constants.MetricTypeBytes || q.in.DataField == constants.MetricTypePackets {
+		appendReportersMerge(&sb, exprs, q.in.ReportersMerge)
+	} else {
+		// other values, such as flow counts or latencies, are not merged
+		sb.WriteString(strings.Join(exprs, " or "))
+	}
+
+	if q.in.Total || q.in.Top != "" {
+		sb.WriteRune(')') // closes topk(... or sum(...
 	}
 
 	return Query{
@@ -132,20 +145,55 @@ func (q *QueryBuilder) Build() Query {
 	}
 }
 
-func appendRate(sb *strings.Builder, metric string, filters filters.SingleQuery, interval string) {
+// appendReportersMerge combines the expressions of the ingress and egress metrics, so that flows reported by both
+// are counted once according to the strategy. Other expressions are OR'ed, with priority given to the first ones
+func appendReportersMerge(sb *strings.Builder, exprs []string, strategy constants.ReportersMerge) {
+	if len(exprs) != 2 {
+		sb.WriteString(strings.Join(exprs, " or "))
+		return
+	}
+	ingress, egress := exprs[0], exprs[1]
+	switch strategy {
+	case constants.ReportersMergeMax:
+		sb.WriteString("(" + ingress + ")>=(" + egress + ") or (" + egress + ") or (" + ingress + ")")
+		return
+	case constants.ReportersMergeNone:
+		sb.WriteString("(" + ingress + ")+(" + egress + ") or (" + ingress + ") or (" + egress + ")")
+		return
+	case constants.ReportersMergeEgress:
+		sb.WriteString(egress + " or " + ingress)
+		return
+	case constants.ReportersMergeIngress:
+	}
+	sb.WriteString(ingress + " or " + egress)
+}
+
+func appendRate(cfg *config.Loki, sb *strings.Builder, metric string, filters filters.SingleQuery, interval string) {
 	sb.WriteString("rate(")
-	appendFilteredMetric(sb, metric, filters)
+	appendFilteredMetric(cfg, sb, metric, filters)
 	sb.WriteRune('[')
 	sb.WriteString(interval)
 	sb.WriteString("])")
 }
 
-func appendFilteredMetric(sb *strings.Builder, metric string, filters filters.SingleQuery) {
+// appendValueFilters filters the aggregated values, e.g. "sum(...) >= 100"
+func appendValueFilters(sb *strings.Builder, filters filters.SingleQuery) {
+	for _, filter := range filters {
+		if filter.MoreThanOrEqual {
+			sb.WriteString(" >= ")
+		} else {
+			sb.WriteString(" <= ")
+		}
+		sb.WriteString(filter.Values)
+	}
+}
+
+func appendFilteredMetric(cfg *config.Loki, sb *strings.Builder, metric string, filters filters.SingleQuery) {
 	sb.WriteString(metric)
 	sb.WriteRune('{')
 	first := true
-	for _, filter := range filters {
-		if lf, ok := filter.ToLabelFilter(); ok {
+	for i := range filters {
+		if lf, ok := toLabelFilter(cfg, &filters[i]); ok {
 			if !first {
 				sb.WriteRune(',')
 			}
@@ -156,16 +204,31 @@ func appendFilteredMetric(sb *strings.Builder, metric string, filters filters.Si
 	sb.WriteRune('}')
 }
 
-func GetLabelsAndFilter(aggregate, groups string) ([]string, string) {
-	if aggregate == "app" {
-		// ignore app: it's a noop aggregation needed for Loki, not relevant in promQL
-		return nil, ""
+// toLabelFilter converts a filter into a promQL label matcher. Filters on IP fields are converted to regexes,
+// as promQL has no equivalent for the LogQL ip() function, unless they are regexes already
+func toLabelFilter(cfg *config.Loki, filter *filters.Match) (filters.LabelFilter, bool) {
+	if cfg.IsIPField(filter.Key) && !filter.Regex {
+		return filter.ToIPLabelFilter()
+	}
+	return filter.ToLabelFilter()
+}
+
+func GetLabelsAndFilter(scopes []config.Scope, aggregate, groups string) ([]string, []string) {
+	// ignore app: it's a noop aggregation needed for Loki, not relevant in promQL
+	var aggFields []string
+	for _, agg := range loki.AggregateFields(aggregate) {
+		if agg != "app" {
+			aggFields = append(aggFields, agg)
+		}
+	}
+	if len(aggFields) == 0 {
+		return nil, nil
 	}
-	return loki.GetLabelsAndFilter(aggregate, groups)
+	return loki.GetLabelsAndFilter(scopes, strings.Join(aggFields, ","), groups)
 }
 
-func QueryFilters(metric string, filters filters.SingleQuery) string {
+func QueryFilters(cfg *config.Loki, metric string, filters filters.SingleQuery) string {
 	sb := strings.Builder{}
-	appendFilteredMetric(&sb, metric, filters)
+	appendFilteredMetric(cfg, &sb, metric, filters)
 	return sb.String()
 }
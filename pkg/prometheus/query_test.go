@@ -1,15 +1,20 @@
 package prometheus
 
 import (
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/netobserv/network-observability-console-plugin/pkg/config"
 	"github.com/netobserv/network-observability-console-plugin/pkg/loki"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model/filters"
 	"github.com/netobserv/network-observability-console-plugin/pkg/utils/constants"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var qr = v1.Range{Start: time.Now().Add(-15 * time.Minute), End: time.Now(), Step: 30 * time.Second}
@@ -25,7 +30,7 @@ func TestBuildQuery_PromQLSimpleRateIgnoreApp(t *testing.T) {
 		Aggregate:      "app",
 	}
 	f := filters.SingleQuery{}
-	q := NewQuery(&in, &qr, f, []string{"my_metric"})
+	q := NewQuery(&config.Loki{}, &in, &qr, f, []string{"my_metric"})
 	result := q.Build()
 	assert.Equal(
 		t,
@@ -45,7 +50,7 @@ func TestBuildQuery_PromQLSimpleRateNoFilter(t *testing.T) {
 		Aggregate:      "namespace",
 	}
 	f := filters.SingleQuery{}
-	q := NewQuery(&in, &qr, f, []string{"my_metric"})
+	q := NewQuery(&config.Loki{}, &in, &qr, f, []string{"my_metric"})
 	result := q.Build()
 	assert.Equal(
 		t,
@@ -70,7 +75,7 @@ func TestBuildQuery_PromQLSimpleRateAndFilter(t *testing.T) {
 			Values: `"a"`,
 		},
 	}
-	q := NewQuery(&in, &qr, f, []string{"my_metric"})
+	q := NewQuery(&config.Loki{}, &in, &qr, f, []string{"my_metric"})
 	result := q.Build()
 	assert.Equal(
 		t,
@@ -95,7 +100,7 @@ func TestBuildQuery_PromQLRateMultiFilter(t *testing.T) {
 			Values: `"a","b"`,
 		},
 	}
-	q := NewQuery(&in, &qr, f, []string{"my_metric"})
+	q := NewQuery(&config.Loki{}, &in, &qr, f, []string{"my_metric"})
 	result := q.Build()
 	assert.Equal(
 		t,
@@ -120,7 +125,7 @@ func TestBuildQuery_PromQLHistogramAverage(t *testing.T) {
 			Values: `"a"`,
 		},
 	}
-	q := NewQuery(&in, &qr, f, []string{"my_metric"})
+	q := NewQuery(&config.Loki{}, &in, &qr, f, []string{"my_metric"})
 	result := q.Build()
 	assert.Equal(
 		t,
@@ -145,7 +150,7 @@ func TestBuildQuery_PromQLHistogramP99(t *testing.T) {
 			Values: `"a"`,
 		},
 	}
-	q := NewQuery(&in, &qr, f, []string{"my_metric"})
+	q := NewQuery(&config.Loki{}, &in, &qr, f, []string{"my_metric"})
 	result := q.Build()
 	assert.Equal(
 		t,
@@ -154,6 +159,137 @@ func TestBuildQuery_PromQLHistogramP99(t *testing.T) {
 	)
 }
 
+func TestBuildQuery_PromQLMultiFieldAggregate(t *testing.T) {
+	in := loki.TopologyInput{
+		Top:            "50",
+		RateInterval:   "2m",
+		DataField:      "Bytes",
+		MetricFunction: constants.MetricFunctionRate,
+		RecordType:     constants.RecordTypeLog,
+		DataSource:     constants.DataSourceAuto,
+		Aggregate:      "app,namespace,DstPort,Proto",
+	}
+	q := NewQuery(&config.Loki{}, &in, &qr, nil, []string{"my_metric"})
+	result := q.Build()
+	assert.Equal(
+		t,
+		`topk(50,sum by(SrcK8S_Namespace,DstK8S_Namespace,DstPort,Proto)(rate(my_metric{DstPort!="",Proto!=""}[2m])))`,
+		result.PromQL,
+	)
+}
+
+func TestBuildQuery_PromQLScopes(t *testing.T) {
+	in := loki.TopologyInput{
+		Top:            "50",
+		RateInterval:   "2m",
+		DataField:      "Bytes",
+		MetricFunction: constants.MetricFunctionRate,
+		RecordType:     constants.RecordTypeLog,
+		DataSource:     constants.DataSourceAuto,
+		Aggregate:      "subnet",
+		Groups:         "namespaces",
+		Scopes: append(
+			slices.Clone(config.DefaultScopes),
+			config.Scope{Name: "subnet", Labels: []config.ScopeLabel{{Src: "SrcSubnetLabel", Dst: "DstSubnetLabel"}}},
+		),
+	}
+	q := NewQuery(&config.Loki{}, &in, &qr, nil, []string{"my_metric"})
+	result := q.Build()
+	assert.Equal(
+		t,
+		`topk(50,sum by(SrcSubnetLabel,DstSubnetLabel,SrcK8S_Namespace,DstK8S_Namespace)(rate(my_metric{}[2m])))`,
+		result.PromQL,
+	)
+}
+
+func TestBuildQuery_PromQLTotal(t *testing.T) {
+	in := loki.TopologyInput{
+		Top:            "50",
+		RateInterval:   "2m",
+		DataField:      "Bytes",
+		MetricFunction: constants.MetricFunctionRate,
+		RecordType:     constants.RecordTypeLog,
+		DataSource:     constants.DataSourceAuto,
+		Aggregate:      "namespace",
+		Total:          true,
+	}
+	q := NewQuery(&config.Loki{}, &in, &qr, nil, []string{"my_metric", "my_other_metric"})
+	result := q.Build()
+	assert.Equal(
+		t,
+		`sum(sum by(SrcK8S_Namespace,DstK8S_Namespace)(rate(my_metric{}[2m])) or sum by(SrcK8S_Namespace,DstK8S_Namespace)(rate(my_other_metric{}[2m])))`,
+		result.PromQL,
+	)
+}
+
+func TestBuildQuery_PromQLReportersMerge(t *testing.T) {
+	ingress := "sum by(SrcK8S_Namespace,DstK8S_Namespace)(rate(my_ingress_metric{}[2m]))"
+	egress := "sum by(SrcK8S_Namespace,DstK8S_Namespace)(rate(my_egress_metric{}[2m]))"
+	for strategy, expected := range map[constants.ReportersMerge]string{
+		"":                              ingress + " or " + egress,
+		constants.ReportersMergeIngress: ingress + " or " + egress,
+		constants.ReportersMergeEgress:  egress + " or " + ingress,
+		constants.ReportersMergeMax:     "(" + ingress + ")>=(" + egress + ") or (" + egress + ") or (" + ingress + ")",
+		constants.ReportersMergeNone:    "(" + ingress + ")+(" + egress + ") or (" + ingress + ") or (" + egress + ")",
+	} {
+		in := loki.TopologyInput{
+			Top:            "50",
+			RateInterval:   "2m",
+			DataField:      "Bytes",
+			MetricFunction: constants.MetricFunctionRate,
+			RecordType:     constants.RecordTypeLog,
+			DataSource:     constants.DataSourceAuto,
+			Aggregate:      "namespace",
+			ReportersMerge: strategy,
+		}
+		q := NewQuery(&config.Loki{}, &in, &qr, nil, []string{"my_ingress_metric", "my_egress_metric"})
+		result := q.Build()
+		assert.Equal(t, "topk(50,"+expected+")", result.PromQL, strategy)
+	}
+
+	// reporters are only merged for bytes and packets
+	in := loki.TopologyInput{
+		Top:            "50",
+		RateInterval:   "2m",
+		DataField:      "",
+		MetricFunction: constants.MetricFunctionRate,
+		RecordType:     constants.RecordTypeLog,
+		DataSource:     constants.DataSourceAuto,
+		Aggregate:      "namespace",
+		ReportersMerge: constants.ReportersMergeNone,
+	}
+	q := NewQuery(&config.Loki{}, &in, &qr, nil, []string{"my_ingress_metric", "my_egress_metric"})
+	assert.Equal(t, "topk(50,"+ingress+" or "+egress+")", q.Build().PromQL)
+}
+
+func TestBuildQuery_PromQLHistogramQuantiles(t *testing.T) {
+	for function, quantile := range map[constants.MetricFunction]string{
+		"p50":  "0.5",
+		"p95":  "0.95",
+		"p999": "0.999",
+		"p75":  "0.75",
+		"p5":   "0.05",
+	} {
+		in := loki.TopologyInput{
+			Top:            "50",
+			RateInterval:   "2m",
+			DataField:      "DnsLatencyMs",
+			MetricFunction: function,
+			RecordType:     constants.RecordTypeLog,
+			DataSource:     constants.DataSourceAuto,
+			Aggregate:      "namespace",
+		}
+		q := NewQuery(&config.Loki{}, &in, &qr, nil, []string{"my_metric"})
+		result := q.Build()
+		assert.Equal(
+			t,
+			`topk(50,histogram_quantile(`+quantile+`,sum by(SrcK8S_Namespace,DstK8S_Namespace,le)(rate(my_metric_bucket{}[2m])))*1000)`,
+			result.PromQL,
+			function,
+		)
+	}
+}
+
 func TestBuildQuery_PromQLByDNSResponseCode(t *testing.T) {
 	in := loki.TopologyInput{
 		Top:            "5",
@@ -165,7 +301,7 @@ func TestBuildQuery_PromQLByDNSResponseCode(t *testing.T) {
 		Aggregate:      "DnsFlagsResponseCode",
 	}
 	f := filters.SingleQuery{}
-	q := NewQuery(&in, &qr, f, []string{"netobserv_namespace_dns_latency_seconds_count"})
+	q := NewQuery(&config.Loki{}, &in, &qr, f, []string{"netobserv_namespace_dns_latency_seconds_count"})
 	result := q.Build()
 	assert.Equal(
 		t,
@@ -190,7 +326,7 @@ func TestBuildQuery_PromQLORMetrics(t *testing.T) {
 			Values: `"a"`,
 		},
 	}
-	q := NewQuery(&in, &qr, f, []string{"ingress_metric", "egress_metric"})
+	q := NewQuery(&config.Loki{}, &in, &qr, f, []string{"ingress_metric", "egress_metric"})
 	result := q.Build()
 	assert.Equal(
 		t,
@@ -199,3 +335,118 @@ func TestBuildQuery_PromQLORMetrics(t *testing.T) {
 		result.PromQL,
 	)
 }
+
+func TestBuildQuery_PromQLHistogramValueFilters(t *testing.T) {
+	in := loki.TopologyInput{
+		Top:            "50",
+		RateInterval:   "2m",
+		DataField:      "DnsLatencyMs",
+		MetricFunction: constants.MetricFunctionP99,
+		RecordType:     constants.RecordTypeLog,
+		DataSource:     constants.DataSourceAuto,
+		Aggregate:      "namespace",
+	}
+	f := filters.SingleQuery{
+		filters.NewMatch(fields.SrcNamespace, `"a"`),
+		filters.NewMoreThanOrEqualMatch(fields.DNSLatency, "5"),
+		filters.NewLessThanOrEqualMatch(fields.DNSLatency, "200.5"),
+	}
+	q := NewQuery(&config.Loki{}, &in, &qr, f, []string{"my_metric"})
+	result := q.Build()
+	assert.Equal(
+		t,
+		`topk(50,histogram_quantile(0.99,sum by(SrcK8S_Namespace,DstK8S_Namespace,le)(rate(my_metric_bucket{SrcK8S_Namespace="a"}[2m])))*1000 >= 5 <= 200.5)`,
+		result.PromQL,
+	)
+}
+
+func TestBuildQuery_PromQLIPFilters(t *testing.T) {
+	in := loki.TopologyInput{
+		Top:            "50",
+		RateInterval:   "2m",
+		DataField:      "Bytes",
+		MetricFunction: constants.MetricFunctionRate,
+		RecordType:     constants.RecordTypeLog,
+		DataSource:     constants.DataSourceAuto,
+		Aggregate:      "namespace",
+	}
+	f := filters.SingleQuery{
+		filters.NewMatch(fields.SrcAddr, "10.128.0.0/14"),
+		filters.NewNotMatch(fields.DstAddr, `"10.0.0.1"`),
+	}
+	q := NewQuery(&config.Loki{}, &in, &qr, f, []string{"my_metric"})
+	result := q.Build()
+	assert.Equal(
+		t,
+		`topk(50,sum by(SrcK8S_Namespace,DstK8S_Namespace)(rate(my_metric{SrcAddr=~"^10\\.(?:12[8-9]|13[0-1])\\.[0-9]{1,3}\\.[0-9]{1,3}$",DstAddr!~"^10\\.0\\.0\\.1$"}[2m])))`,
+		result.PromQL,
+	)
+}
+
+func TestBuildQuery_PromQLConfiguredIPFields(t *testing.T) {
+	cfg := config.Loki{Fields: []config.FieldConfig{
+		{Name: "XlatSrcAddr", Type: config.FieldTypeIP},
+		{Name: fields.SrcAddr, Type: config.FieldTypeIP},
+	}}
+	f := filters.SingleQuery{
+		filters.NewMatch("XlatSrcAddr", "10.0.0.0/8"),
+		filters.NewMatch(fields.SrcAddr, "2001:db8::/32"),
+		// fields that are not configured have their built-in type
+		filters.NewMatch("MyAddr", `"10.0.0.1"`),
+	}
+	assert.Equal(
+		t,
+		`my_metric{XlatSrcAddr=~"^10\\.[0-9]{1,3}\\.[0-9]{1,3}\\.[0-9]{1,3}$",SrcAddr=~"^(?:2001:db8:[0-9a-f:]*)$",MyAddr="10.0.0.1"}`,
+		QueryFilters(&cfg, "my_metric", f),
+	)
+}
+
+func TestBuildQuery_PromQLRegexFilters(t *testing.T) {
+	f := filters.SingleQuery{
+		filters.NewRegexMatch(fields.SrcNamespace, "kube-.*"),
+		filters.NewNotRegexMatch(fields.DstAddr, `10\\.0\\..*`),
+		{Key: fields.DstNamespace, Values: "App", CaseSensitive: true},
+	}
+	assert.Equal(
+		t,
+		`my_metric{SrcK8S_Namespace=~"kube-.*",DstAddr!~"10\\.0\\..*",DstK8S_Namespace=~".*App.*"}`,
+		QueryFilters(&config.Loki{}, "my_metric", f),
+	)
+}
+
+func TestQueryFilters_NegatedMultiValues(t *testing.T) {
+	// negated filters match none of their values, like in LogQL
+	for _, tc := range []struct {
+		name     string
+		filter   filters.Match
+		expected string
+	}{
+		{name: "exact", filter: filters.NewNotMatch(fields.SrcNamespace, `"a","b"`), expected: `SrcK8S_Namespace!~"^a$|^b$"`},
+		{name: "single exact", filter: filters.NewNotMatch(fields.SrcNamespace, `"a"`), expected: `SrcK8S_Namespace!="a"`},
+		{name: "pattern", filter: filters.NewNotMatch(fields.SrcNamespace, `a,"b*"`), expected: `SrcK8S_Namespace!~"(?i).*a.*|^b.*"`},
+		{name: "number", filter: filters.NewNotMatch(fields.Proto, `"6","17"`), expected: `Proto!~"^6$|^17$"`},
+		{name: "regex", filter: filters.NewNotRegexMatch(fields.SrcNamespace, `a.*,b.*`), expected: `SrcK8S_Namespace!~"(?:a.*)|(?:b.*)"`},
+		{name: "ip", filter: filters.NewNotMatch(fields.SrcAddr, `10.0.0.1,"10.0.0.2"`), expected: `SrcAddr!~"^10\\.0\\.0\\.1$|^10\\.0\\.0\\.2$"`},
+		{name: "ip with empty", filter: filters.NewNotMatch(fields.SrcAddr, `10.0.0.1,""`), expected: `SrcAddr!~"^10\\.0\\.0\\.1$|^$"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, "my_metric{"+tc.expected+"}", QueryFilters(&config.Loki{}, "my_metric", filters.SingleQuery{tc.filter}))
+		})
+	}
+}
+
+func FuzzQueryFilters(f *testing.F) {
+	for _, seed := range []string{"bar", `"bar"`, `"bar*"`, `my"pod`, `a\b`, `"}) or vector(1`, "ünïcode"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		result := QueryFilters(&config.Loki{}, "my_metric", filters.SingleQuery{filters.NewMatch(fields.SrcNamespace, value)})
+
+		// the value must remain a single string literal inside the label matchers
+		require.True(t, strings.HasPrefix(result, "my_metric{"+fields.SrcNamespace+"="), result)
+		remaining := strings.TrimPrefix(strings.TrimPrefix(result, "my_metric{"+fields.SrcNamespace+"="), "~")
+		literal, err := strconv.QuotedPrefix(remaining)
+		require.NoError(t, err, result)
+		assert.Equal(t, "}", strings.TrimPrefix(remaining, literal))
+	})
+}
@@ -0,0 +1,94 @@
+package prometheus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	pmod "github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultScrapeInterval is the Prometheus default, used when the configuration can't be read,
+	// e.g. behind a Thanos querier
+	DefaultScrapeInterval = time.Minute
+	scrapeIntervalTTL     = 10 * time.Minute
+	// scrapeIntervalRetryTTL is how long a failed fetch is not retried
+	scrapeIntervalRetryTTL = 30 * time.Second
+	scrapeIntervalTimeout  = 10 * time.Second
+)
+
+// ScrapeInterval reads the global scrape interval from the Prometheus configuration, and keeps it for some time
+// as it's not expected to change often
+type ScrapeInterval struct {
+	mu       sync.Mutex
+	value    time.Duration
+	expires  time.Time
+	fetching bool
+}
+
+type promConfig struct {
+	Global struct {
+		ScrapeInterval string `yaml:"scrape_interval"`
+	} `yaml:"global"`
+}
+
+// Get returns the scrape interval, fetching it when unknown or outdated. The configuration is fetched without holding
+// the lock: while it is, other callers get the outdated value if any. The fetch isn't bound to the caller's request,
+// as its result is shared, but it has its own timeout. Errors are logged, and the last value, or else the default,
+// is used until a retry shortly after
+func (s *ScrapeInterval) Get(ctx context.Context, cl api.Client) time.Duration {
+	s.mu.Lock()
+	if s.value > 0 && (s.fetching || time.Now().Before(s.expires)) {
+		value := s.value
+		s.mu.Unlock()
+		return value
+	}
+	s.fetching = true
+	s.mu.Unlock()
+
+	fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), scrapeIntervalTimeout)
+	defer cancel()
+	value, err := fetchScrapeInterval(fetchCtx, cl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetching = false
+	if err != nil {
+		if s.value == 0 {
+			s.value = DefaultScrapeInterval
+		}
+		log.WithError(err).Infof("Could not read the Prometheus scrape interval, using %s", s.value)
+		s.expires = time.Now().Add(scrapeIntervalRetryTTL)
+		return s.value
+	}
+	s.value = value
+	s.expires = time.Now().Add(scrapeIntervalTTL)
+	return value
+}
+
+func fetchScrapeInterval(ctx context.Context, cl api.Client) (time.Duration, error) {
+	result, err := v1.NewAPI(cl).Config(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return parseScrapeInterval(result.YAML)
+}
+
+func parseScrapeInterval(config string) (time.Duration, error) {
+	var cfg promConfig
+	if err := yaml.Unmarshal([]byte(config), &cfg); err != nil {
+		return 0, err
+	}
+	if cfg.Global.ScrapeInterval == "" {
+		return DefaultScrapeInterval, nil
+	}
+	d, err := pmod.ParseDuration(cfg.Global.ScrapeInterval)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(d), nil
+}
@@ -0,0 +1,116 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScrapeInterval(t *testing.T) {
+	d, err := parseScrapeInterval(`
+global:
+  scrape_interval: 30s
+  evaluation_interval: 30s
+scrape_configs:
+- job_name: netobserv
+  scrape_interval: 15s
+`)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, d)
+
+	// Prometheus default
+	d, err = parseScrapeInterval(`
+global:
+  evaluation_interval: 30s
+`)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultScrapeInterval, d)
+
+	_, err = parseScrapeInterval(`
+global:
+  scrape_interval: often
+`)
+	assert.Error(t, err)
+}
+
+// blockingClient answers the configuration queries once released
+type blockingClient struct {
+	release chan struct{}
+}
+
+func (c *blockingClient) URL(ep string, _ map[string]string) *url.URL {
+	return &url.URL{Scheme: "http", Host: "prometheus", Path: ep}
+}
+
+func (c *blockingClient) Do(_ context.Context, req *http.Request) (*http.Response, []byte, error) {
+	<-c.release
+	body := `{"status":"success","data":{"yaml":"global:\n  scrape_interval: 30s\n"}}`
+	return &http.Response{StatusCode: http.StatusOK, Request: req}, []byte(body), nil
+}
+
+func TestScrapeInterval_Get(t *testing.T) {
+	cl := &blockingClient{release: make(chan struct{})}
+	s := ScrapeInterval{value: 15 * time.Second}
+
+	// while the outdated value is being refreshed, other callers get it without waiting
+	done := make(chan time.Duration)
+	go func() {
+		done <- s.Get(context.Background(), cl)
+	}()
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.fetching
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 15*time.Second, s.Get(context.Background(), cl))
+
+	close(cl.release)
+	assert.Equal(t, 30*time.Second, <-done)
+	assert.Equal(t, 30*time.Second, s.Get(context.Background(), cl))
+}
+
+// failingClient fails the configuration queries, recording the state of their context
+type failingClient struct {
+	calls       int
+	ctxErr      error
+	hasDeadline bool
+}
+
+func (c *failingClient) URL(ep string, _ map[string]string) *url.URL {
+	return &url.URL{Scheme: "http", Host: "prometheus", Path: ep}
+}
+
+func (c *failingClient) Do(ctx context.Context, _ *http.Request) (*http.Response, []byte, error) {
+	c.calls++
+	c.ctxErr = ctx.Err()
+	_, c.hasDeadline = ctx.Deadline()
+	return nil, nil, errors.New("not found")
+}
+
+func TestScrapeInterval_GetErrors(t *testing.T) {
+	cl := &failingClient{}
+	s := ScrapeInterval{}
+
+	// the fetch is not bound to the caller's context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, DefaultScrapeInterval, s.Get(ctx, cl))
+	assert.Equal(t, 1, cl.calls)
+	assert.NoError(t, cl.ctxErr)
+	assert.True(t, cl.hasDeadline)
+
+	// a failed fetch is retried shortly after, not after the whole TTL
+	assert.Equal(t, DefaultScrapeInterval, s.Get(context.Background(), cl))
+	assert.Equal(t, 1, cl.calls)
+	s.expires = time.Now().Add(-time.Second)
+	s.value = 15 * time.Second
+	assert.Equal(t, 15*time.Second, s.Get(context.Background(), cl), "the last value is kept on errors")
+	assert.Equal(t, 2, cl.calls)
+	assert.WithinDuration(t, time.Now().Add(scrapeIntervalRetryTTL), s.expires, time.Second)
+}
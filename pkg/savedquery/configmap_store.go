@@ -0,0 +1,117 @@
+package savedquery
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/client"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxConflictRetries is the number of attempts to update the config map when it's concurrently modified
+const maxConflictRetries = 3
+
+// ConfigMapStore stores saved queries in a single config map, with one JSON-encoded query per key.
+// Config maps are limited to 1MiB, which is enough for thousands of queries.
+type ConfigMapStore struct {
+	api       client.ConfigMapAPI
+	namespace string
+	name      string
+}
+
+func NewConfigMapStore(api client.ConfigMapAPI, namespace, name string) *ConfigMapStore {
+	return &ConfigMapStore{api: api, namespace: namespace, name: name}
+}
+
+func (s *ConfigMapStore) List(ctx context.Context) ([]SavedQuery, error) {
+	cm, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queries := make(map[string]SavedQuery, len(cm.Data))
+	for id, data := range cm.Data {
+		var q SavedQuery
+		if err := json.Unmarshal([]byte(data), &q); err != nil {
+			log.Warnf("Ignoring invalid saved query %s: %v", id, err)
+			continue
+		}
+		queries[id] = q
+	}
+	return sortedQueries(queries), nil
+}
+
+func (s *ConfigMapStore) Get(ctx context.Context, id string) (*SavedQuery, error) {
+	cm, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := cm.Data[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var q SavedQuery
+	if err := json.Unmarshal([]byte(data), &q); err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+func (s *ConfigMapStore) Put(ctx context.Context, q *SavedQuery) error {
+	data, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	return s.update(ctx, func(cm *corev1.ConfigMap) error {
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[q.ID] = string(data)
+		return nil
+	})
+}
+
+func (s *ConfigMapStore) Delete(ctx context.Context, id string) error {
+	return s.update(ctx, func(cm *corev1.ConfigMap) error {
+		if _, ok := cm.Data[id]; !ok {
+			return ErrNotFound
+		}
+		delete(cm.Data, id)
+		return nil
+	})
+}
+
+// get returns the config map, or an empty one if it doesn't exist yet
+func (s *ConfigMapStore) get(ctx context.Context) (*corev1.ConfigMap, error) {
+	cm, err := s.api.GetConfigMap(ctx, s.namespace, s.name)
+	if apierrors.IsNotFound(err) {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: s.namespace, Name: s.name}}, nil
+	}
+	return cm, err
+}
+
+// update applies the change to the config map, creating it if needed. The change is applied again on conflicts.
+func (s *ConfigMapStore) update(ctx context.Context, change func(*corev1.ConfigMap) error) error {
+	var err error
+	for i := 0; i < maxConflictRetries; i++ {
+		var cm *corev1.ConfigMap
+		cm, err = s.get(ctx)
+		if err != nil {
+			return err
+		}
+		if err = change(cm); err != nil {
+			return err
+		}
+		if cm.ResourceVersion == "" {
+			_, err = s.api.CreateConfigMap(ctx, cm)
+		} else {
+			_, err = s.api.UpdateConfigMap(ctx, cm)
+		}
+		if !apierrors.IsConflict(err) && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+		log.Debugf("Saved queries config map was concurrently modified, retrying: %v", err)
+	}
+	return err
+}
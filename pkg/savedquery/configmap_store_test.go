@@ -0,0 +1,126 @@
+package savedquery
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var configMapsResource = schema.GroupResource{Resource: "configmaps"}
+
+// fakeConfigMaps is an in-memory ConfigMapAPI, checking resource versions like the API server
+type fakeConfigMaps struct {
+	items map[string]*corev1.ConfigMap
+	// conflicts is the number of next updates to reject with a conflict
+	conflicts int
+	version   int
+}
+
+func newFakeConfigMaps() *fakeConfigMaps {
+	return &fakeConfigMaps{items: map[string]*corev1.ConfigMap{}}
+}
+
+func (f *fakeConfigMaps) GetConfigMap(_ context.Context, namespace, name string) (*corev1.ConfigMap, error) {
+	cm, ok := f.items[namespace+"/"+name]
+	if !ok {
+		return nil, apierrors.NewNotFound(configMapsResource, name)
+	}
+	return cm.DeepCopy(), nil
+}
+
+func (f *fakeConfigMaps) CreateConfigMap(_ context.Context, cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	key := cm.Namespace + "/" + cm.Name
+	if _, ok := f.items[key]; ok {
+		return nil, apierrors.NewAlreadyExists(configMapsResource, cm.Name)
+	}
+	return f.store(key, cm), nil
+}
+
+func (f *fakeConfigMaps) UpdateConfigMap(_ context.Context, cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	key := cm.Namespace + "/" + cm.Name
+	current, ok := f.items[key]
+	if !ok {
+		return nil, apierrors.NewNotFound(configMapsResource, cm.Name)
+	}
+	if f.conflicts > 0 {
+		f.conflicts--
+		// simulate a concurrent modification
+		f.store(key, current)
+	}
+	if f.items[key].ResourceVersion != cm.ResourceVersion {
+		return nil, apierrors.NewConflict(configMapsResource, cm.Name, nil)
+	}
+	return f.store(key, cm), nil
+}
+
+func (f *fakeConfigMaps) store(key string, cm *corev1.ConfigMap) *corev1.ConfigMap {
+	f.version++
+	stored := cm.DeepCopy()
+	stored.ResourceVersion = strconv.Itoa(f.version)
+	f.items[key] = stored
+	return stored.DeepCopy()
+}
+
+func TestConfigMapStore(t *testing.T) {
+	api := newFakeConfigMaps()
+	store := NewConfigMapStore(api, "netobserv", "saved-queries")
+	ctx := context.TODO()
+
+	// no config map yet
+	queries, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, queries)
+	_, err = store.Get(ctx, "1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// config map is created on first put
+	require.NoError(t, store.Put(ctx, &SavedQuery{ID: "1", Name: "b", Owner: "user1"}))
+	require.NoError(t, store.Put(ctx, &SavedQuery{ID: "2", Name: "a", Owner: "user2"}))
+	cm, err := api.GetConfigMap(ctx, "netobserv", "saved-queries")
+	require.NoError(t, err)
+	assert.Len(t, cm.Data, 2)
+
+	queries, err = store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, queries, 2)
+	assert.Equal(t, "2", queries[0].ID)
+	assert.Equal(t, "1", queries[1].ID)
+
+	// update
+	require.NoError(t, store.Put(ctx, &SavedQuery{ID: "1", Name: "c", Owner: "user1"}))
+	q, err := store.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "c", q.Name)
+
+	// delete
+	require.NoError(t, store.Delete(ctx, "2"))
+	assert.ErrorIs(t, store.Delete(ctx, "2"), ErrNotFound)
+	queries, err = store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, queries, 1)
+}
+
+func TestConfigMapStore_Conflicts(t *testing.T) {
+	api := newFakeConfigMaps()
+	store := NewConfigMapStore(api, "netobserv", "saved-queries")
+	ctx := context.TODO()
+	require.NoError(t, store.Put(ctx, &SavedQuery{ID: "1", Name: "a"}))
+
+	// concurrent modifications are retried
+	api.conflicts = maxConflictRetries - 1
+	require.NoError(t, store.Put(ctx, &SavedQuery{ID: "2", Name: "b"}))
+	queries, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, queries, 2)
+
+	// until giving up
+	api.conflicts = maxConflictRetries
+	err = store.Put(ctx, &SavedQuery{ID: "3", Name: "c"})
+	assert.True(t, apierrors.IsConflict(err))
+}
@@ -0,0 +1,122 @@
+package savedquery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// FileStore stores all saved queries as a JSON document in a local file
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) List(_ context.Context) ([]SavedQuery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queries, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return sortedQueries(queries), nil
+}
+
+func (s *FileStore) Get(_ context.Context, id string) (*SavedQuery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queries, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	q, ok := queries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &q, nil
+}
+
+func (s *FileStore) Put(_ context.Context, q *SavedQuery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queries, err := s.read()
+	if err != nil {
+		return err
+	}
+	queries[q.ID] = *q
+	return s.write(queries)
+}
+
+func (s *FileStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queries, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := queries[id]; !ok {
+		return ErrNotFound
+	}
+	delete(queries, id)
+	return s.write(queries)
+}
+
+// read loads queries by id; a missing file means that no query was saved yet
+func (s *FileStore) read() (map[string]SavedQuery, error) {
+	queries := map[string]SavedQuery{}
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return queries, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &queries); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// write replaces the file atomically, so that it's never left partially written
+func (s *FileStore) write(queries map[string]SavedQuery) error {
+	content, err := json.Marshal(queries)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func sortedQueries(queries map[string]SavedQuery) []SavedQuery {
+	list := make([]SavedQuery, 0, len(queries))
+	for _, q := range queries {
+		list = append(list, q)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
@@ -0,0 +1,70 @@
+package savedquery
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.json")
+	store := NewFileStore(path)
+	ctx := context.TODO()
+
+	// no file yet
+	queries, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, queries)
+
+	require.NoError(t, store.Put(ctx, &SavedQuery{ID: "2", Name: "b", Visibility: VisibilityPrivate}))
+	require.NoError(t, store.Put(ctx, &SavedQuery{ID: "1", Name: "a", Visibility: VisibilityCluster, Params: Params{Filters: "SrcPort=80"}}))
+
+	// data is persisted
+	store = NewFileStore(path)
+	queries, err = store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, queries, 2)
+	assert.Equal(t, "a", queries[0].Name)
+	assert.Equal(t, "b", queries[1].Name)
+
+	q, err := store.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "SrcPort=80", q.Params.Filters)
+
+	require.NoError(t, store.Delete(ctx, "1"))
+	_, err = store.Get(ctx, "1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Delete(ctx, "1"), ErrNotFound)
+}
+
+func TestValidate(t *testing.T) {
+	q := SavedQuery{Name: "test", Visibility: VisibilityPrivate}
+	require.NoError(t, q.Validate())
+
+	q = SavedQuery{Name: " ", Visibility: VisibilityPrivate}
+	assert.EqualError(t, q.Validate(), "name is required")
+
+	q = SavedQuery{Name: "test", Visibility: VisibilityNamespace}
+	assert.EqualError(t, q.Validate(), "namespace is required with namespace visibility")
+
+	q = SavedQuery{Name: "test", Visibility: VisibilityCluster, Namespace: "ns"}
+	assert.EqualError(t, q.Validate(), "namespace can only be set with namespace visibility")
+
+	q = SavedQuery{Name: "test", Visibility: "public"}
+	assert.EqualError(t, q.Validate(), "invalid visibility: public")
+}
+
+func TestNewIDs(t *testing.T) {
+	id, shareID, err := NewIDs()
+	require.NoError(t, err)
+	assert.Len(t, id, 16)
+	assert.Regexp(t, "^[0-9a-zA-Z]{8}$", shareID)
+
+	id2, shareID2, err := NewIDs()
+	require.NoError(t, err)
+	assert.NotEqual(t, id, id2)
+	assert.NotEqual(t, shareID, shareID2)
+}
@@ -0,0 +1,182 @@
+// Package savedquery provides persistence for named queries, which can be shared between console users
+package savedquery
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netobserv/network-observability-console-plugin/pkg/config"
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/client"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.WithField("module", "savedquery")
+
+type Visibility string
+
+const (
+	// VisibilityPrivate queries are only visible by their owner
+	VisibilityPrivate Visibility = "private"
+	// VisibilityNamespace queries are visible by users having access to the query namespace
+	VisibilityNamespace Visibility = "namespace"
+	// VisibilityCluster queries are visible by any authenticated user
+	VisibilityCluster Visibility = "cluster"
+
+	maxNameLength = 256
+	shareIDLength = 8
+	shareIDChars  = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+var ErrNotFound = errors.New("saved query not found")
+
+// Params are the query parameters restored when opening a saved query. They use the same names as the
+// flows and topology API parameters
+type Params struct {
+	Filters        string   `json:"filters,omitempty"`
+	FilterSyntax   string   `json:"filterSyntax,omitempty"`
+	CaseSensitive  *bool    `json:"caseSensitive,omitempty"`
+	TimeRange      int64    `json:"timeRange,omitempty"` // relative time range, in seconds
+	StartTime      string   `json:"startTime,omitempty"`
+	EndTime        string   `json:"endTime,omitempty"`
+	RecordType     string   `json:"recordType,omitempty"`
+	DataSource     string   `json:"dataSource,omitempty"`
+	PacketLoss     string   `json:"packetLoss,omitempty"`
+	AggregateBy    string   `json:"aggregateBy,omitempty"`
+	Groups         string   `json:"groups,omitempty"`
+	MetricType     string   `json:"type,omitempty"`
+	MetricFunction string   `json:"function,omitempty"`
+	Panels         []string `json:"panels,omitempty"`
+}
+
+type SavedQuery struct {
+	ID          string     `json:"id"`
+	ShareID     string     `json:"shareId"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Owner       string     `json:"owner"`
+	Visibility  Visibility `json:"visibility"`
+	Namespace   string     `json:"namespace,omitempty"`
+	Params      Params     `json:"params"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// Store persists saved queries
+type Store interface {
+	List(ctx context.Context) ([]SavedQuery, error)
+	// Get returns ErrNotFound if there is no query with this id
+	Get(ctx context.Context, id string) (*SavedQuery, error)
+	// Put creates or replaces the query having the same id
+	Put(ctx context.Context, q *SavedQuery) error
+	// Delete returns ErrNotFound if there is no query with this id
+	Delete(ctx context.Context, id string) error
+}
+
+// NewStore creates the store configured for saved queries. Nothing is read before the first call.
+func NewStore(cfg *config.SavedQueries) (Store, error) {
+	switch cfg.Store {
+	case "", config.SavedQueriesFileStore:
+		if cfg.FilePath == "" {
+			return nil, errors.New("saved queries file path is not configured")
+		}
+		return NewFileStore(cfg.FilePath), nil
+	case config.SavedQueriesConfigMapStore:
+		api, err := client.NewInClusterConfigMaps()
+		if err != nil {
+			return nil, err
+		}
+		return NewConfigMapStore(api, cfg.Namespace, cfg.ConfigMapName), nil
+	}
+	return nil, fmt.Errorf("unknown saved queries store: %s", cfg.Store)
+}
+
+// FindByShareID returns the query having this share id, or ErrNotFound
+func FindByShareID(ctx context.Context, store Store, shareID string) (*SavedQuery, error) {
+	queries, err := store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range queries {
+		if queries[i].ShareID == shareID {
+			return &queries[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// NewIDs generates a random id and a short share id
+func NewIDs() (string, string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	share := strings.Builder{}
+	charsCount := big.NewInt(int64(len(shareIDChars)))
+	for i := 0; i < shareIDLength; i++ {
+		n, err := rand.Int(rand.Reader, charsCount)
+		if err != nil {
+			return "", "", err
+		}
+		share.WriteByte(shareIDChars[n.Int64()])
+	}
+	return hex.EncodeToString(b), share.String(), nil
+}
+
+// Validate checks the query attributes, except for params which are validated as API parameters, see Params.Values
+func (q *SavedQuery) Validate() error {
+	if strings.TrimSpace(q.Name) == "" {
+		return errors.New("name is required")
+	}
+	if len(q.Name) > maxNameLength {
+		return fmt.Errorf("name is too long: more than %d characters", maxNameLength)
+	}
+	switch q.Visibility {
+	case VisibilityPrivate, VisibilityCluster:
+		if q.Namespace != "" {
+			return fmt.Errorf("namespace can only be set with %s visibility", VisibilityNamespace)
+		}
+	case VisibilityNamespace:
+		if q.Namespace == "" {
+			return fmt.Errorf("namespace is required with %s visibility", VisibilityNamespace)
+		}
+	default:
+		return fmt.Errorf("invalid visibility: %s", q.Visibility)
+	}
+	return nil
+}
+
+// Values returns the params as API query parameters
+func (p *Params) Values() url.Values {
+	values := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	set("filters", p.Filters)
+	set("filterSyntax", p.FilterSyntax)
+	if p.CaseSensitive != nil {
+		values.Set("caseSensitive", strconv.FormatBool(*p.CaseSensitive))
+	}
+	if p.TimeRange > 0 {
+		values.Set("timeRange", strconv.FormatInt(p.TimeRange, 10))
+	}
+	set("startTime", p.StartTime)
+	set("endTime", p.EndTime)
+	set("recordType", p.RecordType)
+	set("dataSource", p.DataSource)
+	set("packetLoss", p.PacketLoss)
+	set("aggregateBy", p.AggregateBy)
+	set("groups", p.Groups)
+	set("type", p.MetricType)
+	set("function", p.MetricFunction)
+	return values
+}
@@ -10,17 +10,53 @@ import (
 	"github.com/netobserv/network-observability-console-plugin/pkg/config"
 	"github.com/netobserv/network-observability-console-plugin/pkg/handler"
 	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/client"
+	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/podlabels"
+	"github.com/netobserv/network-observability-console-plugin/pkg/newpeers"
 	"github.com/netobserv/network-observability-console-plugin/pkg/prometheus"
+	"github.com/netobserv/network-observability-console-plugin/pkg/savedquery"
 )
 
 func setupRoutes(ctx context.Context, cfg *config.Config, authChecker auth.Checker) *mux.Router {
 	var promInventory *prometheus.Inventory
 	if cfg.IsPromEnabled() {
 		promInventory = prometheus.NewInventory(&cfg.Prometheus)
+		if cfg.Prometheus.Discovery.Enable {
+			promClient, err := prometheus.NewClient(&cfg.Prometheus, nil)
+			if err != nil {
+				logrus.Warnf("Prometheus metrics discovery is disabled: %v", err)
+			} else {
+				promInventory.StartDiscovery(ctx, promClient, cfg.Prometheus.Discovery.Interval.Duration)
+			}
+		}
+	}
+
+	savedQueries, err := savedquery.NewStore(&cfg.SavedQueries)
+	if err != nil {
+		logrus.Warnf("Saved queries are disabled: %v", err)
+	}
+
+	var podLabels *podlabels.Cache
+	if cfg.PodLabelFilters.Enable {
+		clientset, err := client.NewInClusterClientset()
+		if err != nil {
+			logrus.Warnf("Label selector filters are disabled: %v", err)
+		} else {
+			podLabels = podlabels.NewCache(clientset)
+			podLabels.Start(ctx)
+		}
 	}
 
 	r := mux.NewRouter()
-	h := handler.Handlers{Cfg: cfg, PromInventory: promInventory}
+	h := handler.Handlers{
+		Cfg:            cfg,
+		PromInventory:  promInventory,
+		AuthChecker:    authChecker,
+		SavedQueries:   savedQueries,
+		PodLabels:      podLabels,
+		NewPeers:       newpeers.NewCache(newpeers.DefaultCacheEntries),
+		ScrapeInterval: &prometheus.ScrapeInterval{},
+	}
 
 	api := r.PathPrefix("/api").Subrouter()
 	api.Use(func(orig http.Handler) http.Handler {
@@ -45,12 +81,20 @@ func setupRoutes(ctx context.Context, cfg *config.Config, authChecker auth.Check
 	api.HandleFunc("/loki/flow/records", h.GetFlows(ctx))
 	api.HandleFunc("/loki/flow/metrics", h.GetTopology(ctx))
 	api.HandleFunc("/loki/export", h.ExportFlows(ctx))
+	api.HandleFunc("/loki/flow/newpeers", h.GetNewPeers(ctx))
 	api.HandleFunc("/resources/clusters", h.GetClusters(ctx))
 	api.HandleFunc("/resources/zones", h.GetZones(ctx))
 	api.HandleFunc("/resources/namespaces", h.GetNamespaces(ctx))
 	api.HandleFunc("/resources/namespace/{namespace}/kind/{kind}/names", h.GetNames(ctx))
 	api.HandleFunc("/resources/kind/{kind}/names", h.GetNames(ctx))
 	api.HandleFunc("/frontend-config", h.GetFrontendConfig())
+	api.HandleFunc("/filters/validate", h.ValidateFilters())
+	api.HandleFunc("/saved-queries", h.GetSavedQueries(ctx)).Methods(http.MethodGet)
+	api.HandleFunc("/saved-queries", h.CreateSavedQuery(ctx)).Methods(http.MethodPost)
+	api.HandleFunc("/saved-queries/share/{shareId}", h.GetSharedQuery(ctx)).Methods(http.MethodGet)
+	api.HandleFunc("/saved-queries/{id}", h.GetSavedQuery(ctx)).Methods(http.MethodGet)
+	api.HandleFunc("/saved-queries/{id}", h.UpdateSavedQuery(ctx)).Methods(http.MethodPut)
+	api.HandleFunc("/saved-queries/{id}", h.DeleteSavedQuery(ctx)).Methods(http.MethodDelete)
 
 	r.PathPrefix("/").Handler(http.FileServer(http.Dir("./web/dist/")))
 	return r
@@ -88,7 +88,7 @@ func TestLokiFiltering(t *testing.T) {
 		name:      "OR IP filters",
 		inputPath: "?filters=" + url.QueryEscape("SrcAddr=10.128.0.1,10.128.0.2"),
 		outputQueries: []string{
-			"?query={app=\"netobserv-flowcollector\"}|json|SrcAddr=ip(\"10.128.0.1\")+or+SrcAddr=ip(\"10.128.0.2\")",
+			"?query={app=\"netobserv-flowcollector\"}|json|SrcAddr=ip(\"10.128.0.1\") or SrcAddr=ip(\"10.128.0.2\")",
 		},
 	}, {
 		name:      "Several OR filters",
@@ -172,7 +172,7 @@ func TestLokiFiltering(t *testing.T) {
 		name:      "Empty line filter OR same key",
 		inputPath: "?filters=" + url.QueryEscape(`SrcK8S_Name="",foo&DstK8S_Name="hello"`),
 		outputQueries: []string{
-			"?query={app=\"netobserv-flowcollector\"}|~`DstK8S_Name\":\"hello\"`|json|SrcK8S_Name=\"\"+or+SrcK8S_Name=~`(?i).*foo.*`",
+			"?query={app=\"netobserv-flowcollector\"}|~`DstK8S_Name\":\"hello\"`|json|SrcK8S_Name=\"\" or SrcK8S_Name=~`(?i).*foo.*`",
 		},
 	}, {
 		name:      "Empty label ORed",
@@ -193,13 +193,13 @@ func TestLokiFiltering(t *testing.T) {
 		inputPath: "?filters=" + url.QueryEscape(`SrcK8S_Name="",foo|DstK8S_Name="hello"`),
 		outputQueries: []string{
 			"?query={app=\"netobserv-flowcollector\"}|~`DstK8S_Name\":\"hello\"`",
-			"?query={app=\"netobserv-flowcollector\"}|json|SrcK8S_Name=\"\"+or+SrcK8S_Name=~`(?i).*foo.*`",
+			"?query={app=\"netobserv-flowcollector\"}|json|SrcK8S_Name=\"\" or SrcK8S_Name=~`(?i).*foo.*`",
 		},
 	}, {
 		name:      "Empty line filter ORed (ter)",
 		inputPath: "?filters=" + url.QueryEscape(`SrcK8S_Type="","Pod"`),
 		outputQueries: []string{
-			"?query={app=\"netobserv-flowcollector\"}|json|SrcK8S_Type=\"\"+or+SrcK8S_Type=\"Pod\"",
+			"?query={app=\"netobserv-flowcollector\"}|json|SrcK8S_Type=\"\" or SrcK8S_Type=\"Pod\"",
 		},
 	}, {
 		name:      "Double empty line filters",
@@ -214,6 +214,37 @@ func TestLokiFiltering(t *testing.T) {
 		outputQueries: []string{
 			"?query={app=\"netobserv-flowcollector\"}|json|SrcPort=\"\"",
 		},
+	}, {
+		name:      "Expression syntax with nesting",
+		inputPath: "?filterSyntax=expression&filters=" + url.QueryEscape(`SrcK8S_Namespace = "ns1" and (SrcPort = 8080 or not DstK8S_Name contains "foo")`),
+		outputQueries: []string{
+			"?query={app=\"netobserv-flowcollector\",SrcK8S_Namespace=\"ns1\"}|~`\"DstK8S_Name\"`!~`DstK8S_Name\":\"(?i)[^\"]*foo.*\"`",
+			"?query={app=\"netobserv-flowcollector\",SrcK8S_Namespace=\"ns1\"}|~`SrcPort\":8080[,}]`",
+		},
+	}, {
+		name:      "Expression syntax with regexes",
+		inputPath: "?filterSyntax=expression&filters=" + url.QueryEscape(`SrcK8S_Namespace =~ "prod-.*" and DstK8S_Name !~ "db-[0-9]+"`),
+		outputQueries: []string{
+			"?query={app=\"netobserv-flowcollector\",SrcK8S_Namespace=~\"prod-.*\"}|json|DstK8S_Name!~\"db-[0-9]+\"",
+		},
+	}, {
+		name:      "Case-insensitive filters",
+		inputPath: "?caseSensitive=false&filters=" + url.QueryEscape(`SrcK8S_Namespace=~Prod-.*&DstK8S_Name="Api"`),
+		outputQueries: []string{
+			"?query={app=\"netobserv-flowcollector\",SrcK8S_Namespace=~\"(?i)Prod-.*\"}|~`DstK8S_Name\":\"Api\"`",
+		},
+	}, {
+		name:      "Case-sensitive filters",
+		inputPath: "?caseSensitive=true&filters=" + url.QueryEscape(`SrcK8S_Namespace=~Prod-.*&DstK8S_Name=Api`),
+		outputQueries: []string{
+			"?query={app=\"netobserv-flowcollector\",SrcK8S_Namespace=~\"Prod-.*\"}|~`DstK8S_Name\":\"[^\"]*Api.*\"`",
+		},
+	}, {
+		name:      "Expression syntax with special characters",
+		inputPath: "?filterSyntax=expression&filters=" + url.QueryEscape(`SrcK8S_Name in ("a=b", "c&d|e,f")`),
+		outputQueries: []string{
+			"?query={app=\"netobserv-flowcollector\"}|~`SrcK8S_Name\":\"a=b\"|SrcK8S_Name\":\"c&d\\|e,f\"`",
+		},
 	}}
 
 	numberQueriesExpected := 0
@@ -274,13 +305,13 @@ func TestLokiFiltering(t *testing.T) {
 			if len(expectedURLs) > 0 {
 				expectedWithTime := injectTime(t, expectedURLs, now)
 				for range expectedURLs {
-					requestURL := lokiMock.Calls[nCall].Arguments[1].(*http.Request).URL.String()
+					requestURL := unescapedURL(t, lokiMock.Calls[nCall].Arguments[1].(*http.Request))
 					assert.Contains(t, expectedWithTime, requestURL)
 					nCall++
 				}
 			} else {
 				for i, part := range tc.outputQueryParts {
-					requestURL := lokiMock.Calls[nCall].Arguments[1].(*http.Request).URL.String()
+					requestURL := unescapedURL(t, lokiMock.Calls[nCall].Arguments[1].(*http.Request))
 					if i == 0 {
 						// First part always includes URL
 						part = "/loki/api/v1/query_range" + part
@@ -293,6 +324,13 @@ func TestLokiFiltering(t *testing.T) {
 	}
 }
 
+// unescapedURL decodes the request URL, for readability of the expected queries
+func unescapedURL(t *testing.T, req *http.Request) string {
+	decoded, err := url.QueryUnescape(req.URL.String())
+	require.NoError(t, err)
+	return decoded
+}
+
 func injectTime(t *testing.T, queries []string, now int64) []string {
 	var modifiedQueries []string
 	for _, query := range queries {
@@ -15,9 +15,11 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -30,6 +32,7 @@ import (
 	"github.com/netobserv/network-observability-console-plugin/pkg/kubernetes/auth"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model"
 	"github.com/netobserv/network-observability-console-plugin/pkg/model/fields"
+	"github.com/netobserv/network-observability-console-plugin/pkg/newpeers"
 )
 
 const (
@@ -323,6 +326,45 @@ func TestLokiConfiguration(t *testing.T) {
 	assert.NotNil(t, qr.Result)
 }
 
+func TestLokiConfiguration_LongQueryUsesPost(t *testing.T) {
+	// GIVEN a Loki service
+	lokiMock := httpMock{}
+	var form url.Values
+	lokiMock.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		req := args.Get(1).(*http.Request)
+		require.NoError(t, req.ParseForm())
+		form = req.PostForm
+		_, _ = args.Get(0).(http.ResponseWriter).Write([]byte(`{"status":"","data":{"resultType":"streams","result":[]}}`))
+	})
+	lokiSvc := httptest.NewServer(&lokiMock)
+	defer lokiSvc.Close()
+	authM := &authMock{}
+	authM.MockGranted()
+
+	// THAT is accessed behind the NOO console plugin backend, with a low max URL length
+	backendRoutes := setupRoutes(context.TODO(), &config.Config{
+		Loki: config.Loki{URL: lokiSvc.URL, Timeout: config.Duration{Duration: time.Second}, MaxURLLength: 100},
+	}, authM)
+	backendSvc := httptest.NewServer(backendRoutes)
+	defer backendSvc.Close()
+
+	// WHEN the Loki flows endpoint is queried in the backend with many filter values
+	resp, err := backendSvc.Client().Get(backendSvc.URL + "/api/loki/flow/records?filters=" + url.QueryEscape("SrcK8S_Name=name1,name2,name3,name4,name5"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// THEN the query has been forwarded to Loki as a form-encoded POST
+	req := lokiMock.Calls[0].Arguments[1].(*http.Request)
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, "/loki/api/v1/query_range", req.URL.Path)
+	assert.Empty(t, req.URL.RawQuery)
+	assert.Equal(
+		t,
+		`{app="netobserv-flowcollector"}|~`+"`"+`SrcK8S_Name":"(?i)[^"]*name1.*"|SrcK8S_Name":"(?i)[^"]*name2.*"|SrcK8S_Name":"(?i)[^"]*name3.*"|SrcK8S_Name":"(?i)[^"]*name4.*"|SrcK8S_Name":"(?i)[^"]*name5.*"`+"`",
+		form.Get("query"),
+	)
+}
+
 func TestLokiConfigurationForTopology(t *testing.T) {
 	// GIVEN a Loki service
 	lokiMock := httpMock{}
@@ -432,6 +474,429 @@ func TestLokiConfigurationForTableHistogram(t *testing.T) {
 	assert.NotNil(t, qr.Result)
 }
 
+func TestLokiConfigurationForTopologyGraph(t *testing.T) {
+	// GIVEN a Loki service returning topology metrics
+	lokiMock := httpMock{}
+	lokiMock.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		_, _ = args.Get(0).(http.ResponseWriter).Write([]byte(`{"status":"","data":{"resultType":"matrix","result":[` +
+			`{"metric":{"SrcK8S_Namespace":"ns1","DstK8S_Namespace":"ns2"},"values":[[1700000000,"10"],[1700000030,"30"]]}]}}`))
+	})
+	lokiSvc := httptest.NewServer(&lokiMock)
+	defer lokiSvc.Close()
+	authM := &authMock{}
+	authM.MockGranted()
+
+	// THAT is accessed behind the NOO console plugin backend
+	backendRoutes := setupRoutes(context.TODO(), &config.Config{
+		Loki: config.Loki{
+			URL:     lokiSvc.URL,
+			Timeout: config.Duration{Duration: time.Second},
+		},
+	}, authM)
+	backendSvc := httptest.NewServer(backendRoutes)
+	defer backendSvc.Close()
+
+	// WHEN the topology is queried in graph format
+	resp, err := backendSvc.Client().Get(backendSvc.URL + "/api/loki/flow/metrics?type=Flows&function=count&aggregateBy=namespace&format=graph")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// THEN the response is a graph of nodes and edges
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var gr model.TopologyGraphResponse
+	err = json.Unmarshal(body, &gr)
+	require.NoError(t, err)
+	assert.Equal(t, []model.TopologyNode{
+		{ID: "n=ns1", Kind: "Namespace", TopologyPeer: model.TopologyPeer{Namespace: "ns1"}},
+		{ID: "n=ns2", Kind: "Namespace", TopologyPeer: model.TopologyPeer{Namespace: "ns2"}},
+	}, gr.Graph.Nodes)
+	assert.Equal(t, []model.TopologyEdge{
+		{ID: "n=ns1.n=ns2", Source: "n=ns1", Target: "n=ns2", Total: 40, Avg: 20, Max: 30, Latest: 30},
+	}, gr.Graph.Edges)
+	assert.Empty(t, gr.Graph.Groups)
+
+	// WHEN rates are queried with a step in days
+	resp, err = backendSvc.Client().Get(backendSvc.URL + "/api/loki/flow/metrics?type=Bytes&function=rate&aggregateBy=namespace&format=graph&step=1d")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// THEN totals are the rates over each of the two days
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	gr = model.TopologyGraphResponse{}
+	err = json.Unmarshal(body, &gr)
+	require.NoError(t, err)
+	require.Len(t, gr.Graph.Edges, 1)
+	assert.NotZero(t, gr.Graph.Edges[0].Total)
+	assert.Equal(t, gr.Graph.Edges[0].Avg*2*86400, gr.Graph.Edges[0].Total)
+
+	// WHEN the format is unknown
+	resp, err = backendSvc.Client().Get(backendSvc.URL + "/api/loki/flow/metrics?aggregateBy=namespace&format=tree")
+	require.NoError(t, err)
+
+	// THEN the request is rejected
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestLokiConfigurationForTopologyComparison(t *testing.T) {
+	// GIVEN a Loki service returning different topology metrics for last week
+	lokiMock := httpMock{}
+	lokiMock.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		req := args.Get(1).(*http.Request)
+		result := `{"metric":{"SrcK8S_Namespace":"ns1","DstK8S_Namespace":"ns2"},"values":[[1700000000,"30"]]}`
+		if req.URL.Query().Get("start") == "1699395200" {
+			result = `{"metric":{"SrcK8S_Namespace":"ns1","DstK8S_Namespace":"ns2"},"values":[[1699395200,"10"]]},` +
+				`{"metric":{"SrcK8S_Namespace":"ns1","DstK8S_Namespace":"ns3"},"values":[[1699395200,"5"]]}`
+		}
+		_, _ = args.Get(0).(http.ResponseWriter).Write([]byte(`{"status":"","data":{"resultType":"matrix","result":[` + result + `]}}`))
+	})
+	lokiSvc := httptest.NewServer(&lokiMock)
+	defer lokiSvc.Close()
+	authM := &authMock{}
+	authM.MockGranted()
+
+	// THAT is accessed behind the NOO console plugin backend
+	backendRoutes := setupRoutes(context.TODO(), &config.Config{
+		Loki: config.Loki{
+			URL:     lokiSvc.URL,
+			Timeout: config.Duration{Duration: time.Second},
+		},
+	}, authM)
+	backendSvc := httptest.NewServer(backendRoutes)
+	defer backendSvc.Close()
+
+	// WHEN the topology is compared with the same time last week
+	resp, err := backendSvc.Client().Get(backendSvc.URL + "/api/loki/flow/metrics?type=Flows&function=count&aggregateBy=namespace&startTime=1700000000&endTime=1700003600&step=1h&compareTo=1w")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// THEN both windows are queried
+	require.Len(t, lokiMock.Calls, 2)
+	req1 := lokiMock.Calls[0].Arguments[1].(*http.Request)
+	req2 := lokiMock.Calls[1].Arguments[1].(*http.Request)
+	assert.Equal(t, []string{"1700000000", "1700003601"}, []string{req1.URL.Query().Get("start"), req1.URL.Query().Get("end")})
+	assert.Equal(t, []string{"1699395200", "1699398801"}, []string{req2.URL.Query().Get("start"), req2.URL.Query().Get("end")})
+
+	// AND each series is compared with its baseline
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var cr model.ComparisonQueryResponse
+	err = json.Unmarshal(body, &cr)
+	require.NoError(t, err)
+	assert.Equal(t, "1w", cr.CompareTo)
+	require.Len(t, cr.Result, 2)
+	// averaged over the samples at the start and end of the hour, the missing one counting as zero
+	assert.Equal(t, 15.0, cr.Result[0].Current)
+	assert.Equal(t, 5.0, cr.Result[0].Baseline)
+	assert.Equal(t, 10.0, cr.Result[0].Delta)
+	require.NotNil(t, cr.Result[0].RelativeDelta)
+	assert.Equal(t, 2.0, *cr.Result[0].RelativeDelta)
+	assert.Empty(t, cr.Result[0].Change)
+	assert.Len(t, cr.Result[0].BaselineValues, 1)
+	assert.Equal(t, "ns3", string(cr.Result[1].Metric["DstK8S_Namespace"]))
+	assert.Equal(t, model.SeriesDisappeared, cr.Result[1].Change)
+
+	// WHEN the time window is not set
+	resp, err = backendSvc.Client().Get(backendSvc.URL + "/api/loki/flow/metrics?aggregateBy=namespace&compareTo=1w")
+	require.NoError(t, err)
+
+	// THEN the request is rejected
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestLokiConfigurationForTopologyOthers(t *testing.T) {
+	// GIVEN a Loki service returning the top topology metrics, and their total
+	lokiMock := httpMock{}
+	lokiMock.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		req := args.Get(1).(*http.Request)
+		result := `{"metric":{"SrcK8S_Namespace":"ns1","DstK8S_Namespace":"ns2"},"values":[[1700000000,"30"],[1700000030,"20"]]},` +
+			`{"metric":{"SrcK8S_Namespace":"ns1","DstK8S_Namespace":"ns3"},"values":[[1700000000,"10"]]}`
+		if strings.HasPrefix(req.URL.Query().Get("query"), "sum(") {
+			result = `{"metric":{},"values":[[1700000000,"45"],[1700000030,"20"]]}`
+		}
+		_, _ = args.Get(0).(http.ResponseWriter).Write([]byte(`{"status":"","data":{"resultType":"matrix","result":[` + result + `]}}`))
+	})
+	lokiSvc := httptest.NewServer(&lokiMock)
+	defer lokiSvc.Close()
+	authM := &authMock{}
+	authM.MockGranted()
+
+	// THAT is accessed behind the NOO console plugin backend
+	backendRoutes := setupRoutes(context.TODO(), &config.Config{
+		Loki: config.Loki{
+			URL:     lokiSvc.URL,
+			Timeout: config.Duration{Duration: time.Second},
+		},
+	}, authM)
+	backendSvc := httptest.NewServer(backendRoutes)
+	defer backendSvc.Close()
+
+	// WHEN the topology is queried with others
+	resp, err := backendSvc.Client().Get(backendSvc.URL + "/api/loki/flow/metrics?type=Flows&function=count&aggregateBy=namespace&limit=2&others=true")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// THEN the top series and the total are queried
+	require.Len(t, lokiMock.Calls, 2)
+	req1 := lokiMock.Calls[0].Arguments[1].(*http.Request)
+	req2 := lokiMock.Calls[1].Arguments[1].(*http.Request)
+	assert.True(t, strings.HasPrefix(req1.URL.Query().Get("query"), "topk(2,sum by(SrcK8S_Namespace,DstK8S_Namespace)("), req1.URL.Query().Get("query"))
+	assert.True(t, strings.HasPrefix(req2.URL.Query().Get("query"), "sum(sum by(SrcK8S_Namespace,DstK8S_Namespace)(count_over_time("), req2.URL.Query().Get("query"))
+
+	// AND the remainder is returned as an extra series
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var qr struct {
+		Result model.Matrix          `json:"result"`
+		Stats  model.AggregatedStats `json:"stats"`
+	}
+	err = json.Unmarshal(body, &qr)
+	require.NoError(t, err)
+	require.Len(t, qr.Result, 3)
+	assert.Equal(t, "true", string(qr.Result[2].Metric[model.OthersLabel]))
+	require.Len(t, qr.Result[2].Values, 2)
+	assert.Equal(t, 5.0, float64(qr.Result[2].Values[0].Value))
+	assert.Zero(t, float64(qr.Result[2].Values[1].Value))
+	assert.Equal(t, 2, qr.Stats.NumQueries)
+
+	// WHEN others is requested with a function that is not a sum
+	resp, err = backendSvc.Client().Get(backendSvc.URL + "/api/loki/flow/metrics?type=Bytes&function=max&aggregateBy=namespace&others=true")
+	require.NoError(t, err)
+
+	// THEN the request is rejected
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestLokiConfigurationForTopologyAutoStep(t *testing.T) {
+	// GIVEN a Loki service
+	lokiMock := httpMock{}
+	lokiMock.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		_, _ = args.Get(0).(http.ResponseWriter).Write([]byte(`{"status":"","data":{"resultType":"matrix","result":[]}}`))
+	})
+	lokiSvc := httptest.NewServer(&lokiMock)
+	defer lokiSvc.Close()
+	authM := &authMock{}
+	authM.MockGranted()
+
+	// THAT is accessed behind the NOO console plugin backend
+	backendRoutes := setupRoutes(context.TODO(), &config.Config{
+		Loki: config.Loki{
+			URL:     lokiSvc.URL,
+			Timeout: config.Duration{Duration: time.Second},
+		},
+	}, authM)
+	backendSvc := httptest.NewServer(backendRoutes)
+	defer backendSvc.Close()
+
+	// WHEN the topology is queried over a week with automatic step and rate interval
+	resp, err := backendSvc.Client().Get(backendSvc.URL + "/api/loki/flow/metrics?type=Flows&function=rate&aggregateBy=namespace&startTime=1700000000&endTime=1700604799&step=auto&rateInterval=auto")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// THEN the query uses the chosen values
+	require.Len(t, lokiMock.Calls, 1)
+	req := lokiMock.Calls[0].Arguments[1].(*http.Request)
+	assert.Equal(t, "1h", req.URL.Query().Get("step"))
+	assert.Contains(t, req.URL.Query().Get("query"), "[1h]")
+
+	// AND they are returned in stats
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var qr struct {
+		Stats model.AggregatedStats `json:"stats"`
+	}
+	err = json.Unmarshal(body, &qr)
+	require.NoError(t, err)
+	assert.Equal(t, "1h", qr.Stats.Step)
+	assert.Equal(t, "1h", qr.Stats.RateInterval)
+}
+
+func TestLokiConfigurationForTopologyAnomalies(t *testing.T) {
+	// GIVEN a Loki service returning topology metrics, with a spike at the end
+	lokiMock := httpMock{}
+	lokiMock.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		_, _ = args.Get(0).(http.ResponseWriter).Write([]byte(`{"status":"","data":{"resultType":"matrix","result":[` +
+			`{"metric":{"SrcK8S_Namespace":"ns1","DstK8S_Namespace":"ns2"},"values":[[1699996400,"10"],[1699998200,"10"],[1700000000,"10"]]},` +
+			`{"metric":{"SrcK8S_Namespace":"ns1","DstK8S_Namespace":"ns3"},"values":[[1699996400,"10"],[1699998200,"10"],[1700000000,"50"]]}]}}`))
+	})
+	lokiSvc := httptest.NewServer(&lokiMock)
+	defer lokiSvc.Close()
+	authM := &authMock{}
+	authM.MockGranted()
+
+	// THAT is accessed behind the NOO console plugin backend
+	backendRoutes := setupRoutes(context.TODO(), &config.Config{
+		Loki: config.Loki{
+			URL:     lokiSvc.URL,
+			Timeout: config.Duration{Duration: time.Second},
+		},
+	}, authM)
+	backendSvc := httptest.NewServer(backendRoutes)
+	defer backendSvc.Close()
+
+	// WHEN the topology is queried with the anomaly function
+	resp, err := backendSvc.Client().Get(backendSvc.URL + "/api/loki/flow/metrics?type=Flows&function=anomaly&aggregateBy=namespace&startTime=1700000000&endTime=1700000600&lookback=1h&step=30m&limit=1")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// THEN the query runs as a rate over the extended time window, with more candidates
+	require.Len(t, lokiMock.Calls, 1)
+	req := lokiMock.Calls[0].Arguments[1].(*http.Request)
+	assert.Equal(t, "1699996400", req.URL.Query().Get("start"))
+	assert.Contains(t, req.URL.Query().Get("query"), "topk(5,")
+	assert.Contains(t, req.URL.Query().Get("query"), "rate(")
+
+	// AND series are ranked by anomaly
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var ar model.AnomalyQueryResponse
+	err = json.Unmarshal(body, &ar)
+	require.NoError(t, err)
+	assert.Equal(t, "1h", ar.Lookback)
+	require.Len(t, ar.Result, 1)
+	assert.Equal(t, "ns3", string(ar.Result[0].Metric["DstK8S_Namespace"]))
+	assert.Equal(t, model.MaxAnomalyScore, ar.Result[0].Score)
+	assert.Equal(t, 10.0, ar.Result[0].Baseline)
+	assert.Len(t, ar.Result[0].Values, 1)
+
+	// WHEN the look-back window is long compared to the step
+	resp, err = backendSvc.Client().Get(backendSvc.URL + "/api/loki/flow/metrics?type=Flows&function=anomaly&aggregateBy=namespace&startTime=1700000000&endTime=1700000600&lookback=1w&step=30s")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// THEN the step is raised to limit the points per series
+	require.Len(t, lokiMock.Calls, 2)
+	req = lokiMock.Calls[1].Arguments[1].(*http.Request)
+	assert.Equal(t, "1699395200", req.URL.Query().Get("start"))
+	assert.Equal(t, "10m6s", req.URL.Query().Get("step"))
+
+	// WHEN combined with a comparison
+	resp, err = backendSvc.Client().Get(backendSvc.URL + "/api/loki/flow/metrics?function=anomaly&aggregateBy=namespace&timeRange=300&compareTo=1d")
+	require.NoError(t, err)
+
+	// THEN the request is rejected
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestLokiConfigurationForNewPeers(t *testing.T) {
+	// GIVEN a Loki service where api started talking to cache within the last hour
+	recentStart := time.Now().Add(-2 * time.Hour).Unix()
+	lokiMock := httpMock{}
+	lokiMock.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		query := args.Get(1).(*http.Request).URL.Query()
+		var result string
+		if !strings.Contains(query.Get("query"), "Addr") {
+			start, _ := strconv.ParseInt(query.Get("start"), 10, 64)
+			result = `{"metric":{"SrcK8S_Namespace":"ns","SrcK8S_OwnerName":"api","SrcK8S_OwnerType":"Deployment","DstK8S_Namespace":"ns","DstK8S_OwnerName":"db","DstK8S_OwnerType":"Deployment"},"values":[[` + strconv.FormatInt(start, 10) + `,"100"]]}`
+			if start > recentStart {
+				result += `,{"metric":{"SrcK8S_Namespace":"ns","SrcK8S_OwnerName":"api","SrcK8S_OwnerType":"Deployment","DstK8S_Namespace":"ns","DstK8S_OwnerName":"cache","DstK8S_OwnerType":"Deployment"},"values":[[` + strconv.FormatInt(start+60, 10) + `,"50"]]}`
+			}
+		}
+		_, _ = args.Get(0).(http.ResponseWriter).Write([]byte(`{"status":"","data":{"resultType":"matrix","result":[` + result + `]}}`))
+	})
+	lokiSvc := httptest.NewServer(&lokiMock)
+	defer lokiSvc.Close()
+	authM := &authMock{}
+	authM.MockGranted()
+
+	// THAT is accessed behind the NOO console plugin backend
+	backendRoutes := setupRoutes(context.TODO(), &config.Config{
+		Loki: config.Loki{
+			URL:     lokiSvc.URL,
+			Timeout: config.Duration{Duration: time.Second},
+		},
+	}, authM)
+	backendSvc := httptest.NewServer(backendRoutes)
+	defer backendSvc.Close()
+
+	// WHEN new peers are queried
+	resp, err := backendSvc.Client().Get(backendSvc.URL + "/api/loki/flow/newpeers?window=1h&baseline=1w")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// THEN owners and external IPs edges are queried on both the baseline and the recent windows
+	// (each query being split to merge reporters)
+	assert.Len(t, lokiMock.Calls, 12)
+
+	// AND the new peer is returned
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var nr newpeers.Response
+	err = json.Unmarshal(body, &nr)
+	require.NoError(t, err)
+	require.Len(t, nr.Peers, 1)
+	assert.Equal(t, "cache", nr.Peers[0].Destination.OwnerName)
+	assert.Equal(t, newpeers.DirectionInternal, nr.Peers[0].Direction)
+	// both reporters queries return the same rate here, over the 30s step
+	assert.Contains(t, lokiMock.Calls[0].Arguments[1].(*http.Request).URL.Query().Get("query"), "rate(")
+	assert.Equal(t, 3000.0, nr.Peers[0].Bytes)
+	assert.Equal(t, 1, nr.BaselineEdges)
+	assert.Equal(t, nr.UnixTimestamp-3600-7*24*3600, nr.BaselineStart)
+
+	// WHEN queried again
+	resp, err = backendSvc.Client().Get(backendSvc.URL + "/api/loki/flow/newpeers?window=1h&baseline=1w")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// THEN the baseline is not queried again
+	assert.Len(t, lokiMock.Calls, 18)
+}
+
+func TestPrometheusConfigurationForNewPeers(t *testing.T) {
+	// GIVEN a Prometheus service where api started talking to cache within the last hour
+	recentStart := time.Now().Add(-2 * time.Hour).Unix()
+	promMock := httpMock{}
+	promMock.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		req := args.Get(1).(*http.Request)
+		_ = req.ParseForm()
+		var result string
+		if !strings.Contains(req.Form.Get("query"), "Addr") {
+			start, _ := strconv.ParseFloat(req.Form.Get("start"), 64)
+			result = `{"metric":{"SrcK8S_Namespace":"ns","SrcK8S_OwnerName":"api","SrcK8S_OwnerType":"Deployment","DstK8S_Namespace":"ns","DstK8S_OwnerName":"db","DstK8S_OwnerType":"Deployment"},"values":[[` + strconv.FormatInt(int64(start), 10) + `,"100"]]}`
+			if int64(start) > recentStart {
+				// 10 bytes/s over two steps of 30s
+				result += `,{"metric":{"SrcK8S_Namespace":"ns","SrcK8S_OwnerName":"api","SrcK8S_OwnerType":"Deployment","DstK8S_Namespace":"ns","DstK8S_OwnerName":"cache","DstK8S_OwnerType":"Deployment"},"values":[[` +
+					strconv.FormatInt(int64(start)+60, 10) + `,"10"],[` + strconv.FormatInt(int64(start)+90, 10) + `,"10"]]}`
+			}
+		}
+		_, _ = args.Get(0).(http.ResponseWriter).Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[` + result + `]}}`))
+	})
+	promSvc := httptest.NewServer(&promMock)
+	defer promSvc.Close()
+	authM := &authMock{}
+	authM.MockGranted()
+
+	// THAT is accessed behind the NOO console plugin backend, without Loki
+	labels := []string{"SrcK8S_Namespace", "SrcK8S_OwnerName", "SrcK8S_OwnerType", "DstK8S_Namespace", "DstK8S_OwnerName", "DstK8S_OwnerType", "SrcAddr", "DstAddr"}
+	backendRoutes := setupRoutes(context.TODO(), &config.Config{
+		Prometheus: config.Prometheus{
+			URL:     promSvc.URL,
+			Timeout: config.Duration{Duration: time.Second},
+			Metrics: []config.MetricInfo{
+				{Enabled: true, Name: "netobserv_workload_bytes_total", Type: "counter", ValueField: "Bytes", Direction: config.AnyDirection, Labels: labels},
+			},
+		},
+	}, authM)
+	backendSvc := httptest.NewServer(backendRoutes)
+	defer backendSvc.Close()
+
+	// WHEN new peers are queried
+	resp, err := backendSvc.Client().Get(backendSvc.URL + "/api/loki/flow/newpeers?window=1h&baseline=1w")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+
+	// THEN the new peer is returned with its bytes: the rates multiplied by the step
+	var nr newpeers.Response
+	err = json.Unmarshal(body, &nr)
+	require.NoError(t, err)
+	require.Len(t, nr.Peers, 1)
+	assert.Equal(t, "cache", nr.Peers[0].Destination.OwnerName)
+	assert.Equal(t, 600.0, nr.Peers[0].Bytes)
+}
+
 func prepareTokenFile(t *testing.T) (string, *os.File) {
 	tmpDir, err := os.MkdirTemp("", "server-test")
 	require.NoError(t, err)
@@ -518,9 +983,21 @@ func (a *authMock) CheckAdmin(ctx context.Context, header http.Header) error {
 	return args.Error(0)
 }
 
+func (a *authMock) GetUser(ctx context.Context, header http.Header) (string, error) {
+	args := a.Called(ctx, header)
+	return args.String(0), args.Error(1)
+}
+
+func (a *authMock) CheckNamespace(ctx context.Context, header http.Header, namespace string) error {
+	args := a.Called(ctx, header, namespace)
+	return args.Error(0)
+}
+
 func (a *authMock) MockGranted() {
 	a.On("CheckAuth", mock.Anything, mock.Anything).Return(nil)
 	a.On("CheckAdmin", mock.Anything, mock.Anything).Return(nil)
+	a.On("GetUser", mock.Anything, mock.Anything).Return("user1", nil)
+	a.On("CheckNamespace", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 }
 
 func prepareServerAssets(t *testing.T) string {
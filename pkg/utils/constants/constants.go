@@ -6,6 +6,7 @@ type DataSource string
 type PacketLoss string
 type Discriminator string
 type Direction string
+type ReportersMerge string
 
 const (
 	AppLabel        = "app"
@@ -29,7 +30,10 @@ const (
 	MetricFunctionMax     MetricFunction = "max"
 	MetricFunctionP90     MetricFunction = "p90"
 	MetricFunctionP99     MetricFunction = "p99"
+	MetricFunctionStddev  MetricFunction = "stddev"
+	MetricFunctionLast    MetricFunction = "last"
 	MetricFunctionRate    MetricFunction = "rate"
+	MetricFunctionAnomaly MetricFunction = "anomaly"
 	DefaultMetricFunction MetricFunction = MetricFunctionRate
 
 	RecordTypeAllConnections RecordType = "allConnections"
@@ -53,6 +57,12 @@ const (
 	Ingress Direction = "0"
 	Egress  Direction = "1"
 	Inner   Direction = "2"
+
+	ReportersMergeIngress ReportersMerge = "ingress"
+	ReportersMergeEgress  ReportersMerge = "egress"
+	ReportersMergeMax     ReportersMerge = "max"
+	ReportersMergeNone    ReportersMerge = "none"
+	DefaultReportersMerge ReportersMerge = ReportersMergeIngress
 )
 
 var AnyConnectionType = []string{
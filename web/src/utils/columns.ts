@@ -289,6 +289,7 @@ export const getDefaultColumns = (columnDefs: ColumnConfigDef[], fieldConfigs: F
         case 'number':
           return Number(value);
         case 'string':
+        case 'ip':
           return String(value);
         default:
           throw new Error('forceType error: type ' + type + ' is not managed');
@@ -1,4 +1,4 @@
-export type FieldType = 'string' | 'number';
+export type FieldType = 'string' | 'number' | 'boolean' | 'ip' | 'string[]' | 'number[]';
 
 export interface FieldConfig {
   name: string;